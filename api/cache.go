@@ -0,0 +1,157 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keeps the answers of the API to the GET and OPTIONS requests, by URL,
+// so that the same objects are not fetched again while they are fresh.
+// The objects are found at the URLs of their path and of their id,
+// the requests of the client that change them invalidate both
+type Cache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*cacheEntry
+	hits    int
+	misses  int
+}
+
+type entryKind int
+
+const (
+	objectEntry   entryKind = iota //a single object, such as /api/racks/{id}
+	listEntry                      //objects, hierarchies and the other answers
+	notFoundEntry                  //nothing at this URL
+)
+
+// Fields of an object that give its path
+type objectPlace struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID string `json:"parentId"`
+}
+
+type cacheEntry struct {
+	kind    entryKind
+	obj     objectPlace //of the object entries, empty for OPTIONS
+	body    []byte      //nil for an OPTIONS request
+	env     *envelope
+	err     error
+	expires time.Time
+}
+
+// Returns a cache whose entries expire after ttl, nothing is kept if it is 0
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{ttl: ttl, entries: map[string]*cacheEntry{}}
+}
+
+type CacheStats struct {
+	Entries int
+	Hits    int
+	Misses  int
+	TTL     time.Duration
+}
+
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{len(c.entries), c.hits, c.misses, c.ttl}
+}
+
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*cacheEntry{}
+}
+
+// Returns the answer to a request if it is known, the answers to the GET
+// requests also answer the OPTIONS requests to the same URL
+func (c *Cache) get(method, URL string) (*cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[URL]
+	if ok && time.Now().After(entry.expires) {
+		delete(c.entries, URL)
+		ok = false
+	}
+	if ok && method == http.MethodGet && entry.body == nil && entry.kind != notFoundEntry {
+		//Only the existence of the object is known
+		ok = false
+	}
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	return entry, ok
+}
+
+func (c *Cache) put(method, URL string, body []byte, env *envelope, err error) {
+	if c.ttl <= 0 {
+		return
+	}
+	entry := &cacheEntry{kind: listEntry, body: body, env: env, err: err,
+		expires: time.Now().Add(c.ttl)}
+	if method == http.MethodOptions {
+		//The API may answer with the object or not
+		entry.body, entry.env = nil, nil
+	}
+	if err != nil {
+		if !IsNotFound(err) {
+			return
+		}
+		entry.kind = notFoundEntry
+	} else if method == http.MethodOptions {
+		entry.kind = objectEntry
+	} else if env != nil && !strings.Contains(URL, "?") && !strings.HasSuffix(URL, "/all") {
+		if json.Unmarshal(env.Data, &entry.obj) == nil && entry.obj.ID != "" {
+			entry.kind = objectEntry
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.entries[URL]; ok && old.body != nil && entry.body == nil {
+		//Keep the object, it answers both kinds of requests
+		return
+	}
+	c.entries[URL] = entry
+}
+
+// Drops the entries that a request changing the objects may make wrong :
+// a new object may be at a URL that was not found and it changes the lists
+// and the hierarchies, a deleted, renamed or moved object changes the paths
+// of its descendants. The updated object is given by its id and the data sent
+func (c *Cache) invalidate(method string, id string, data map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if method == http.MethodDelete || method != http.MethodPost && c.moves(id, data) {
+		c.entries = map[string]*cacheEntry{}
+		return
+	}
+	for URL, entry := range c.entries {
+		if entry.kind != objectEntry || id != "" && entry.obj.ID == id {
+			delete(c.entries, URL)
+		}
+	}
+}
+
+// Tells whether the data changes the path of the object, or may change it
+// if the object is not in the cache
+func (c *Cache) moves(id string, data map[string]interface{}) bool {
+	name, hasName := data["name"]
+	parentID, hasParent := data["parentId"]
+	if !hasName && !hasParent {
+		return false
+	}
+	for _, entry := range c.entries {
+		if entry.kind == objectEntry && entry.obj.ID == id {
+			return hasName && name != entry.obj.Name ||
+				hasParent && parentID != entry.obj.ParentID
+		}
+	}
+	return true
+}
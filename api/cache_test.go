@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Returns a client with a cache and the number of GET and OPTIONS
+// requests received by its API, which has the racks R1 and R2 in a room
+func cachedClient(t *testing.T, ttl time.Duration) (*Client, *int) {
+	fetches := 0
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodOptions {
+			fetches++
+		}
+		switch r.URL.Path {
+		case "/api/racks/R1", "/api/rooms/RO/racks/R1":
+			w.Write([]byte(`{"status":true,"data":{"id":"R1","name":"R1","parentId":"RO"}}`))
+		case "/api/racks/R2":
+			w.Write([]byte(`{"status":true,"data":{"id":"R2","name":"R2","parentId":"RO"}}`))
+		case "/api/rooms/RO/racks":
+			w.Write([]byte(`{"status":true,"data":{"objects":[{"id":"R1","name":"R1"},{"id":"R2","name":"R2"}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":false,"message":"Nothing matches this request"}`))
+		}
+	})
+	c.Cache = NewCache(ttl)
+	return c, &fetches
+}
+
+func TestCacheHits(t *testing.T) {
+	c, fetches := cachedClient(t, time.Minute)
+	for i := 0; i < 2; i++ {
+		if rack, err := c.GetRack("R1"); err != nil || rack.Name != "R1" {
+			t.Fatalf("wrong rack : %v %v", rack, err)
+		}
+		if exists, _ := c.ObjectExistsAt(c.URL + "/api/racks/R1"); !exists {
+			t.Errorf("the rack should exist")
+		}
+		if _, err := c.GetRack("R3"); !IsNotFound(err) {
+			t.Errorf("expected a not found error, got %v", err)
+		}
+	}
+	if *fetches != 2 {
+		t.Errorf("the rack and the missing rack should be fetched once, got %d requests", *fetches)
+	}
+	stats := c.Cache.Stats()
+	if stats.Entries != 2 || stats.Misses != 2 || stats.Hits != 4 {
+		t.Errorf("wrong statistics : %+v", stats)
+	}
+
+	//An OPTIONS request does not give the object
+	c.ObjectExistsAt(c.URL + "/api/racks/R2")
+	c.GetRack("R2")
+	if *fetches != 4 {
+		t.Errorf("the rack should be fetched after its existence, got %d requests", *fetches)
+	}
+
+	c.Cache.Clear()
+	c.GetRack("R1")
+	if *fetches != 5 {
+		t.Errorf("the rack should be fetched again after a clear, got %d requests", *fetches)
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	c, fetches := cachedClient(t, time.Minute)
+	fetchAll := func() {
+		c.GetRack("R1")
+		c.GetRack("R2")
+		c.ObjectAt(c.URL + "/api/rooms/RO/racks/R1")
+		c.ChildrenAt(c.URL+"/api/rooms/RO", "racks")
+	}
+	fetchAll()
+	if *fetches != 4 {
+		t.Fatalf("expected 4 requests, got %d", *fetches)
+	}
+
+	//The updated rack and the lists are fetched again, not the other rack
+	c.UpdateObject("rack", "R1", map[string]interface{}{"name": "R1",
+		"attributes": map[string]interface{}{"color": "red"}}, false)
+	fetchAll()
+	if *fetches != 7 {
+		t.Errorf("expected 3 more requests after an update, got %d", *fetches-4)
+	}
+
+	//The paths of the descendants of a renamed object change
+	c.UpdateObject("rack", "R1", map[string]interface{}{"name": "R3"}, false)
+	fetchAll()
+	if *fetches != 11 {
+		t.Errorf("everything should be fetched again after a rename, got %d", *fetches-7)
+	}
+
+	c.DeleteObject("rack", "R2")
+	fetchAll()
+	if *fetches != 15 {
+		t.Errorf("everything should be fetched again after a delete, got %d", *fetches-11)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	c, fetches := cachedClient(t, 10*time.Millisecond)
+	c.GetRack("R1")
+	c.GetRack("R1")
+	time.Sleep(20 * time.Millisecond)
+	c.GetRack("R1")
+	if *fetches != 2 {
+		t.Errorf("the rack should be fetched again when it expires, got %d requests", *fetches)
+	}
+
+	c, fetches = cachedClient(t, 0)
+	c.GetRack("R1")
+	c.GetRack("R1")
+	if *fetches != 2 || c.Cache.Stats().Entries != 0 {
+		t.Errorf("nothing should be kept without a TTL")
+	}
+}
@@ -0,0 +1,360 @@
+package api
+
+//This package sends the requests of the shell to the
+//OGrEE API and decodes its responses into typed values
+
+import (
+	"cli/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+)
+
+type Client struct {
+	URL   string //of the API, without the /api suffix
+	Key   string
+	Cache *Cache //of the answers of the API, none if nil
+
+	ctx context.Context //of the requests, the one of the command if nil
+}
+
+func NewClient(apiURL, key string) *Client {
+	return &Client{URL: apiURL, Key: key}
+}
+
+// Returns a copy of the client whose requests are canceled with ctx,
+// it shares the cache of the client
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
+
+// Responses of the API, data holds the objects
+type envelope struct {
+	Status  *bool           `json:"status"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Sends a request, unless its answer is in the cache, and returns the
+// body of the response, the statuses other than 2xx are returned as *Error
+func (c *Client) send(method, URL string, body map[string]interface{}) ([]byte, *envelope, error) {
+	if c.Cache == nil {
+		return c.request(method, URL, body)
+	}
+	if method != http.MethodGet && method != http.MethodOptions {
+		b, env, err := c.request(method, URL, body)
+		if err == nil {
+			id := ""
+			if method != http.MethodPost {
+				id = path.Base(URL)
+			}
+			c.Cache.invalidate(method, id, body)
+		}
+		return b, env, err
+	}
+	if entry, ok := c.Cache.get(method, URL); ok {
+		return entry.body, entry.env, entry.err
+	}
+	b, env, err := c.request(method, URL, body)
+	c.Cache.put(method, URL, b, env, err)
+	return b, env, err
+}
+
+// Sends a request without the cache
+func (c *Client) request(method, URL string, body map[string]interface{}) ([]byte, *envelope, error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = models.Context()
+	}
+	resp, err := models.SendContext(ctx, method, URL, c.Key, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, &ResponseError{method, URL, err}
+	}
+	env := &envelope{}
+	if json.Unmarshal(b, env) != nil {
+		//No body, or not one of the API
+		env = nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 ||
+		env != nil && env.Status != nil && !*env.Status {
+		apiErr := &Error{Method: method, URL: URL, StatusCode: resp.StatusCode}
+		if env != nil {
+			apiErr.Message = env.Message
+		}
+		return nil, nil, apiErr
+	}
+	return b, env, nil
+}
+
+// Sends a request and decodes the data of the response in out
+func (c *Client) do(method, URL string, body map[string]interface{}, out interface{}) error {
+	_, env, err := c.send(method, URL, body)
+	if err != nil || out == nil {
+		return err
+	}
+	if env == nil || len(env.Data) == 0 || string(env.Data) == "null" {
+		return &ResponseError{method, URL, fmt.Errorf("no data")}
+	}
+	if err := json.Unmarshal(env.Data, out); err != nil {
+		return &ResponseError{method, URL, err}
+	}
+	return nil
+}
+
+func (c *Client) entityURL(entity string) string {
+	return c.URL + "/api/" + entity + "s"
+}
+
+// Returns the object at the URL of its path in the hierarchy,
+// such as .../api/tenants/T/sites/S
+func (c *Client) ObjectAt(URL string) (*Object, error) {
+	obj := &Object{}
+	if err := c.do(http.MethodGet, URL, nil, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Tells whether an object exists at the URL of its path
+func (c *Client) ObjectExistsAt(URL string) (bool, error) {
+	_, _, err := c.send(http.MethodOptions, URL, nil)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Returns the objects of an entity under the object at the given URL,
+// entities is the plural name of the entity (racks, devices...)
+func (c *Client) ChildrenAt(URL, entities string) ([]*Object, error) {
+	return c.ObjectsAt(URL + "/" + entities)
+}
+
+// Returns the objects at the URL of a collection, such
+// as .../api/tenants/T/sites or .../api/obj-templates
+func (c *Client) ObjectsAt(URL string) ([]*Object, error) {
+	var data struct {
+		Objects []*Object `json:"objects"`
+	}
+	if err := c.do(http.MethodGet, URL, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Objects, nil
+}
+
+// Returns the templates at the URL of a collection, such as .../api/obj-templates
+func (c *Client) TemplatesAt(URL string) ([]*Template, error) {
+	var data struct {
+		Objects []json.RawMessage `json:"objects"`
+	}
+	if err := c.do(http.MethodGet, URL, nil, &data); err != nil {
+		return nil, err
+	}
+	templates := make([]*Template, len(data.Objects))
+	for i, b := range data.Objects {
+		templates[i] = &Template{}
+		if err := decodeWithFields(b, templates[i], &templates[i].fields); err != nil {
+			return nil, &ResponseError{http.MethodGet, URL, err}
+		}
+	}
+	return templates, nil
+}
+
+func (c *Client) CreateObject(entity string, data map[string]interface{}) (*Object, error) {
+	obj := &Object{}
+	if err := c.do(http.MethodPost, c.entityURL(entity), data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// Checks the object without creating it,
+// returns the message of the API
+func (c *Client) ValidateObject(entity string, data map[string]interface{}) (string, error) {
+	_, env, err := c.request(http.MethodPost, c.URL+"/api/validate/"+entity+"s", data)
+	if err != nil {
+		return "", err
+	}
+	if env == nil {
+		return "", nil
+	}
+	return env.Message, nil
+}
+
+// Changes the given fields of the object,
+// or replaces the whole object if replace is true
+func (c *Client) UpdateObject(entity, id string, data map[string]interface{}, replace bool) (*Object, error) {
+	method := http.MethodPatch
+	if replace {
+		method = http.MethodPut
+	}
+	obj := &Object{}
+	if err := c.do(method, c.entityURL(entity)+"/"+id, data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (c *Client) DeleteObject(entity, id string) error {
+	return c.do(http.MethodDelete, c.entityURL(entity)+"/"+id, nil, nil)
+}
+
+// Returns the objects of an entity whose fields have the given values
+func (c *Client) SearchObjects(entity string, query url.Values) ([]*Object, error) {
+	var data struct {
+		Objects []*Object `json:"objects"`
+	}
+	URL := c.entityURL(entity) + "?" + query.Encode()
+	if err := c.do(http.MethodGet, URL, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Objects, nil
+}
+
+// Returns the object with its descendants down to the given
+// depth, all of them if it is negative, in the Children field
+func (c *Client) Hierarchy(entity, id string, depth int) (*Object, error) {
+	obj := &Object{}
+	URL := c.entityURL(entity) + "/" + id + "/all"
+	if depth >= 0 {
+		URL += "?limit=" + strconv.Itoa(depth)
+	}
+	if err := c.do(http.MethodGet, URL, nil, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func get[T any](c *Client, entities, id string) (*T, error) {
+	obj := new(T)
+	if err := c.do(http.MethodGet, c.URL+"/api/"+entities+"/"+id, nil, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (c *Client) GetTenant(name string) (*Tenant, error) {
+	return get[Tenant](c, "tenants", name)
+}
+
+func (c *Client) GetSite(id string) (*Site, error) {
+	return get[Site](c, "sites", id)
+}
+
+func (c *Client) GetBuilding(id string) (*Building, error) {
+	return get[Building](c, "buildings", id)
+}
+
+func (c *Client) GetRoom(id string) (*Room, error) {
+	return get[Room](c, "rooms", id)
+}
+
+func (c *Client) GetRack(id string) (*Rack, error) {
+	return get[Rack](c, "racks", id)
+}
+
+func (c *Client) GetDevice(id string) (*Device, error) {
+	return get[Device](c, "devices", id)
+}
+
+func (c *Client) GetGroup(id string) (*Group, error) {
+	return get[Group](c, "groups", id)
+}
+
+func (c *Client) GetCorridor(id string) (*Corridor, error) {
+	return get[Corridor](c, "corridors", id)
+}
+
+func (c *Client) GetStrayDevice(id string) (*StrayDevice, error) {
+	return get[StrayDevice](c, "stray-devices", id)
+}
+
+func (c *Client) GetStraySensor(id string) (*StraySensor, error) {
+	return get[StraySensor](c, "stray-sensors", id)
+}
+
+func (c *Client) GetObjTemplate(slug string) (*ObjTemplate, error) {
+	return get[ObjTemplate](c, "obj-templates", slug)
+}
+
+func (c *Client) GetRoomTemplate(slug string) (*RoomTemplate, error) {
+	return get[RoomTemplate](c, "room-templates", slug)
+}
+
+func (c *Client) GetBldgTemplate(slug string) (*BldgTemplate, error) {
+	return get[BldgTemplate](c, "bldg-templates", slug)
+}
+
+// Creates a template, its category tells which kind of template it is
+func (c *Client) CreateTemplate(data map[string]interface{}) error {
+	var entities string
+	switch data["category"] {
+	case "room":
+		entities = "room-templates"
+	case "bldg", "building":
+		entities = "bldg-templates"
+	case "rack", "device":
+		entities = "obj-templates"
+	default:
+		return fmt.Errorf("this template does not have a valid category. Please add a category attribute with a value of building or room or rack or device")
+	}
+	return c.do(http.MethodPost, c.URL+"/api/"+entities, data, nil)
+}
+
+func (c *Client) Version() (*Version, error) {
+	version := &Version{}
+	if err := c.do(http.MethodGet, c.URL+"/api/version", nil, version); err != nil {
+		return nil, err
+	}
+	return version, nil
+}
+
+// Returns the statistics of the API, such as the number of objects
+func (c *Client) Stats() (map[string]interface{}, error) {
+	b, _, err := c.request(http.MethodGet, c.URL+"/api/stats", nil)
+	if err != nil {
+		return nil, err
+	}
+	stats := map[string]interface{}{}
+	if err := json.Unmarshal(b, &stats); err != nil {
+		return nil, &ResponseError{http.MethodGet, c.URL + "/api/stats", err}
+	}
+	return stats, nil
+}
+
+// Creates the account of a user, returns its API key
+func (c *Client) CreateAccount(email, password string) (string, error) {
+	URL := c.URL + "/api"
+	b, _, err := c.request(http.MethodPost, URL,
+		map[string]interface{}{"email": email, "password": password})
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Account struct {
+			Token string `json:"token"`
+		} `json:"account"`
+	}
+	if err := json.Unmarshal(b, &resp); err != nil || resp.Account.Token == "" {
+		return "", &ResponseError{http.MethodPost, URL, fmt.Errorf("no token")}
+	}
+	return resp.Account.Token, nil
+}
+
+// Returns nil if the key of the client is accepted by the API
+func (c *Client) CheckKey() error {
+	_, _, err := c.request(http.MethodGet, c.URL+"/api/token/valid", nil)
+	return err
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient(server.URL, "key")
+}
+
+func TestGetObject(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/racks/R1" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":false,"message":"Nothing matches this request"}`))
+			return
+		}
+		w.Write([]byte(`{"status":true,"data":{"id":"R1","name":"R1","category":"rack",
+			"attributes":{"height":"47"},"newField":"kept"}}`))
+	})
+
+	rack, err := c.GetRack("R1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if rack.ID != "R1" || rack.Category != "rack" || rack.Attributes["height"] != "47" {
+		t.Errorf("the rack is not decoded: %+v", rack)
+	}
+	if rack.Fields()["newField"] != "kept" {
+		t.Errorf("the unknown fields should be kept")
+	}
+
+	_, err = c.GetRack("R2")
+	if !IsNotFound(err) {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	if err.Error() != "Nothing matches this request" {
+		t.Errorf("the message of the API should be the error, got %q", err.Error())
+	}
+}
+
+func TestInvalidResponse(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/devices/D1":
+			//The data of the API is not an object
+			w.Write([]byte(`{"status":true,"data":["D1"]}`))
+		case "/api/devices/D2":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`not json`))
+		default:
+			w.Write([]byte(`{"status":true}`))
+		}
+	})
+
+	var respErr *ResponseError
+	if _, err := c.GetDevice("D1"); !errors.As(err, &respErr) {
+		t.Errorf("expected a response error, got %v", err)
+	}
+	if _, err := c.GetDevice("D3"); !errors.As(err, &respErr) {
+		t.Errorf("expected a response error when there is no data, got %v", err)
+	}
+	var apiErr *Error
+	_, err := c.GetDevice("D2")
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected an API error, got %v", err)
+	}
+	if apiErr.Message != "" {
+		t.Errorf("the error should not have a message, got %q", apiErr.Message)
+	}
+}
+
+func TestHierarchyAndTemplates(t *testing.T) {
+	c := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/rooms/R1/all":
+			if r.URL.Query().Get("limit") != "1" {
+				t.Errorf("the depth should be sent as limit")
+			}
+			w.Write([]byte(`{"status":true,"data":{"id":"R1","category":"room",
+				"children":[{"id":"R1.A01","category":"rack"}]}}`))
+		case "/api/obj-templates/ibm-ns1200":
+			w.Write([]byte(`{"status":true,"data":{"slug":"ibm-ns1200",
+				"category":"device","sizeWDHmm":[482,800,44]}}`))
+		case "/api/room-templates":
+			if r.Method != http.MethodPost {
+				t.Errorf("templates should be posted")
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"status":true,"data":{}}`))
+		case "/api/bldg-templates":
+			w.Write([]byte(`{"status":true,"data":{"objects":[
+				{"slug":"B1","sizeWDHm":[10,10,5]},{"slug":"B2","description":"main"}]}}`))
+		}
+	})
+
+	room, err := c.Hierarchy("room", "R1", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(room.Children) != 1 || room.Children[0].Fields()["id"] != "R1.A01" {
+		t.Errorf("the children are not decoded: %+v", room.Children)
+	}
+
+	tmpl, err := c.GetObjTemplate("ibm-ns1200")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if tmpl.Slug != "ibm-ns1200" || len(tmpl.SizeWDHmm) != 3 || tmpl.Fields()["category"] != "device" {
+		t.Errorf("the template is not decoded: %+v", tmpl)
+	}
+
+	templates, err := c.TemplatesAt(c.URL + "/api/bldg-templates")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(templates) != 2 || templates[1].Slug != "B2" || templates[0].Fields()["sizeWDHm"] == nil {
+		t.Errorf("the templates are not decoded: %+v", templates)
+	}
+
+	if err := c.CreateTemplate(map[string]interface{}{"category": "room"}); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := c.CreateTemplate(map[string]interface{}{"category": "tenant"}); err == nil {
+		t.Errorf("a template without a valid category should not be sent")
+	}
+}
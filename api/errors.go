@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// An error answered by the API
+type Error struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string //given by the API, may be empty
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s %s : %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// The response of the API cannot be read or does not have
+// the expected content, the shell or the API may be outdated
+type ResponseError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("invalid response from the API to %s %s : %s", e.Method, e.URL, e.Err.Error())
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
@@ -0,0 +1,381 @@
+package mock
+
+//This package is an OGrEE API built into the shell, it answers
+//its requests without the network, for offline use and for the tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Server struct {
+	mu    sync.Mutex
+	file  string //where the objects are saved, kept in memory if empty
+	store *store
+}
+
+// Returns a server whose objects are read from and saved to the
+// given JSON file, created at the first change if it does not exist
+func NewServer(file string) (*Server, error) {
+	s := &Server{file: file, store: newStore()}
+	if file != "" {
+		var err error
+		if s.store, err = loadStore(file); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Response of the API, with its status code
+type response struct {
+	code int
+	body map[string]interface{} //no body if nil
+}
+
+func fail(code int, message string) response {
+	return response{code, map[string]interface{}{"status": false, "message": message}}
+}
+
+func success(code int, message string, data interface{}) response {
+	body := map[string]interface{}{"status": true, "message": message}
+	if data != nil {
+		body["data"] = data
+	}
+	return response{code, body}
+}
+
+func notFound() response {
+	return fail(http.StatusNotFound, "Nothing matches this request")
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := s.handle(r)
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.code)
+	if resp.body != nil {
+		json.NewEncoder(w).Encode(resp.body)
+	}
+}
+
+// Answers the requests sent to the mock:// URLs,
+// the server is then used as the transport of the client
+func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	recorder := httptest.NewRecorder()
+	s.ServeHTTP(recorder, req)
+	return recorder.Result(), nil
+}
+
+func (s *Server) handle(r *http.Request) response {
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if segments[0] != "api" {
+		return notFound()
+	}
+	segments = segments[1:]
+	var body map[string]interface{}
+	if r.Body != nil && (r.Method == http.MethodPost ||
+		r.Method == http.MethodPut || r.Method == http.MethodPatch) {
+		if json.NewDecoder(r.Body).Decode(&body) != nil || body == nil {
+			return fail(http.StatusBadRequest, "Invalid request body")
+		}
+	}
+
+	switch {
+	case len(segments) == 0:
+		if r.Method != http.MethodPost {
+			return notFound()
+		}
+		//Any account is created, with a key that is not checked
+		email, _ := body["email"].(string)
+		return response{http.StatusCreated, map[string]interface{}{"status": true,
+			"message": "Account has been created",
+			"account": map[string]interface{}{"email": email, "token": "offline"}}}
+	case strings.Join(segments, "/") == "token/valid":
+		return success(http.StatusOK, "working", nil)
+	case segments[0] == "version":
+		return success(http.StatusOK, "", map[string]interface{}{
+			"BuildDate": "", "BuildTree": "mock", "BuildHash": "", "CommitDate": ""})
+	case segments[0] == "stats":
+		return response{http.StatusOK, s.stats()}
+	case segments[0] == "validate" && len(segments) == 2 && r.Method == http.MethodPost:
+		return s.validate(segments[1], body)
+	case isTemplateCollection(segments[0]):
+		return s.handleTemplates(r.Method, segments, body)
+	}
+	return s.handleObjects(r.Method, segments, r.URL.Query(), body)
+}
+
+func (s *Server) stats() map[string]interface{} {
+	stats := map[string]interface{}{"Number of Hierarchal Objects": len(s.store.Objects)}
+	for category := range parentCategories {
+		count := 0
+		for _, obj := range s.store.Objects {
+			if c, _ := obj["category"].(string); normalize(c) == category {
+				count++
+			}
+		}
+		stats["Number of "+category+"s"] = count
+	}
+	return stats
+}
+
+func (s *Server) validate(collection string, body map[string]interface{}) response {
+	obj := newObject(collection, body)
+	if message := s.store.check(obj); message != "" {
+		return fail(http.StatusBadRequest, message)
+	}
+	return success(http.StatusOK, "This object can be created", nil)
+}
+
+// The object to create from the body of a request,
+// its category is the one of its collection by default
+func newObject(collection string, body map[string]interface{}) object {
+	obj := object{}
+	for k, v := range body {
+		obj[k] = v
+	}
+	if _, ok := obj["category"]; !ok {
+		obj["category"] = categoryOf(collection)
+	}
+	return obj
+}
+
+// Objects are found by their id, /api/racks/{id}, or by the names
+// of their path from a root object, /api/tenants/T/sites/S/buildings/B
+func (s *Server) handleObjects(method string, segments []string, query url.Values,
+	body map[string]interface{}) response {
+	collection := segments[0]
+	if len(segments) == 1 {
+		switch method {
+		case http.MethodGet:
+			return s.search(collection, query)
+		case http.MethodPost:
+			return s.create(collection, body)
+		case http.MethodOptions:
+			return success(http.StatusOK, "", nil)
+		}
+		return fail(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	obj := s.store.find(collection, segments[1])
+	i := 2
+	for ; obj != nil && i+1 < len(segments); i += 2 {
+		collection = segments[i]
+		obj = s.store.child(obj, collection, segments[i+1])
+	}
+	if obj == nil {
+		return notFound()
+	}
+
+	if i < len(segments) {
+		//Children of the object
+		if method != http.MethodGet && method != http.MethodOptions {
+			return fail(http.StatusMethodNotAllowed, "Method not allowed")
+		}
+		if segments[i] == "all" {
+			depth := -1
+			if limit, err := strconv.Atoi(query.Get("limit")); err == nil {
+				depth = limit
+			}
+			return success(http.StatusOK, "successfully got object", s.store.hierarchy(obj, depth))
+		}
+		objs := []interface{}{}
+		for _, child := range s.store.children(obj) {
+			if inCollection(child, segments[i]) {
+				objs = append(objs, child)
+			}
+		}
+		return success(http.StatusOK, "successfully got objects", map[string]interface{}{"objects": objs})
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodOptions:
+		return success(http.StatusOK, "successfully got object", obj)
+	case http.MethodPut, http.MethodPatch:
+		if len(segments) != 2 {
+			break
+		}
+		return s.update(obj, body, method == http.MethodPut)
+	case http.MethodDelete:
+		if len(segments) != 2 {
+			break
+		}
+		s.store.delete(obj)
+		if err := s.save(); err != nil {
+			return fail(http.StatusInternalServerError, err.Error())
+		}
+		return response{http.StatusNoContent, nil}
+	}
+	return fail(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func (s *Server) save() error {
+	return s.store.save(s.file)
+}
+
+func now() string {
+	return time.Now().Format(time.RFC3339)
+}
+
+// Returns the objects of the collection whose fields or
+// attributes have the values of the query
+func (s *Server) search(collection string, query url.Values) response {
+	objs := []interface{}{}
+	for _, obj := range s.store.filter(func(obj object) bool { return inCollection(obj, collection) }) {
+		attrs, _ := obj["attributes"].(map[string]interface{})
+		matches := true
+		for key := range query {
+			value, ok := obj[key]
+			if !ok {
+				value, ok = attrs[key]
+			}
+			if !ok || stringify(value) != query.Get(key) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			objs = append(objs, obj)
+		}
+	}
+	return success(http.StatusOK, "successfully got query for "+categoryOf(collection),
+		map[string]interface{}{"objects": objs})
+}
+
+func stringify(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+func (s *Server) create(collection string, body map[string]interface{}) response {
+	obj := newObject(collection, body)
+	if message := s.store.check(obj); message != "" {
+		return fail(http.StatusBadRequest, message)
+	}
+	obj["id"] = s.store.newID()
+	obj["createdDate"] = now()
+	obj["lastUpdated"] = obj["createdDate"]
+	s.store.Objects[obj["id"].(string)] = obj
+	if err := s.save(); err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	return success(http.StatusCreated, "successfully created "+obj["category"].(string), obj)
+}
+
+// Replaces the object by the body, or changes the fields of the body only,
+// the attributes are merged. The id and the category cannot change
+func (s *Server) update(obj object, body map[string]interface{}, replace bool) response {
+	updated := object{}
+	if !replace {
+		for k, v := range obj {
+			updated[k] = v
+		}
+	}
+	for k, v := range body {
+		attrs, isMap := v.(map[string]interface{})
+		oldAttrs, wasMap := updated[k].(map[string]interface{})
+		if k == "attributes" && isMap && wasMap {
+			merged := map[string]interface{}{}
+			for name, value := range oldAttrs {
+				merged[name] = value
+			}
+			for name, value := range attrs {
+				merged[name] = value
+			}
+			v = merged
+		}
+		updated[k] = v
+	}
+	for _, k := range []string{"id", "category", "createdDate"} {
+		updated[k] = obj[k]
+	}
+	if message := s.store.check(updated); message != "" {
+		return fail(http.StatusBadRequest, message)
+	}
+	updated["lastUpdated"] = now()
+	s.store.Objects[obj["id"].(string)] = updated
+	if err := s.save(); err != nil {
+		return fail(http.StatusInternalServerError, err.Error())
+	}
+	return success(http.StatusOK, "successfully updated "+obj["category"].(string), updated)
+}
+
+// The templates are found by their slug, /api/obj-templates/{slug}
+func (s *Server) handleTemplates(method string, segments []string, body map[string]interface{}) response {
+	collection := normalize(segments[0])
+	templates := s.store.Templates[collection]
+	if len(segments) == 1 {
+		switch method {
+		case http.MethodGet:
+			objs := []interface{}{}
+			for _, slug := range sortedKeys(templates) {
+				objs = append(objs, templates[slug])
+			}
+			return success(http.StatusOK, "successfully got templates", map[string]interface{}{"objects": objs})
+		case http.MethodOptions:
+			return success(http.StatusOK, "", nil)
+		case http.MethodPost:
+			slug, _ := body["slug"].(string)
+			if slug == "" {
+				return fail(http.StatusBadRequest, "Field(s) missing: slug is required")
+			}
+			if _, ok := templates[slug]; ok {
+				return fail(http.StatusBadRequest, "Error while creating template: Duplicates not allowed")
+			}
+			if templates == nil {
+				templates = map[string]object{}
+				s.store.Templates[collection] = templates
+			}
+			templates[slug] = body
+			if err := s.save(); err != nil {
+				return fail(http.StatusInternalServerError, err.Error())
+			}
+			return success(http.StatusCreated, "successfully created template", body)
+		}
+		return fail(http.StatusMethodNotAllowed, "Method not allowed")
+	}
+
+	tmpl, ok := templates[segments[1]]
+	if len(segments) != 2 || !ok {
+		return notFound()
+	}
+	switch method {
+	case http.MethodGet, http.MethodOptions:
+		return success(http.StatusOK, "successfully got template", tmpl)
+	case http.MethodDelete:
+		delete(templates, segments[1])
+		if err := s.save(); err != nil {
+			return fail(http.StatusInternalServerError, err.Error())
+		}
+		return response{http.StatusNoContent, nil}
+	}
+	return fail(http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+func sortedKeys(m map[string]object) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,116 @@
+package mock
+
+import (
+	"cli/api"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func newTestClient(t *testing.T, file string) *api.Client {
+	server, err := NewServer(file)
+	if err != nil {
+		t.Fatalf("cannot start the server : %s", err)
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return api.NewClient(httpServer.URL, "")
+}
+
+// Creates a tenant with a site and returns the site
+func createSite(t *testing.T, c *api.Client) *api.Object {
+	tenant, err := c.CreateObject("tenant", map[string]interface{}{"name": "DEMO", "category": "tenant"})
+	if err != nil {
+		t.Fatalf("cannot create the tenant : %s", err)
+	}
+	site, err := c.CreateObject("site", map[string]interface{}{"name": "ALPHA",
+		"category": "site", "parentId": tenant.ID, "attributes": map[string]interface{}{"color": "red"}})
+	if err != nil {
+		t.Fatalf("cannot create the site : %s", err)
+	}
+	return site
+}
+
+func TestObjects(t *testing.T) {
+	c := newTestClient(t, "")
+	site := createSite(t, c)
+
+	obj, err := c.ObjectAt(c.URL + "/api/tenants/DEMO/sites/ALPHA")
+	if err != nil || obj.ID != site.ID {
+		t.Fatalf("the site should be found by its path, got %v", err)
+	}
+	if exists, _ := c.ObjectExistsAt(c.URL + "/api/tenants/DEMO/sites/BETA"); exists {
+		t.Errorf("the site BETA should not exist")
+	}
+	if _, err := c.CreateObject("site", map[string]interface{}{"name": "ALPHA",
+		"category": "site", "parentId": site.ParentID}); err == nil {
+		t.Errorf("the duplicates should be refused")
+	}
+	if _, err := c.CreateObject("building", map[string]interface{}{"name": "B",
+		"category": "building", "parentId": "unknown"}); err == nil {
+		t.Errorf("the objects without parent should be refused")
+	}
+	if _, err := c.ValidateObject("room", map[string]interface{}{"name": "R",
+		"category": "room", "parentId": site.ID}); err == nil {
+		t.Errorf("a room should not be validated under a site")
+	}
+
+	updated, err := c.UpdateObject("site", site.ID, map[string]interface{}{
+		"attributes": map[string]interface{}{"orientation": "NW"}}, false)
+	if err != nil {
+		t.Fatalf("cannot update the site : %s", err)
+	}
+	if updated.Attributes["color"] != "red" || updated.Attributes["orientation"] != "NW" {
+		t.Errorf("the attributes should be merged : %v", updated.Attributes)
+	}
+
+	found, err := c.SearchObjects("site", url.Values{"orientation": {"NW"}})
+	if err != nil || len(found) != 1 {
+		t.Errorf("the site should be found by its attributes, got %v %v", found, err)
+	}
+
+	tenant, err := c.Hierarchy("tenant", "DEMO", -1)
+	if err != nil || len(tenant.Children) != 1 || tenant.Children[0].Name != "ALPHA" {
+		t.Fatalf("wrong hierarchy : %v %v", tenant, err)
+	}
+	if err := c.DeleteObject("tenant", tenant.ID); err != nil {
+		t.Fatalf("cannot delete the tenant : %s", err)
+	}
+	if _, err := c.GetSite(site.ID); !api.IsNotFound(err) {
+		t.Errorf("the children of a deleted object should be deleted, got %v", err)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	c := newTestClient(t, "")
+	tmpl := map[string]interface{}{"slug": "ibm-ns1200", "category": "device",
+		"sizeWDHmm": []interface{}{482, 800, 44}}
+	if err := c.CreateTemplate(tmpl); err != nil {
+		t.Fatalf("cannot create the template : %s", err)
+	}
+	if err := c.CreateTemplate(tmpl); err == nil {
+		t.Errorf("the duplicates should be refused")
+	}
+	got, err := c.GetObjTemplate("ibm-ns1200")
+	if err != nil || len(got.SizeWDHmm) != 3 {
+		t.Errorf("wrong template : %v %v", got, err)
+	}
+}
+
+func TestFileStorage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "objects.json")
+	createSite(t, newTestClient(t, file))
+
+	c := newTestClient(t, file)
+	if _, err := c.ObjectAt(c.URL + "/api/tenants/DEMO/sites/ALPHA"); err != nil {
+		t.Errorf("the objects should be read from the file : %s", err)
+	}
+	other, err := c.CreateObject("tenant", map[string]interface{}{"name": "OTHER", "category": "tenant"})
+	if err != nil {
+		t.Fatalf("cannot create a tenant : %s", err)
+	}
+	if demo, _ := c.GetTenant("DEMO"); demo == nil || demo.ID == other.ID {
+		t.Errorf("the ids should not be reused")
+	}
+}
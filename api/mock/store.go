@@ -0,0 +1,217 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+type object = map[string]interface{}
+
+// Objects of the API, saved as is in the JSON file of the server
+type store struct {
+	Objects   map[string]object            `json:"objects"`   //by id
+	Templates map[string]map[string]object `json:"templates"` //by collection then slug
+	LastID    int                          `json:"lastId"`
+}
+
+func newStore() *store {
+	return &store{Objects: map[string]object{}, Templates: map[string]map[string]object{}}
+}
+
+// Reads the store saved in file, an empty one if it does not exist yet
+func loadStore(file string) (*store, error) {
+	s := newStore()
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("cannot read the objects of %s : %s", file, err.Error())
+	}
+	if s.Objects == nil {
+		s.Objects = map[string]object{}
+	}
+	if s.Templates == nil {
+		s.Templates = map[string]map[string]object{}
+	}
+	return s, nil
+}
+
+func (s *store) save(file string) error {
+	if file == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, b, 0644)
+}
+
+// The ids look like those of the API, they follow the creation order
+func (s *store) newID() string {
+	s.LastID++
+	return fmt.Sprintf("%024x", s.LastID)
+}
+
+// Parents allowed for each category, the objects
+// of the categories without parent are at the root
+var parentCategories = map[string][]string{
+	"tenant":       nil,
+	"site":         {"tenant"},
+	"building":     {"site"},
+	"room":         {"building"},
+	"rack":         {"room"},
+	"device":       {"rack", "device"},
+	"ac":           {"room"},
+	"panel":        {"room"},
+	"cabinet":      {"room"},
+	"corridor":     {"room"},
+	"group":        {"room", "rack"},
+	"sensor":       {"room", "rack", "device"},
+	"domain":       {"domain"},
+	"stray_device": {"stray_device"},
+	"stray_sensor": {"stray_sensor"},
+}
+
+// The collections are hyphenated in the URLs and the categories
+// use underscores, both are accepted : stray-devices, stray_device
+func normalize(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func categoryOf(collection string) string {
+	return strings.TrimSuffix(normalize(collection), "s")
+}
+
+// Tells whether an object belongs to a collection, the children
+// of the stray objects are in the collection of their kind
+func inCollection(obj object, collection string) bool {
+	category, _ := obj["category"].(string)
+	category = normalize(category)
+	return category == categoryOf(collection) || category == "stray_"+categoryOf(collection)
+}
+
+func isTemplateCollection(collection string) bool {
+	return strings.HasSuffix(normalize(collection), "_templates")
+}
+
+// Returns the objects matching f, in their creation order
+func (s *store) filter(f func(obj object) bool) []object {
+	ids := []string{}
+	for id, obj := range s.Objects {
+		if f(obj) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	objs := []object{}
+	for _, id := range ids {
+		objs = append(objs, s.Objects[id])
+	}
+	return objs
+}
+
+// Finds an object of a collection by its id, or by its name
+// for the objects at the root such as the tenants
+func (s *store) find(collection, key string) object {
+	if obj, ok := s.Objects[key]; ok && inCollection(obj, collection) {
+		return obj
+	}
+	found := s.filter(func(obj object) bool {
+		return inCollection(obj, collection) && obj["name"] == key
+	})
+	for _, obj := range found {
+		if parentID, _ := obj["parentId"].(string); parentID == "" {
+			return obj
+		}
+	}
+	if len(found) == 1 {
+		return found[0]
+	}
+	return nil
+}
+
+func (s *store) children(parent object) []object {
+	return s.filter(func(obj object) bool {
+		return obj["parentId"] != nil && obj["parentId"] == parent["id"]
+	})
+}
+
+func (s *store) child(parent object, collection, name string) object {
+	for _, obj := range s.children(parent) {
+		if obj["name"] == name && inCollection(obj, collection) {
+			return obj
+		}
+	}
+	return nil
+}
+
+// Returns a copy of the object with its descendants
+// down to the given depth, all of them if it is negative
+func (s *store) hierarchy(obj object, depth int) object {
+	root := object{}
+	for k, v := range obj {
+		root[k] = v
+	}
+	if depth == 0 {
+		return root
+	}
+	children := []interface{}{}
+	for _, child := range s.children(obj) {
+		children = append(children, s.hierarchy(child, depth-1))
+	}
+	root["children"] = children
+	return root
+}
+
+func (s *store) delete(obj object) {
+	for _, child := range s.children(obj) {
+		s.delete(child)
+	}
+	delete(s.Objects, obj["id"].(string))
+}
+
+// Returns why the object cannot be saved, or an empty string
+func (s *store) check(obj object) string {
+	name, _ := obj["name"].(string)
+	category, _ := obj["category"].(string)
+	if name == "" || category == "" {
+		return "Field(s) missing: name and category are required"
+	}
+	parents, ok := parentCategories[normalize(category)]
+	if !ok {
+		return "Invalid category: " + category
+	}
+	parentID, _ := obj["parentId"].(string)
+	if parentID != "" || len(parents) > 0 && parents[0] != normalize(category) {
+		//The objects that may have a parent of their own category may be at the root
+		parent, ok := s.Objects[parentID]
+		if !ok {
+			return "ParentID should correspond to Existing ID"
+		}
+		parentCategory, _ := parent["category"].(string)
+		if !slices.Contains(parents, normalize(parentCategory)) {
+			return "The parent of a " + category + " cannot be a " + parentCategory
+		}
+	}
+	for _, other := range s.Objects {
+		otherParentID, _ := other["parentId"].(string)
+		otherCategory, _ := other["category"].(string)
+		if other["id"] != obj["id"] && other["name"] == name && otherParentID == parentID &&
+			(parentID != "" || normalize(category) == normalize(otherCategory)) {
+			return "Error while creating " + category + ": Duplicates not allowed"
+		}
+	}
+	return ""
+}
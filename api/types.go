@@ -0,0 +1,108 @@
+package api
+
+import "encoding/json"
+
+// Fields shared by the objects of all the entities. The attributes
+// are free, the users may add their own to any object
+type Object struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Category    string                 `json:"category"`
+	ParentID    string                 `json:"parentId,omitempty"`
+	Domain      string                 `json:"domain,omitempty"`
+	Description []string               `json:"description,omitempty"`
+	Attributes  map[string]interface{} `json:"attributes,omitempty"`
+	Children    []*Object              `json:"children,omitempty"`
+
+	fields map[string]interface{} //as received from the API
+}
+
+// Decodes v, which has no UnmarshalJSON method, and keeps all the fields
+func decodeWithFields(b []byte, v interface{}, fields *map[string]interface{}) error {
+	if err := json.Unmarshal(b, v); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, fields)
+}
+
+func (o *Object) UnmarshalJSON(b []byte) error {
+	type plain Object
+	return decodeWithFields(b, (*plain)(o), &o.fields)
+}
+
+// Returns all the fields of the object as received from the API,
+// those of the API version the shell does not know about included
+func (o *Object) Fields() map[string]interface{} {
+	if o.fields == nil {
+		b, _ := json.Marshal(o)
+		json.Unmarshal(b, &o.fields)
+	}
+	return o.fields
+}
+
+type Tenant struct{ Object }
+type Site struct{ Object }
+type Building struct{ Object }
+type Room struct{ Object }
+type Rack struct{ Object }
+type Device struct{ Object }
+type Group struct{ Object }
+type Corridor struct{ Object }
+type StrayDevice struct{ Object }
+type StraySensor struct{ Object }
+
+// Fields shared by the templates, they are named by their slug
+type Template struct {
+	Slug        string `json:"slug"`
+	Category    string `json:"category,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	fields map[string]interface{}
+}
+
+// Returns all the fields of the template as received from the API
+func (t *Template) Fields() map[string]interface{} {
+	return t.fields
+}
+
+// Template of the racks and the devices
+type ObjTemplate struct {
+	Template
+	SizeWDHmm  []float64              `json:"sizeWDHmm"`
+	FbxModel   string                 `json:"fbxModel,omitempty"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+func (t *ObjTemplate) UnmarshalJSON(b []byte) error {
+	type plain ObjTemplate
+	return decodeWithFields(b, (*plain)(t), &t.fields)
+}
+
+type RoomTemplate struct {
+	Template
+	SizeWDHm    []float64 `json:"sizeWDHm"`
+	Orientation string    `json:"orientation,omitempty"`
+}
+
+func (t *RoomTemplate) UnmarshalJSON(b []byte) error {
+	type plain RoomTemplate
+	return decodeWithFields(b, (*plain)(t), &t.fields)
+}
+
+type BldgTemplate struct {
+	Template
+	SizeWDHm []float64 `json:"sizeWDHm"`
+}
+
+func (t *BldgTemplate) UnmarshalJSON(b []byte) error {
+	type plain BldgTemplate
+	return decodeWithFields(b, (*plain)(t), &t.fields)
+}
+
+// Build information of the API
+type Version struct {
+	BuildDate  string
+	BuildTree  string
+	BuildHash  string
+	CommitDate string
+}
@@ -6,12 +6,70 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
 var dynamicSymbolTable = make(map[string]interface{})
 var funcTable = make(map[string]interface{})
 
+// A scope holds the local variables of a function call, along with the
+// names the function declared as global
+type scope struct {
+	vars    map[string]interface{}
+	globals map[string]bool
+}
+
+// Stack of local scopes, one per function call being executed.
+// When empty, variables are read from and written to dynamicSymbolTable
+var scopeStack []*scope
+
+func pushScope() {
+	scopeStack = append(scopeStack, &scope{map[string]interface{}{}, map[string]bool{}})
+}
+
+func popScope() {
+	scopeStack = scopeStack[:len(scopeStack)-1]
+}
+
+func currentScope() *scope {
+	if len(scopeStack) == 0 {
+		return nil
+	}
+	return scopeStack[len(scopeStack)-1]
+}
+
+// Looks for a variable in the current local scope first,
+// then in the global symbol table
+func getVar(name string) (interface{}, bool) {
+	if s := currentScope(); s != nil && !s.globals[name] {
+		if val, ok := s.vars[name]; ok {
+			return val, true
+		}
+	}
+	val, ok := dynamicSymbolTable[name]
+	return val, ok
+}
+
+// Inside a function, variables are local unless declared global
+func setVar(name string, val interface{}) {
+	if s := currentScope(); s != nil && !s.globals[name] {
+		s.vars[name] = val
+		return
+	}
+	dynamicSymbolTable[name] = val
+}
+
+func unsetVar(name string) {
+	if s := currentScope(); s != nil && !s.globals[name] {
+		if _, ok := s.vars[name]; ok {
+			delete(s.vars, name)
+			return
+		}
+	}
+	delete(dynamicSymbolTable, name)
+}
+
 func GetFuncTable() map[string]interface{} {
 	return funcTable
 }
@@ -38,10 +96,16 @@ type ast struct {
 
 func (a *ast) execute() (interface{}, error) {
 	for i := range a.statements {
+		if err := checkInterrupt(); err != nil {
+			return nil, err
+		}
 		if a.statements[i] != nil {
 			_, err := a.statements[i].execute()
+			if err == nil {
+				err = checkInterrupt()
+			}
 			if err != nil {
-				return nil, err
+				return nil, interruptedIn(a.statements[i], err)
 			}
 		}
 	}
@@ -49,12 +113,13 @@ func (a *ast) execute() (interface{}, error) {
 }
 
 type funcDefNode struct {
-	name string
-	body node
+	name   string
+	params []string
+	body   node
 }
 
 func (n *funcDefNode) execute() (interface{}, error) {
-	funcTable[n.name] = n.body
+	funcTable[n.name] = n
 	if cmd.State.DebugLvl >= 3 {
 		println("New function ", n.name)
 	}
@@ -63,39 +128,121 @@ func (n *funcDefNode) execute() (interface{}, error) {
 
 type funcCallNode struct {
 	name string
+	args []node
 }
 
 func (n *funcCallNode) execute() (interface{}, error) {
 	val, ok := funcTable[n.name]
+	if builtin, isBuiltin := builtinFuncs[n.name]; !ok && isBuiltin {
+		return n.callBuiltin(builtin)
+	}
 	if !ok {
 		return nil, fmt.Errorf("undefined function %s", n.name)
 	}
-	body, ok := val.(node)
+	def, ok := val.(*funcDefNode)
 	if !ok {
 		return nil, fmt.Errorf("variable %s does not contain a function", n.name)
 	}
-	return body.execute()
+	if len(n.args) != len(def.params) {
+		return nil, fmt.Errorf("function %s expects %d argument(s), %d given",
+			n.name, len(def.params), len(n.args))
+	}
+	//Arguments are evaluated in the caller's scope
+	args := []interface{}{}
+	for _, arg := range n.args {
+		v, err := arg.execute()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	pushScope()
+	defer popScope()
+	for i, param := range def.params {
+		setVar(param, args[i])
+	}
+	val, err := def.body.execute()
+	switch err := err.(type) {
+	case *returnError:
+		return err.val, nil
+	case *breakError, *continueError:
+		//The loops of the caller are not stopped by the function
+		return nil, fmt.Errorf("%s in function %s", err.Error(), n.name)
+	}
+	return val, err
+}
+
+func (n *funcCallNode) callBuiltin(builtin builtinFunc) (interface{}, error) {
+	if err := builtin.checkArity(n.name, len(n.args)); err != nil {
+		return nil, err
+	}
+	args := []interface{}{}
+	for _, arg := range n.args {
+		v, err := arg.execute()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, v)
+	}
+	return builtin.call(args)
 }
 
-// At this time arrays are all []floats
+type globalNode struct {
+	names []string
+}
+
+func (n *globalNode) execute() (interface{}, error) {
+	s := currentScope()
+	if s == nil {
+		//Outside of a function every variable is already global
+		return nil, nil
+	}
+	for _, name := range n.names {
+		s.globals[name] = true
+		delete(s.vars, name)
+	}
+	return nil, nil
+}
+
+// Arrays of numbers are []float64, arrays of strings []string,
+// and the others, with units or mixed, []interface{}
 type arrNode struct {
 	nodes []node
 }
 
 func (n *arrNode) execute() (interface{}, error) {
 	var r []float64
+	var strs []string
+	elts := []interface{}{}
 	for i := range n.nodes {
 		v, err := n.nodes[i].execute()
 		if err != nil {
 			return nil, err
 		}
+		switch v := v.(type) {
+		case cmd.Quantity:
+			//The units are kept until the array is given to an object
+			elts = append(elts, v)
+			continue
+		case string:
+			strs = append(strs, v)
+			elts = append(elts, v)
+			continue
+		}
 		val, err := getFloat(v)
 		if err != nil {
-			return nil, fmt.Errorf("Array should contain floats")
+			return nil, fmt.Errorf("Array should contain numbers or strings")
 		}
 		r = append(r, val)
+		elts = append(elts, val)
+	}
+	switch len(elts) {
+	case len(r):
+		return r, nil
+	case len(strs):
+		return strs, nil
 	}
-	return r, nil
+	return elts, nil
 }
 
 type lenNode struct {
@@ -103,15 +250,21 @@ type lenNode struct {
 }
 
 func (n *lenNode) execute() (interface{}, error) {
-	val, ok := dynamicSymbolTable[n.variable]
+	val, ok := getVar(n.variable)
 	if !ok {
 		return nil, fmt.Errorf("Undefined variable %s", n.variable)
 	}
-	arr, ok := val.([]float64)
-	if !ok {
-		return nil, fmt.Errorf("Variable %s does not contain an array.", n.variable)
+	switch arr := val.(type) {
+	case []float64:
+		return len(arr), nil
+	case []string:
+		return len(arr), nil
+	case []interface{}:
+		return len(arr), nil
+	case map[string]interface{}:
+		return len(arr), nil
 	}
-	return len(arr), nil
+	return nil, fmt.Errorf("Variable %s does not contain an array.", n.variable)
 }
 
 type postObjNode struct {
@@ -170,6 +323,23 @@ type lsNode struct {
 }
 
 func (n *lsNode) execute() (interface{}, error) {
+	return n.list(true)
+}
+
+// The names of the objects listed
+func (n *lsNode) quietValue() (interface{}, error) {
+	objects, err := n.list(false)
+	if err != nil {
+		return nil, err
+	}
+	names := []interface{}{}
+	for _, obj := range objects {
+		names = append(names, objectName(obj))
+	}
+	return names, nil
+}
+
+func (n *lsNode) list(display bool) ([]map[string]interface{}, error) {
 	val, err := n.path.execute()
 	if err != nil {
 		return nil, err
@@ -178,7 +348,26 @@ func (n *lsNode) execute() (interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("Path should be a string")
 	}
-	return cmd.LS(path), nil
+	paths, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !display {
+		objects := []map[string]interface{}{}
+		for _, path := range paths {
+			objects = append(objects, cmd.LSObjects(path)...)
+		}
+		return objects, nil
+	}
+	if len(paths) == 1 {
+		return cmd.LS(paths[0]), nil
+	}
+	objects := []map[string]interface{}{}
+	for _, path := range paths {
+		println(path + ":")
+		objects = append(objects, cmd.LS(path)...)
+	}
+	return objects, nil
 }
 
 type lsAttrNode struct {
@@ -266,6 +455,54 @@ func (n *loadNode) execute() (interface{}, error) {
 	return nil, LoadFile(path)
 }
 
+type debugScriptNode struct {
+	path node
+}
+
+func (n *debugScriptNode) execute() (interface{}, error) {
+	val, err := n.path.execute()
+	if err != nil {
+		return nil, err
+	}
+	path, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("path should be a string")
+	}
+	return nil, DebugFile(path)
+}
+
+type saveSessionNode struct {
+	path node
+}
+
+func (n *saveSessionNode) execute() (interface{}, error) {
+	val, err := n.path.execute()
+	if err != nil {
+		return nil, err
+	}
+	path, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("path should be a string")
+	}
+	return nil, SaveSession(path)
+}
+
+type loadSessionNode struct {
+	path node
+}
+
+func (n *loadSessionNode) execute() (interface{}, error) {
+	val, err := n.path.execute()
+	if err != nil {
+		return nil, err
+	}
+	path, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("path should be a string")
+	}
+	return nil, LoadSession(path)
+}
+
 type loadTemplateNode struct {
 	path node
 }
@@ -298,8 +535,11 @@ func (n *printNode) execute() (interface{}, error) {
 	return cmd.Print([]interface{}{val}), nil
 }
 
+// Deletes the objects of a path, the user confirms the deletion
+// of several objects unless force is set
 type deleteObjNode struct {
-	path node
+	path  node
+	force bool
 }
 
 func (n *deleteObjNode) execute() (interface{}, error) {
@@ -311,7 +551,43 @@ func (n *deleteObjNode) execute() (interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("Path should be a string")
 	}
-	return cmd.DeleteObj(path), nil
+	paths, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) > 1 && !n.force {
+		if cmd.State.Terminal == nil {
+			return nil, fmt.Errorf("%d objects match %s, use - -f to delete them", len(paths), path)
+		}
+		if !cmd.Confirm(fmt.Sprintf("%d objects match %s. Do you want to delete them ?", len(paths), path)) {
+			return nil, nil
+		}
+	}
+	//The children are deleted before their parents
+	sort.SliceStable(paths, func(i, j int) bool {
+		return strings.Count(paths[i], "/") > strings.Count(paths[j], "/")
+	})
+	var errs pathErrors
+	for _, path := range paths {
+		if err := cmd.DeleteObj(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, nil
+}
+
+// Errors of a command applied to each path matching a pattern
+type pathErrors []error
+
+func (e pathErrors) Error() string {
+	messages := []string{}
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
 }
 
 type deleteSelectionNode struct{}
@@ -366,11 +642,22 @@ func (n *getObjectNode) execute() (interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("Object path should be a string")
 	}
-	v, _ := cmd.GetObject(path, false)
-	if v == nil {
-		return nil, fmt.Errorf("Cannot find object at path %s", path)
+	paths, err := expandPath(path)
+	if err != nil {
+		return nil, err
 	}
-	return v, nil
+	objects := []map[string]interface{}{}
+	for _, path := range paths {
+		v, _ := cmd.GetObject(path, false)
+		if v == nil {
+			return nil, fmt.Errorf("Cannot find object at path %s", path)
+		}
+		objects = append(objects, v)
+	}
+	if !isPathPattern(path) {
+		return objects[0], nil
+	}
+	return objects, nil
 }
 
 type selectObjectNode struct {
@@ -387,6 +674,13 @@ func (n *selectObjectNode) execute() (interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("Object path should be a string")
 	}
+	if isPathPattern(path) {
+		selection, err = expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		return cmd.SetClipBoard(selection)
+	}
 	if path != "" {
 		selection = []string{path}
 	}
@@ -626,6 +920,22 @@ func (n *updateObjNode) execute() (interface{}, error) {
 		}
 		return nil, cmd.UpdateSelection(map[string]any{n.attr: values[0]})
 	}
+	if isPathPattern(path) {
+		paths, err := expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			if _, err := n.updateObject(path, values); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+	return n.updateObject(path, values)
+}
+
+func (n *updateObjNode) updateObject(path string, values []any) (interface{}, error) {
 	boolInteractVals := []string{"content", "alpha", "tilesName", "tilesColor", "U", "slots", "localCS"}
 	if AssertInStringValues(n.attr, boolInteractVals) {
 		if !IsBool(values[0]) {
@@ -658,23 +968,10 @@ type lsObjNode struct {
 }
 
 func (n *lsObjNode) execute() (interface{}, error) {
-	val, err := n.path.execute()
+	objects, err := n.list()
 	if err != nil {
 		return nil, err
 	}
-	path, ok := val.(string)
-	if !ok {
-		return nil, fmt.Errorf("Path should be a string")
-	}
-	var objects []any
-	if n.recursive {
-		objects = cmd.LSOBJECTRecursive(path, n.entity)
-	} else {
-		objects = cmd.LSOBJECT(path, n.entity)
-	}
-	if n.sort != "" {
-		objects = cmd.SortObjects(&objects, n.sort).GetData()
-	}
 	if n.attrList != nil {
 		if n.format != "" {
 			cmd.DispfWithAttrs(n.format, &objects, &n.attrList)
@@ -698,6 +995,42 @@ func (n *lsObjNode) execute() (interface{}, error) {
 	return objects, nil
 }
 
+// The names of the objects listed
+func (n *lsObjNode) quietValue() (interface{}, error) {
+	objects, err := n.list()
+	if err != nil {
+		return nil, err
+	}
+	names := []interface{}{}
+	for _, obj := range objects {
+		if obj, ok := obj.(map[string]interface{}); ok {
+			names = append(names, objectName(obj))
+		}
+	}
+	return names, nil
+}
+
+func (n *lsObjNode) list() ([]any, error) {
+	val, err := n.path.execute()
+	if err != nil {
+		return nil, err
+	}
+	path, ok := val.(string)
+	if !ok {
+		return nil, fmt.Errorf("Path should be a string")
+	}
+	var objects []any
+	if n.recursive {
+		objects = cmd.LSOBJECTRecursive(path, n.entity)
+	} else {
+		objects = cmd.LSOBJECT(path, n.entity)
+	}
+	if n.sort != "" {
+		objects = cmd.SortObjects(&objects, n.sort).GetData()
+	}
+	return objects, nil
+}
+
 type treeNode struct {
 	path  node
 	depth int
@@ -731,7 +1064,16 @@ func (n *drawNode) execute() (interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("Path should be a string")
 	}
-	return nil, cmd.Draw(path, n.depth, n.force)
+	paths, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if err := cmd.Draw(path, n.depth, n.force); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
 }
 
 type undrawNode struct {
@@ -763,11 +1105,23 @@ func (n *lsenterpriseNode) execute() (interface{}, error) {
 	return nil, nil
 }
 
-type exitNode struct{}
+type exitNode struct {
+	code node
+}
 
 func (n *exitNode) execute() (interface{}, error) {
-	cmd.Exit()
-	return nil, nil
+	if n.code == nil {
+		return nil, &exitError{0}
+	}
+	val, err := n.code.execute()
+	if err != nil {
+		return nil, err
+	}
+	code, ok := val.(int)
+	if !ok {
+		return nil, fmt.Errorf("exit code should be an integer")
+	}
+	return nil, &exitError{code}
 }
 
 type clrNode struct{}
@@ -807,10 +1161,18 @@ type selectChildrenNode struct {
 }
 
 func (n *selectChildrenNode) execute() (interface{}, error) {
-	paths, err := evalNodeArr[string](&n.paths, []string{})
+	patterns, err := evalNodeArr[string](&n.paths, []string{})
 	if err != nil {
 		return nil, err
 	}
+	paths := []string{}
+	for _, pattern := range patterns {
+		expanded, err := expandPath(pattern)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, expanded...)
+	}
 	v, err := cmd.SetClipBoard(paths)
 	if err != nil {
 		return nil, err
@@ -845,7 +1207,7 @@ type unsetVarNode struct {
 }
 
 func (n *unsetVarNode) execute() (interface{}, error) {
-	delete(dynamicSymbolTable, n.varName)
+	unsetVar(n.varName)
 	return nil, nil
 }
 
@@ -980,9 +1342,7 @@ func (n *createBuildingNode) execute() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	posXY, ok := posXYany.([]float64)
-	if !ok || len(posXY) != 2 {
-		fmt.Printf("%v\n", posXYany)
+	if !isVector(posXYany, 2) {
 		return nil, fmt.Errorf("posXY should be a vector2")
 	}
 	rotationAny, err := n.rotation.execute()
@@ -993,7 +1353,7 @@ func (n *createBuildingNode) execute() (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("rotation should be a number")
 	}
-	attributes := map[string]any{"posXY": posXY, "rotation": rotation}
+	attributes := map[string]any{"posXY": posXYany, "rotation": rotation}
 
 	sizeOrTemplateAny, err := n.sizeOrTemplate.execute()
 	if err != nil {
@@ -1003,11 +1363,10 @@ func (n *createBuildingNode) execute() (interface{}, error) {
 	if ok && checkIfTemplate(template, cmd.BLDG) {
 		attributes["template"] = template
 	} else {
-		size, ok := sizeOrTemplateAny.([]float64)
-		if !ok || len(size) != 3 {
+		if !isVector(sizeOrTemplateAny, 3) {
 			return nil, fmt.Errorf("vector3 (size) or template expected")
 		}
-		attributes["size"] = size
+		attributes["size"] = sizeOrTemplateAny
 	}
 	err = cmd.GetOCLIAtrributes(path, cmd.BLDG, map[string]any{"attributes": attributes})
 	if err != nil {
@@ -1039,8 +1398,7 @@ func (n *createRoomNode) execute() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	posXY, ok := posXYany.([]float64)
-	if !ok || len(posXY) != 2 {
+	if !isVector(posXYany, 2) {
 		return nil, fmt.Errorf("posXY should be a vector2")
 	}
 	rotationAny, err := n.rotation.execute()
@@ -1051,7 +1409,7 @@ func (n *createRoomNode) execute() (interface{}, error) {
 	if err != nil {
 		return nil, fmt.Errorf("rotation should be a number")
 	}
-	attributes := map[string]any{"posXY": posXY, "rotation": rotation}
+	attributes := map[string]any{"posXY": posXYany, "rotation": rotation}
 
 	if n.template != nil {
 		templateAny, err := n.template.execute()
@@ -1068,11 +1426,10 @@ func (n *createRoomNode) execute() (interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
-		size, ok := sizeAny.([]float64)
-		if !ok || len(size) != 3 {
+		if !isVector(sizeAny, 3) {
 			return nil, fmt.Errorf("size should be a vector3")
 		}
-		attributes["size"] = size
+		attributes["size"] = sizeAny
 		axisOrientationAny, err := n.axisOrientation.execute()
 		if err != nil {
 			return nil, err
@@ -1122,8 +1479,7 @@ func (n *createRackNode) execute() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	pos, ok := posAny.([]float64)
-	if !ok || (len(pos) != 2 && len(pos) != 3) {
+	if !isVector(posAny, 2, 3) {
 		return nil, fmt.Errorf("position should be a vector2 or a vector3")
 	}
 	orientationAny, err := n.orientation.execute()
@@ -1134,7 +1490,7 @@ func (n *createRackNode) execute() (interface{}, error) {
 	if !ok || (orientation != "front" && orientation != "rear" && orientation != "left" && orientation != "right") {
 		return nil, fmt.Errorf("orientation should be a front, rear, left or right")
 	}
-	attributes := map[string]any{"posXYZ": pos, "orientation": orientation}
+	attributes := map[string]any{"posXYZ": posAny, "orientation": orientation}
 
 	sizeOrTemplateAny, err := n.sizeOrTemplate.execute()
 	if err != nil {
@@ -1144,11 +1500,10 @@ func (n *createRackNode) execute() (interface{}, error) {
 	if ok && checkIfTemplate(template, cmd.RACK) {
 		attributes["template"] = template
 	} else {
-		size, ok := sizeOrTemplateAny.([]float64)
-		if !ok || len(size) != 3 {
+		if !isVector(sizeOrTemplateAny, 3) {
 			return nil, fmt.Errorf("vector3 (size) or template expected")
 		}
-		attributes["size"] = size
+		attributes["size"] = sizeOrTemplateAny
 	}
 	err = cmd.GetOCLIAtrributes(path, cmd.RACK, map[string]any{"attributes": attributes})
 	if err != nil {
@@ -1325,6 +1680,56 @@ func (n *createOrphanNode) execute() (interface{}, error) {
 	return nil, nil
 }
 
+// Creation of several objects from a path with braces : +rk:R{01..10}@...,
+// the create command is executed for each path given by the expansion
+type createManyNode struct {
+	create node
+}
+
+// Returns the path of a create command, and a function
+// copying the command with another path
+func createCommandPath(n node) (node, func(path node) node) {
+	switch n := n.(type) {
+	case *createTenantNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createSiteNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createBuildingNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createRoomNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createRackNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createDeviceNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createGroupNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createCorridorNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	case *createOrphanNode:
+		return n.path, func(path node) node { c := *n; c.path = path; return &c }
+	}
+	return nil, nil
+}
+
+func (n *createManyNode) execute() (interface{}, error) {
+	path, withPath := createCommandPath(n.create)
+	pattern, err := AssertString(&path, "Path")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := cmd.ExpandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		if _, err := withPath(&strLeaf{path}).execute(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
 type uiDelayNode struct {
 	time float64
 }
@@ -1367,6 +1772,20 @@ func (n *uiClearCacheNode) execute() (interface{}, error) {
 	return nil, cmd.UIClearCache()
 }
 
+type cacheClearNode struct{}
+
+func (n *cacheClearNode) execute() (interface{}, error) {
+	cmd.ClearCache()
+	return nil, nil
+}
+
+type cacheStatsNode struct{}
+
+func (n *cacheStatsNode) execute() (interface{}, error) {
+	cmd.DisplayCacheStats()
+	return nil, nil
+}
+
 type cameraMoveNode struct {
 	command  string
 	position node
@@ -1463,7 +1882,11 @@ type symbolReferenceNode struct {
 }
 
 func (s *symbolReferenceNode) execute() (interface{}, error) {
-	val, ok := dynamicSymbolTable[s.va]
+	val, ok := getVar(s.va)
+	if !ok && strings.Contains(s.va, ".") {
+		// ${rack.attributes.height} : attributes of a map variable
+		return resolveAttributes(strings.Split(s.va, "."))
+	}
 	if !ok {
 		return nil, fmt.Errorf("Undefined variable %s", s.va)
 	}
@@ -1476,13 +1899,30 @@ func (s *symbolReferenceNode) execute() (interface{}, error) {
 	return val, nil
 }
 
+func resolveAttributes(names []string) (interface{}, error) {
+	val, ok := getVar(names[0])
+	if !ok {
+		return nil, fmt.Errorf("Undefined variable %s", names[0])
+	}
+	for _, name := range names[1:] {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Cannot get attribute %s of a value that is not a map", name)
+		}
+		if val, ok = m[name]; !ok {
+			return nil, fmt.Errorf("Key %s not found", name)
+		}
+	}
+	return val, nil
+}
+
 type objReferenceNode struct {
 	va    string
 	index node
 }
 
 func (o *objReferenceNode) execute() (interface{}, error) {
-	val, ok := dynamicSymbolTable[o.va]
+	val, ok := getVar(o.va)
 	if !ok {
 		return nil, fmt.Errorf("Undefined variable %s", o.va)
 	}
@@ -1525,31 +1965,167 @@ type arrayReferenceNode struct {
 }
 
 func (n *arrayReferenceNode) execute() (interface{}, error) {
-	v, ok := dynamicSymbolTable[n.variable]
+	v, ok := getVar(n.variable)
 	if !ok {
 		return nil, fmt.Errorf("Undefined variable %s", n.variable)
 	}
-	arr, ok := v.([]float64)
-	if !ok {
-		return nil, fmt.Errorf("You can only index an array.")
+	return indexValue(v, n.idx)
+}
+
+// Access to an element of any value : $(get R1).name, $x[0]["key"]
+type indexNode struct {
+	container node
+	idx       node
+}
+
+func (n *indexNode) execute() (interface{}, error) {
+	v, err := n.container.execute()
+	if err != nil {
+		return nil, err
 	}
-	idx, err := n.idx.execute()
+	return indexValue(v, n.idx)
+}
+
+// Access to a field of a map : $rack.name, $x.attributes.height.
+// In an unquoted string, the other values are followed by the text
+// of the field, so that .cmds:$name.ocli still gives a file name
+type fieldNode struct {
+	container node
+	field     string
+	orText    bool
+}
+
+func (n *fieldNode) execute() (interface{}, error) {
+	v, err := n.container.execute()
 	if err != nil {
 		return nil, err
 	}
+	if _, ok := v.(map[string]interface{}); !ok {
+		if n.orText {
+			return fmt.Sprintf("%v.%s", v, n.field), nil
+		}
+		return nil, fmt.Errorf("cannot access the field %s of %v, it is not a map", n.field, v)
+	}
+	return indexValue(v, &strLeaf{n.field})
+}
+
+// Returns the element of an array at an integer index,
+// or the value of a map at a string key
+func indexValue(v interface{}, idxNode node) (interface{}, error) {
+	idx, err := idxNode.execute()
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		key, ok := idx.(string)
+		if !ok {
+			return nil, fmt.Errorf("Key should be a string.")
+		}
+		val, ok := m[key]
+		if !ok {
+			return nil, fmt.Errorf("Key %s not found", key)
+		}
+		return val, nil
+	}
+	var length int
+	switch arr := v.(type) {
+	case []float64:
+		length = len(arr)
+	case []string:
+		length = len(arr)
+	case []interface{}:
+		length = len(arr)
+	default:
+		return nil, fmt.Errorf("You can only index an array or a map.")
+	}
 	i, ok := idx.(int)
 	if !ok {
 		return nil, fmt.Errorf("Index should be an integer.")
 	}
-	if i < 0 || i >= len(arr) {
+	if i < 0 || i >= length {
 		return nil, fmt.Errorf(
 			"Index out of range\n"+
 				"Array length : %d"+
 				"But desired index at : %d",
-			len(arr), i,
+			length, i,
 		)
 	}
-	return arr[i], nil
+	switch arr := v.(type) {
+	case []string:
+		return arr[i], nil
+	case []interface{}:
+		return arr[i], nil
+	}
+	return v.([]float64)[i], nil
+}
+
+// Map literal : {"key": value, ...}
+type mapNode struct {
+	keys   []string
+	values []node
+}
+
+func (n *mapNode) execute() (interface{}, error) {
+	m := map[string]interface{}{}
+	for i, key := range n.keys {
+		val, err := n.values[i].execute()
+		if err != nil {
+			return nil, err
+		}
+		m[key] = val
+	}
+	return m, nil
+}
+
+// Command substitution : $(command), evaluates to the value
+// returned by the command, lists of objects become lists of names
+type commandSubstNode struct {
+	command node
+}
+
+func objectName(obj map[string]interface{}) interface{} {
+	if slug, ok := obj["slug"].(string); ok {
+		return slug
+	}
+	if name, ok := obj["name"].(string); ok {
+		return name
+	}
+	return obj
+}
+
+// Commands displaying what they return, quietValue gives the
+// value of the command without displaying it when it is substituted
+type quietCommand interface {
+	quietValue() (interface{}, error)
+}
+
+// The listings give the names of the objects,
+// the other commands give the objects themselves
+func (n *commandSubstNode) execute() (interface{}, error) {
+	if n.command == nil {
+		return nil, fmt.Errorf("empty command substitution")
+	}
+	var val interface{}
+	var err error
+	if command, ok := n.command.(quietCommand); ok {
+		val, err = command.quietValue()
+	} else {
+		val, err = n.command.execute()
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch v := val.(type) {
+	case nil:
+		return nil, fmt.Errorf("the substituted command returns no value")
+	case []map[string]interface{}:
+		objects := []interface{}{}
+		for _, obj := range v {
+			objects = append(objects, obj)
+		}
+		return objects, nil
+	}
+	return val, nil
 }
 
 type assignNode struct {
@@ -1563,8 +2139,8 @@ func (a *assignNode) execute() (interface{}, error) {
 		return nil, err
 	}
 	switch v := val.(type) {
-	case bool, int, float64, string, []float64, map[string]interface{}:
-		dynamicSymbolTable[a.variable] = v
+	case bool, int, float64, string, cmd.Quantity, []float64, []string, []interface{}, map[string]interface{}:
+		setVar(a.variable, v)
 		if cmd.State.DebugLvl >= 3 {
 			println("You want to assign", a.variable, "with value of", v)
 		}
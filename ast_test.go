@@ -0,0 +1,445 @@
+package main
+
+import (
+	cmd "cli/controllers"
+	"reflect"
+	"testing"
+)
+
+func executeCommand(buffer string, t *testing.T) interface{} {
+	n, err := Parse(buffer)
+	if err != nil {
+		t.Errorf("cannot parse command : %s", err.Error())
+		return nil
+	}
+	val, execErr := n.execute()
+	if execErr != nil {
+		t.Errorf("cannot execute command %s : %s", buffer, execErr.Error())
+	}
+	return val
+}
+
+func TestFuncLocalScope(t *testing.T) {
+	executeCommand(".var:i=0", t)
+	executeCommand("alias setlocal(v) {.var:i=$v; .var:j=$v}", t)
+	executeCommand("setlocal(42)", t)
+	if dynamicSymbolTable["i"] != 0 {
+		t.Errorf("global variable modified by function : %v", dynamicSymbolTable["i"])
+	}
+	if _, ok := dynamicSymbolTable["j"]; ok {
+		t.Errorf("local variable leaked into global scope")
+	}
+	if _, ok := dynamicSymbolTable["v"]; ok {
+		t.Errorf("parameter leaked into global scope")
+	}
+	if len(scopeStack) != 0 {
+		t.Errorf("scope stack not empty after call")
+	}
+}
+
+func TestFuncGlobalDecl(t *testing.T) {
+	executeCommand(".var:count=0", t)
+	executeCommand("alias incr(n) {global count; .var:count=$count+$n}", t)
+	executeCommand("incr(2)", t)
+	executeCommand("incr(3)", t)
+	if dynamicSymbolTable["count"] != 5 {
+		t.Errorf("global variable not updated : %v", dynamicSymbolTable["count"])
+	}
+}
+
+func TestFuncWrongArity(t *testing.T) {
+	executeCommand("alias twoargs(a, b) {print $a}", t)
+	n, _ := Parse("twoargs(1)")
+	if _, err := n.execute(); err == nil {
+		t.Errorf("calling a function with a wrong number of arguments should fail")
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	executeCommand(".var:i=4", t)
+	executeCommand(".var:name=\"R${i}-$i: 50%\"", t)
+	if dynamicSymbolTable["name"] != "R4-4: 50%" {
+		t.Errorf("wrong interpolated string : %v", dynamicSymbolTable["name"])
+	}
+}
+
+func TestBreakContinue(t *testing.T) {
+	executeCommand(".var:sum=0", t)
+	executeCommand("for i in 0..10 {if $i == 7 {break}; if $i % 2 == 0 {continue}; .var:sum=$sum+$i}", t)
+	if dynamicSymbolTable["sum"] != 9 {
+		t.Errorf("wrong sum computed with break and continue : %v", dynamicSymbolTable["sum"])
+	}
+	executeCommand(".var:k=0", t)
+	executeCommand("while true {.var:k=$k+1; if $k == 5 {break}}", t)
+	if dynamicSymbolTable["k"] != 5 {
+		t.Errorf("while loop not exited by break : %v", dynamicSymbolTable["k"])
+	}
+}
+
+func TestReturn(t *testing.T) {
+	executeCommand("alias firstabove(n) {for i in 0..100 {if $i * $i > $n {return $i}}; return -1}", t)
+	executeCommand(".var:r=firstabove(50) + 1", t)
+	if dynamicSymbolTable["r"] != 9 {
+		t.Errorf("wrong value returned by function : %v", dynamicSymbolTable["r"])
+	}
+	n, _ := Parse("break")
+	if _, err := n.execute(); err == nil {
+		t.Errorf("break outside of a loop should return an error")
+	}
+}
+
+// The aliases whose name starts with cache are not the cache command
+func TestCacheAlias(t *testing.T) {
+	executeCommand("alias cacheWarm {global warmed; .var:warmed=true}", t)
+	executeCommand("cacheWarm", t)
+	if dynamicSymbolTable["warmed"] != true {
+		t.Errorf("the alias cacheWarm should be called")
+	}
+	if _, err := Parse("cache warm"); err == nil {
+		t.Errorf("cache warm is not a cache command")
+	}
+}
+
+func TestBreakInFunction(t *testing.T) {
+	executeCommand("alias brk {break}", t)
+	executeCommand(".var:c=0", t)
+	n, _ := Parse("for i in 0..3 {brk; .var:c=$c+1}")
+	_, err := n.execute()
+	if err == nil || err.Error() != "break outside of a loop in function brk" {
+		t.Errorf("break should not leave the function : %v", err)
+	}
+	if dynamicSymbolTable["c"] != 0 {
+		t.Errorf("the loop should stop at the error : %v", dynamicSymbolTable["c"])
+	}
+	executeCommand("alias cont {continue}", t)
+	n, _ = Parse("while true {cont}")
+	if _, err := n.execute(); err == nil {
+		t.Errorf("continue should not leave the function")
+	}
+}
+
+func TestTryCatch(t *testing.T) {
+	executeCommand(".var:step=0", t)
+	executeCommand("try {.var:step=1; undefinedfunc; .var:step=2} catch $err {.var:caught=$err} finally {.var:final=true}", t)
+	if dynamicSymbolTable["step"] != 1 {
+		t.Errorf("try block should stop at the first error : %v", dynamicSymbolTable["step"])
+	}
+	if dynamicSymbolTable["caught"] != "undefined function undefinedfunc" {
+		t.Errorf("wrong caught error message : %v", dynamicSymbolTable["caught"])
+	}
+	if dynamicSymbolTable["final"] != true {
+		t.Errorf("finally block not executed")
+	}
+	n, _ := Parse("try {undefinedfunc} finally {.var:final=false}")
+	if _, err := n.execute(); err == nil {
+		t.Errorf("error should be propagated when there is no catch block")
+	}
+	if dynamicSymbolTable["final"] != false {
+		t.Errorf("finally block not executed")
+	}
+	executeCommand(".var:count=0", t)
+	executeCommand("for i in 0..5 {try {break} catch {.var:count=42}; .var:count=$count+1}", t)
+	if dynamicSymbolTable["count"] != 0 {
+		t.Errorf("break should not be caught : %v", dynamicSymbolTable["count"])
+	}
+}
+
+func TestCommandSubst(t *testing.T) {
+	objects := []interface{}{
+		map[string]interface{}{"name": "R1"},
+		map[string]interface{}{"slug": "rack-template"},
+	}
+	val, err := (&commandSubstNode{&valueNode{objects}}).execute()
+	if err != nil || !reflect.DeepEqual(val, objects) {
+		t.Errorf("objects should be substituted by themselves : %v", val)
+	}
+	executeCommand("alias vec {return [1, 2]}", t)
+	executeCommand(".var:sum=0; for x in $(vec) {.var:sum=$sum+$x}", t)
+	if dynamicSymbolTable["sum"] != 3. {
+		t.Errorf("wrong sum of the substituted array : %v", dynamicSymbolTable["sum"])
+	}
+}
+
+// In an unquoted string, the values that are not maps
+// are not accessed, they are followed by the text
+func TestFileNameNotField(t *testing.T) {
+	executeCommand(".var:name=demo; .var:i=3", t)
+	executeCommand(".var:f=$name.ocli; .var:g=${name}.tar.gz; .var:h=$i.json", t)
+	if dynamicSymbolTable["f"] != "demo.ocli" {
+		t.Errorf("wrong file name : %v", dynamicSymbolTable["f"])
+	}
+	if dynamicSymbolTable["g"] != "demo.tar.gz" || dynamicSymbolTable["h"] != "3.json" {
+		t.Errorf("wrong file names : %v %v", dynamicSymbolTable["g"], dynamicSymbolTable["h"])
+	}
+	//In the other expressions, only the maps have fields
+	for _, command := range []string{".var:e=$name.ocli + \"\"", "if $i.json == 3 {print 3}"} {
+		n, _ := Parse(command)
+		if _, err := n.execute(); err == nil {
+			t.Errorf("%s should fail, the variables are not maps", command)
+		}
+	}
+}
+
+func TestMapAccess(t *testing.T) {
+	executeCommand(".var:rack={\"name\": \"R1\", \"attributes\": {\"height\": 42, \"posXY\": [1, 2]}}", t)
+	executeCommand(".var:h=$rack.attributes.height; .var:y=$rack[\"attributes\"][\"posXY\"][1]", t)
+	if dynamicSymbolTable["h"] != 42 || dynamicSymbolTable["y"] != 2. {
+		t.Errorf("wrong attribute values : %v %v", dynamicSymbolTable["h"], dynamicSymbolTable["y"])
+	}
+	executeCommand(".var:s=\"${rack.name}:${rack.attributes.height}\"", t)
+	if dynamicSymbolTable["s"] != "R1:42" {
+		t.Errorf("wrong attributes in string : %v", dynamicSymbolTable["s"])
+	}
+	executeCommand(".var:keys=\"\"; for k in $rack.attributes {.var:keys=\"$keys$k \"}", t)
+	if dynamicSymbolTable["keys"] != "height posXY " {
+		t.Errorf("map keys should be iterated in order : %v", dynamicSymbolTable["keys"])
+	}
+	if _, err := (&arrayReferenceNode{"rack", &strLeaf{"color"}}).execute(); err == nil {
+		t.Errorf("access to a missing key should fail")
+	}
+}
+
+func TestBuiltinFuncs(t *testing.T) {
+	tests := map[string]interface{}{
+		"split(\"a,b\", \",\")":               []string{"a", "b"},
+		"join([1, 2.5], \"-\")":               "1-2.5",
+		"upper(\"ab\") + lower(\"CD\")":       "ABcd",
+		"replace(\"R1-R2\", \"R\", \"C\")":    "C1-C2",
+		"format(\"R%02d:%s\", 3, \"a\")":      "R03:a",
+		"pad(7, 3)":                           "007",
+		"pad(-7, 3)":                          "-07",
+		"pad(\"ab\", 4, \".\")":               "..ab",
+		"round(2.5) + floor(1.9) + ceil(1.1)": 6,
+		"round(3.14159, 2)":                   3.14,
+		"min(3, 1.5, 2)":                      1.5,
+		"max([1, 4, 2])":                      4.,
+		"abs(-3)":                             3,
+		"int(\"42\") + int(2.9)":              44,
+		"float(\"1.5\")":                      1.5,
+		"str(1.5) + str(true)":                "1.5true",
+		"bool(\"false\") || bool(0)":          false,
+		"len(\"abc\") + len([1, 2])":          5,
+	}
+	for expr, expected := range tests {
+		n, _, err := parseExpr(newFrame(expr))
+		if err != nil {
+			t.Errorf("cannot parse %s : %s", expr, err.Error())
+			continue
+		}
+		val, e := n.execute()
+		if e != nil || !reflect.DeepEqual(val, expected) {
+			t.Errorf("%s gives %v (error %v), %v expected", expr, val, e, expected)
+		}
+	}
+	for _, expr := range []string{"upper(42)", "pad(1)", "int(\"a\")", "min([])"} {
+		n, _, err := parseExpr(newFrame(expr))
+		if err != nil {
+			t.Errorf("cannot parse %s : %s", expr, err.Error())
+			continue
+		}
+		if _, e := n.execute(); e == nil {
+			t.Errorf("%s should fail", expr)
+		}
+	}
+	executeCommand("alias upper(s) {return \"overridden\"}", t)
+	defer delete(funcTable, "upper")
+	executeCommand(".var:u=upper(\"a\")", t)
+	if dynamicSymbolTable["u"] != "overridden" {
+		t.Errorf("user functions should take precedence over built-in ones")
+	}
+}
+
+func TestOperators(t *testing.T) {
+	executeCommand(".var:rack={\"name\": \"B12\", \"height\": 47}", t)
+	tests := map[string]interface{}{
+		"$rack.name =~ \"^B\" && $rack.height in [42, 47]": true,
+		"$rack.name !~ \"^B[0-9]\\$\"":                     true,
+		"\"name\" in $rack":                                true,
+		"\"12\" in $rack.name":                             true,
+		"\"c\" in split(\"a,b\", \",\")":                   false,
+		"\"a\" in [\"a\", \"b\"]":                          true,
+		"\"1\" in [\"a\", 1]":                              false,
+		"[\"a\", \"b\"]":                                   []string{"a", "b"},
+		"[\"a\", 1]":                                       []interface{}{"a", 1.0},
+		"\"R10\" < \"R9\"":                                 true,
+		"$rack.height > 42 ? \"high\" : \"low\"":           "high",
+		"false ? 1 : true ? 2 : 3":                         2,
+		"\"ab\" * 3 + 1 + true":                            "ababab1true",
+		"2 * \"-\"":                                        "--",
+	}
+	for expr, expected := range tests {
+		n, _, err := parseExpr(newFrame(expr))
+		if err != nil {
+			t.Errorf("cannot parse %s : %s", expr, err.Error())
+			continue
+		}
+		val, e := n.execute()
+		if e != nil || !reflect.DeepEqual(val, expected) {
+			t.Errorf("%s gives %v (error %v), %v expected", expr, val, e, expected)
+		}
+	}
+	for _, expr := range []string{"1 ? 2 : 3", "\"a\" =~ \"[\"", "1 in 2", "\"a\" * -1"} {
+		n, _, err := parseExpr(newFrame(expr))
+		if err != nil {
+			t.Errorf("cannot parse %s : %s", expr, err.Error())
+			continue
+		}
+		if _, e := n.execute(); e == nil {
+			t.Errorf("%s should fail", expr)
+		}
+	}
+}
+
+func TestUnits(t *testing.T) {
+	executeCommand(".var:pos=[600mm, 2.4m, 1]", t)
+	if pos, _ := getVar("pos"); !isVector(pos, 3) {
+		t.Errorf("%v should be a vector3", pos)
+	}
+	executeCommand(".var:h=42U", t)
+	if h, _ := getVar("h"); h != (cmd.Quantity{Value: 42, Unit: "U"}) {
+		t.Errorf("h is %v, 42U expected", h)
+	}
+	conversions := []struct {
+		value    interface{}
+		unit     string
+		expected float64
+	}{
+		{cmd.Quantity{Value: 600, Unit: "mm"}, "t", 1},
+		{cmd.Quantity{Value: 2.4, Unit: "m"}, "cm", 240},
+		{cmd.Quantity{Value: 42, Unit: "U"}, "mm", 1866.9},
+		{cmd.Quantity{Value: 2, Unit: "ft"}, "m", 0.6096},
+		{1.5, "U", 1.5},
+	}
+	for _, c := range conversions {
+		val, err := cmd.ConvertLength(c.value, c.unit)
+		if err != nil || val != c.expected {
+			t.Errorf("%v in %s gives %v (error %v), %v expected", c.value, c.unit, val, err, c.expected)
+		}
+	}
+	if _, err := cmd.ConvertLength(cmd.Quantity{Value: 42, Unit: "U"}, "t"); err == nil {
+		t.Errorf("42U should not be converted to tiles")
+	}
+	if _, err := cmd.ConvertLength(cmd.Quantity{Value: 3, Unit: "t"}, "U"); err == nil {
+		t.Errorf("3t should not be converted to rack units")
+	}
+	arith := map[string]interface{}{
+		"2 * 600mm":     cmd.Quantity{Value: 1200, Unit: "mm"},
+		"1m + 600mm":    cmd.Quantity{Value: 1.6, Unit: "m"},
+		"-(1.2m / 2)":   cmd.Quantity{Value: -0.6, Unit: "m"},
+		"1m / 20cm":     5.,
+		"600mm == 60cm": true,
+		"600mm > 1m":    false,
+		"1m >= 600mm":   true,
+		"42U != 42":     false,
+	}
+	for expr, expected := range arith {
+		n, _, err := parseExpr(newFrame(expr))
+		if err != nil {
+			t.Errorf("cannot parse %s : %s", expr, err.Error())
+			continue
+		}
+		val, e := n.execute()
+		if e != nil || !reflect.DeepEqual(val, expected) {
+			t.Errorf("%s gives %v (error %v), %v expected", expr, val, e, expected)
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	hierarchy := map[string][]string{
+		"/":                         {"Physical"},
+		"/Physical":                 {"SI"},
+		"/Physical/SI":              {"BLDG1", "BLDG2"},
+		"/Physical/SI/BLDG1":        {"R1", "R2"},
+		"/Physical/SI/BLDG2":        {"R1", "X3"},
+		"/Physical/SI/BLDG1/R1":     {"A01", "A02", "B01"},
+		"/Physical/SI/BLDG2/R1":     {"A01"},
+		"/Physical/SI/BLDG2/R1/A01": {"dev"},
+	}
+	children := func(path string) []string {
+		return hierarchy[path]
+	}
+	tests := map[string][]string{
+		"/Physical/SI/*/R?":            {"/Physical/SI/BLDG1/R1", "/Physical/SI/BLDG1/R2", "/Physical/SI/BLDG2/R1"},
+		"/Physical/SI/BLDG1/R1/[AB]01": {"/Physical/SI/BLDG1/R1/A01", "/Physical/SI/BLDG1/R1/B01"},
+		"/Physical/SI/*/R1/A01":        {"/Physical/SI/BLDG1/R1/A01", "/Physical/SI/BLDG2/R1/A01"},
+		"/Physical/**/A*":              {"/Physical/SI/BLDG1/R1/A01", "/Physical/SI/BLDG1/R1/A02", "/Physical/SI/BLDG2/R1/A01"},
+		"/Physical/SI/**/dev":          {"/Physical/SI/BLDG2/R1/A01/dev"},
+	}
+	for pattern, expected := range tests {
+		paths, err := cmd.ExpandPath(pattern, children)
+		if err != nil || !reflect.DeepEqual(paths, expected) {
+			t.Errorf("%s expands to %v (error %v), %v expected", pattern, paths, err, expected)
+		}
+	}
+	for _, pattern := range []string{"/Physical/SI/*/Z*", "/Physical/SI/[A"} {
+		if _, err := cmd.ExpandPath(pattern, children); err == nil {
+			t.Errorf("%s should not expand", pattern)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := map[string][]string{
+		"/P/R{01..03}":     {"/P/R01", "/P/R02", "/P/R03"},
+		"/P/{A..C}{1..2}":  {"/P/A1", "/P/A2", "/P/B1", "/P/B2", "/P/C1", "/P/C2"},
+		"/P/R{a,b{1,2},}c": {"/P/Rac", "/P/Rb1c", "/P/Rb2c", "/P/Rc"},
+		"/P/{9..11}":       {"/P/9", "/P/10", "/P/11"},
+		"/P/{10..1..4}":    {"/P/10", "/P/6", "/P/2"},
+		"/P/{c..a}":        {"/P/c", "/P/b", "/P/a"},
+		"/P/{-01..1}":      {"/P/-01", "/P/000", "/P/001"},
+		"/P/R1":            {"/P/R1"},
+	}
+	for pattern, expected := range tests {
+		paths, err := cmd.ExpandBraces(pattern)
+		if err != nil || !reflect.DeepEqual(paths, expected) {
+			t.Errorf("%s expands to %v (error %v), %v expected", pattern, paths, err, expected)
+		}
+	}
+	for _, pattern := range []string{"/P/R{1..3", "/P/{ab}", "/P/{1..a}", "/P/{Z..a}", "/P/{a..Z}", "/P/{0..100000}"} {
+		if _, err := cmd.ExpandBraces(pattern); err == nil {
+			t.Errorf("%s should not expand", pattern)
+		}
+	}
+}
+
+func TestInterrupt(t *testing.T) {
+	interrupted.Store(true)
+	defer interrupted.Store(false)
+	for _, command := range []string{
+		"while true {.var:k=0}",
+		"try {for i in 0..10 {print $i}} catch {.var:interruptCaught=true}",
+	} {
+		n, _ := Parse(command)
+		if _, err := n.execute(); err == nil {
+			t.Errorf("%s should be interrupted", command)
+		} else if _, ok := err.(*interruptError); !ok {
+			t.Errorf("%s should be interrupted, got error %s", command, err.Error())
+		}
+	}
+	if _, ok := dynamicSymbolTable["interruptCaught"]; ok {
+		t.Errorf("an interruption should not be caught")
+	}
+}
+
+// Ctrl-C pressed while a statement runs names this statement
+func TestInterruptedStatement(t *testing.T) {
+	builtinFuncs["ctrlc"] = builtinFunc{0, 0, func(args []interface{}) (interface{}, error) {
+		interrupted.Store(true)
+		return nil, nil
+	}}
+	defer delete(builtinFuncs, "ctrlc")
+	defer interrupted.Store(false)
+	n, _ := Parse(".var:a=1; for i in 0..2 {.var:b=2; .var:c=ctrlc(); .var:d=4}; .var:e=5")
+	_, err := n.execute()
+	interruptErr, ok := err.(*interruptError)
+	if !ok {
+		t.Fatalf("the command should be interrupted, got %v", err)
+	}
+	if interruptErr.statement != ".var:c=ctrlc()" {
+		t.Errorf("wrong statement interrupted : %s", interruptErr.statement)
+	}
+	if _, ok := dynamicSymbolTable["d"]; ok {
+		t.Errorf("the next statements should not run")
+	}
+}
@@ -1,6 +1,12 @@
 package main
 
-import "fmt"
+import (
+	cmd "cli/controllers"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
 
 type boolNode interface {
 	getBool() (bool, error)
@@ -34,6 +40,12 @@ func (n *equalityNode) getBool() (bool, error) {
 	if err != nil {
 		return false, err
 	}
+	if leftNum, rightNum, ok, err := sameUnitLengths(left, right); ok {
+		if err != nil {
+			return false, err
+		}
+		left, right = leftNum, rightNum
+	}
 	switch n.op {
 	case "==":
 		return left == right, nil
@@ -43,6 +55,28 @@ func (n *equalityNode) getBool() (bool, error) {
 	return false, fmt.Errorf("Invalid equality node operator : " + n.op)
 }
 
+// Converts two lengths, one of them at least with a unit, to the unit
+// of the left one, or of the right one if the left one has none.
+// Returns false if none of them has a unit
+func sameUnitLengths(left, right interface{}) (float64, float64, bool, error) {
+	unit := ""
+	if q, ok := right.(cmd.Quantity); ok {
+		unit = q.Unit
+	}
+	if q, ok := left.(cmd.Quantity); ok {
+		unit = q.Unit
+	}
+	if unit == "" {
+		return 0, 0, false, nil
+	}
+	leftNum, err := cmd.ConvertLength(left, unit)
+	if err != nil {
+		return 0, 0, true, err
+	}
+	rightNum, err := cmd.ConvertLength(right, unit)
+	return leftNum, rightNum, true, err
+}
+
 func (n *equalityNode) execute() (interface{}, error) {
 	return n.getBool()
 }
@@ -53,24 +87,41 @@ type comparatorNode struct {
 	right node
 }
 
+// Numbers are compared by value, lengths in the same unit
+// and strings in lexical order
 func (n *comparatorNode) getBool() (bool, error) {
 	left, err := n.left.execute()
 	if err != nil {
 		return false, err
 	}
-	leftNum, err := getFloat(left)
-	if err != nil {
-		return false, fmt.Errorf("left expression should return a number")
-	}
 	right, err := n.right.execute()
 	if err != nil {
 		return false, err
 	}
+	leftStr, leftIsStr := left.(string)
+	rightStr, rightIsStr := right.(string)
+	if leftIsStr && rightIsStr {
+		return compareOrdered(n.op, strings.Compare(leftStr, rightStr), 0)
+	}
+	if leftNum, rightNum, ok, err := sameUnitLengths(left, right); ok {
+		if err != nil {
+			return false, err
+		}
+		return compareOrdered(n.op, leftNum, rightNum)
+	}
+	leftNum, err := getFloat(left)
+	if err != nil {
+		return false, fmt.Errorf("left expression should return a number or a string")
+	}
 	rightNum, err := getFloat(right)
 	if err != nil {
-		return false, fmt.Errorf("right expression should return a number")
+		return false, fmt.Errorf("right expression should return a number or a string")
 	}
-	switch n.op {
+	return compareOrdered(n.op, leftNum, rightNum)
+}
+
+func compareOrdered[T int | float64](op string, leftNum, rightNum T) (bool, error) {
+	switch op {
 	case "<":
 		return leftNum < rightNum, nil
 	case "<=":
@@ -80,7 +131,7 @@ func (n *comparatorNode) getBool() (bool, error) {
 	case ">=":
 		return leftNum >= rightNum, nil
 	}
-	return false, fmt.Errorf("Invalid comparison operator : " + n.op)
+	return false, fmt.Errorf("Invalid comparison operator : " + op)
 }
 
 func (n *comparatorNode) execute() (interface{}, error) {
@@ -142,3 +193,135 @@ func (n *negateBoolNode) getBool() (bool, error) {
 func (n *negateBoolNode) execute() (interface{}, error) {
 	return n.getBool()
 }
+
+// Compiled regular expressions of the =~ and !~ operators
+var regexCache = map[string]*regexp.Regexp{}
+
+type matchNode struct {
+	op    string
+	left  node
+	right node
+}
+
+func (n *matchNode) getBool() (bool, error) {
+	left, err := n.left.execute()
+	if err != nil {
+		return false, err
+	}
+	right, err := n.right.execute()
+	if err != nil {
+		return false, err
+	}
+	pattern, ok := right.(string)
+	if !ok {
+		return false, fmt.Errorf("a regular expression should be a string")
+	}
+	re, ok := regexCache[pattern]
+	if !ok {
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			return false, fmt.Errorf("invalid regular expression %s", pattern)
+		}
+		regexCache[pattern] = re
+	}
+	var matched bool
+	switch left := left.(type) {
+	case string:
+		matched = re.MatchString(left)
+	case int, float64:
+		matched = re.MatchString(numToString(left))
+	default:
+		return false, fmt.Errorf("left expression of %s should return a string", n.op)
+	}
+	if n.op == "!~" {
+		return !matched, nil
+	}
+	return matched, nil
+}
+
+func (n *matchNode) execute() (interface{}, error) {
+	return n.getBool()
+}
+
+// x in container : x is an element of an array,
+// a key of a map, or a substring of a string
+type inNode struct {
+	elt       node
+	container node
+}
+
+func (n *inNode) getBool() (bool, error) {
+	elt, err := n.elt.execute()
+	if err != nil {
+		return false, err
+	}
+	container, err := n.container.execute()
+	if err != nil {
+		return false, err
+	}
+	switch container := container.(type) {
+	case string:
+		s, ok := elt.(string)
+		if !ok {
+			return false, fmt.Errorf("only a string can be searched in a string")
+		}
+		return strings.Contains(container, s), nil
+	case map[string]interface{}:
+		key, ok := elt.(string)
+		if !ok {
+			return false, fmt.Errorf("map keys are strings")
+		}
+		_, found := container[key]
+		return found, nil
+	}
+	elts, ok := arrayElements(container)
+	if !ok {
+		return false, fmt.Errorf("right expression of in should return an array, a map or a string")
+	}
+	for _, e := range elts {
+		if valuesEqual(elt, e) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (n *inNode) execute() (interface{}, error) {
+	return n.getBool()
+}
+
+// Integers and floats of the same value are equal
+func valuesEqual(a, b interface{}) bool {
+	switch a.(type) {
+	case int, float64:
+		switch b.(type) {
+		case int, float64:
+			x, _ := getFloat(a)
+			y, _ := getFloat(b)
+			return x == y
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// condition ? ifTrue : ifFalse, only the chosen expression is evaluated
+type conditionalNode struct {
+	condition node
+	ifTrue    node
+	ifFalse   node
+}
+
+func (n *conditionalNode) execute() (interface{}, error) {
+	val, err := n.condition.execute()
+	if err != nil {
+		return nil, err
+	}
+	condition, ok := val.(bool)
+	if !ok {
+		return nil, fmt.Errorf("condition should be a boolean")
+	}
+	if condition {
+		return n.ifTrue.execute()
+	}
+	return n.ifFalse.execute()
+}
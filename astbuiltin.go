@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Functions available in every expression, a function
+// defined by the user with the same name takes precedence
+type builtinFunc struct {
+	minArgs int
+	maxArgs int //-1 if the number of arguments is not limited
+	call    func(args []interface{}) (interface{}, error)
+}
+
+var builtinFuncs = map[string]builtinFunc{
+	"len":     {1, 1, builtinLen},
+	"split":   {2, 2, builtinSplit},
+	"join":    {2, 2, builtinJoin},
+	"upper":   {1, 1, stringFunc("upper", strings.ToUpper)},
+	"lower":   {1, 1, stringFunc("lower", strings.ToLower)},
+	"replace": {3, 3, builtinReplace},
+	"format":  {1, -1, builtinFormat},
+	"pad":     {2, 3, builtinPad},
+	"round":   {1, 2, builtinRound},
+	"floor":   {1, 1, roundingFunc("floor", math.Floor)},
+	"ceil":    {1, 1, roundingFunc("ceil", math.Ceil)},
+	"min":     {1, -1, extremumFunc("min", func(x, y float64) bool { return x < y })},
+	"max":     {1, -1, extremumFunc("max", func(x, y float64) bool { return x > y })},
+	"abs":     {1, 1, builtinAbs},
+	"int":     {1, 1, builtinInt},
+	"float":   {1, 1, builtinFloat},
+	"str":     {1, 1, builtinStr},
+	"bool":    {1, 1, builtinBool},
+}
+
+func (f builtinFunc) checkArity(name string, n int) error {
+	if n >= f.minArgs && (f.maxArgs == -1 || n <= f.maxArgs) {
+		return nil
+	}
+	switch {
+	case f.maxArgs == f.minArgs:
+		return fmt.Errorf("function %s expects %d argument(s), %d given", name, f.minArgs, n)
+	case f.maxArgs == -1:
+		return fmt.Errorf("function %s expects at least %d argument(s), %d given", name, f.minArgs, n)
+	}
+	return fmt.Errorf("function %s expects %d to %d arguments, %d given", name, f.minArgs, f.maxArgs, n)
+}
+
+func stringArg(name string, arg interface{}) (string, error) {
+	s, ok := arg.(string)
+	if !ok {
+		return "", fmt.Errorf("%s expects a string, got %v", name, arg)
+	}
+	return s, nil
+}
+
+func intArg(name string, arg interface{}) (int, error) {
+	switch v := arg.(type) {
+	case int:
+		return v, nil
+	case float64:
+		if v == math.Trunc(v) {
+			return int(v), nil
+		}
+	}
+	return 0, fmt.Errorf("%s expects an integer, got %v", name, arg)
+}
+
+func numberArg(name string, arg interface{}) (float64, error) {
+	switch v := arg.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("%s expects a number, got %v", name, arg)
+}
+
+// Returns the elements of any kind of array
+func arrayElements(arg interface{}) ([]interface{}, bool) {
+	switch arr := arg.(type) {
+	case []interface{}:
+		return arr, true
+	case []float64:
+		elts := []interface{}{}
+		for _, x := range arr {
+			elts = append(elts, x)
+		}
+		return elts, true
+	case []string:
+		elts := []interface{}{}
+		for _, s := range arr {
+			elts = append(elts, s)
+		}
+		return elts, true
+	}
+	return nil, false
+}
+
+func valueToString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int, float64:
+		return numToString(v)
+	}
+	return fmt.Sprint(v)
+}
+
+func builtinLen(args []interface{}) (interface{}, error) {
+	if s, ok := args[0].(string); ok {
+		return len(s), nil
+	}
+	if m, ok := args[0].(map[string]interface{}); ok {
+		return len(m), nil
+	}
+	if elts, ok := arrayElements(args[0]); ok {
+		return len(elts), nil
+	}
+	return nil, fmt.Errorf("len expects a string, an array or a map, got %v", args[0])
+}
+
+func builtinSplit(args []interface{}) (interface{}, error) {
+	s, err := stringArg("split", args[0])
+	if err != nil {
+		return nil, err
+	}
+	sep, err := stringArg("split", args[1])
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(s, sep), nil
+}
+
+func builtinJoin(args []interface{}) (interface{}, error) {
+	elts, ok := arrayElements(args[0])
+	if !ok {
+		return nil, fmt.Errorf("join expects an array, got %v", args[0])
+	}
+	sep, err := stringArg("join", args[1])
+	if err != nil {
+		return nil, err
+	}
+	strs := []string{}
+	for _, elt := range elts {
+		strs = append(strs, valueToString(elt))
+	}
+	return strings.Join(strs, sep), nil
+}
+
+func stringFunc(name string, f func(string) string) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		s, err := stringArg(name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return f(s), nil
+	}
+}
+
+func builtinReplace(args []interface{}) (interface{}, error) {
+	strs := []string{}
+	for _, arg := range args {
+		s, err := stringArg("replace", arg)
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, s)
+	}
+	return strings.ReplaceAll(strs[0], strs[1], strs[2]), nil
+}
+
+func builtinFormat(args []interface{}) (interface{}, error) {
+	format, err := stringArg("format", args[0])
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf(format, args[1:]...), nil
+}
+
+// pad(value, width, char) : pads the value on the left up to the width,
+// with zeros for a number and spaces for a string if no char is given
+func builtinPad(args []interface{}) (interface{}, error) {
+	width, err := intArg("pad", args[1])
+	if err != nil {
+		return nil, err
+	}
+	padding := " "
+	switch args[0].(type) {
+	case int, float64:
+		padding = "0"
+	}
+	if len(args) == 3 {
+		if padding, err = stringArg("pad", args[2]); err != nil {
+			return nil, err
+		}
+		if len(padding) != 1 {
+			return nil, fmt.Errorf("pad expects a single padding character")
+		}
+	}
+	s := valueToString(args[0])
+	if len(s) >= width {
+		return s, nil
+	}
+	if padding == "0" && strings.HasPrefix(s, "-") {
+		return "-" + strings.Repeat("0", width-len(s)) + s[1:], nil
+	}
+	return strings.Repeat(padding, width-len(s)) + s, nil
+}
+
+// round(x) gives an integer, round(x, digits) a float
+// with the given number of decimals
+func builtinRound(args []interface{}) (interface{}, error) {
+	x, err := numberArg("round", args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 1 {
+		return int(math.Round(x)), nil
+	}
+	digits, err := intArg("round", args[1])
+	if err != nil {
+		return nil, err
+	}
+	pow := math.Pow(10, float64(digits))
+	return math.Round(x*pow) / pow, nil
+}
+
+func roundingFunc(name string, f func(float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		x, err := numberArg(name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return int(f(x)), nil
+	}
+}
+
+// min and max take either numbers or a single array of numbers,
+// the result keeps the type of the number chosen
+func extremumFunc(name string, better func(x, y float64) bool) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if elts, ok := arrayElements(args[0]); ok && len(args) == 1 {
+			args = elts
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("%s of an empty array", name)
+		}
+		var best interface{}
+		var bestVal float64
+		for _, arg := range args {
+			x, err := numberArg(name, arg)
+			if err != nil {
+				return nil, err
+			}
+			if best == nil || better(x, bestVal) {
+				best, bestVal = arg, x
+			}
+		}
+		return best, nil
+	}
+}
+
+func builtinAbs(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		if v < 0 {
+			return -v, nil
+		}
+		return v, nil
+	case float64:
+		return math.Abs(v), nil
+	}
+	return nil, fmt.Errorf("abs expects a number, got %v", args[0])
+}
+
+func builtinInt(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		return v, nil
+	case float64:
+		return int(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			return i, nil
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return int(f), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %v to an integer", args[0])
+}
+
+func builtinFloat(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case string:
+		if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			return f, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %v to a float", args[0])
+}
+
+func builtinStr(args []interface{}) (interface{}, error) {
+	return valueToString(args[0]), nil
+}
+
+func builtinBool(args []interface{}) (interface{}, error) {
+	switch v := args[0].(type) {
+	case bool:
+		return v, nil
+	case int:
+		return v != 0, nil
+	case float64:
+		return v != 0, nil
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert %v to a boolean", args[0])
+}
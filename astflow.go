@@ -1,6 +1,123 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+)
+
+// break, continue and return statements are propagated through the
+// execution of the nodes as errors, until they reach the loop or the
+// function call they apply to. exit is propagated up to the top level
+type breakError struct{}
+
+func (e *breakError) Error() string {
+	return "break outside of a loop"
+}
+
+type continueError struct{}
+
+func (e *continueError) Error() string {
+	return "continue outside of a loop"
+}
+
+type returnError struct {
+	val interface{}
+}
+
+func (e *returnError) Error() string {
+	return "return outside of a function"
+}
+
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit %d", e.code)
+}
+
+// Returned by the statement running when Ctrl-C is pressed, it
+// cannot be caught and stops the command up to the prompt
+type interruptError struct {
+	statement string //running when Ctrl-C was pressed, empty if not known yet
+}
+
+func (e *interruptError) Error() string {
+	if e.statement == "" {
+		return "interrupted"
+	}
+	return "interrupted during " + e.statement
+}
+
+// Set by the REPL when Ctrl-C is pressed during a command
+var interrupted atomic.Bool
+
+func checkInterrupt() error {
+	if interrupted.Load() {
+		return &interruptError{}
+	}
+	return nil
+}
+
+// Names the statement that was running in an interruption, the errors
+// of the statement stopped by Ctrl-C, such as a canceled API request,
+// are interruptions too
+func interruptedIn(statement node, err error) error {
+	if interruptErr, ok := err.(*interruptError); ok {
+		if interruptErr.statement == "" {
+			interruptErr.statement = statementText(statement)
+		}
+		return interruptErr
+	}
+	if interrupted.Load() {
+		return &interruptError{statementText(statement)}
+	}
+	return err
+}
+
+type breakNode struct{}
+
+func (n *breakNode) execute() (interface{}, error) {
+	return nil, &breakError{}
+}
+
+type continueNode struct{}
+
+func (n *continueNode) execute() (interface{}, error) {
+	return nil, &continueError{}
+}
+
+type returnNode struct {
+	expr node
+}
+
+func (n *returnNode) execute() (interface{}, error) {
+	if n.expr == nil {
+		return nil, &returnError{nil}
+	}
+	val, err := n.expr.execute()
+	if err != nil {
+		return nil, err
+	}
+	return nil, &returnError{val}
+}
+
+// Executes the body of a loop, the returned boolean
+// indicates if the loop should be exited
+func executeLoopBody(body node) (bool, error) {
+	if err := checkInterrupt(); err != nil {
+		return true, err
+	}
+	_, err := body.execute()
+	switch err.(type) {
+	case nil, *continueError:
+		return false, nil
+	case *breakError:
+		return true, nil
+	}
+	return true, err
+}
 
 type ifNode struct {
 	condition  node
@@ -33,6 +150,50 @@ func (n *ifNode) execute() (interface{}, error) {
 	return nil, nil
 }
 
+type tryNode struct {
+	tryBody     node
+	errVar      string
+	catchBody   node
+	finallyBody node
+}
+
+func isControlFlowError(err error) bool {
+	if traceErr, ok := err.(*stackTraceError); ok {
+		err = traceErr.err
+	}
+	switch err.(type) {
+	case *breakError, *continueError, *returnError, *exitError, *interruptError:
+		return true
+	}
+	return false
+}
+
+// Message of an error as seen by a catch block, errors coming
+// from a loaded script are stripped of their stack trace
+func caughtErrorMessage(err error) string {
+	if traceErr, ok := err.(*stackTraceError); ok {
+		return traceErr.err.Error()
+	}
+	return err.Error()
+}
+
+func (n *tryNode) execute() (interface{}, error) {
+	_, err := n.tryBody.execute()
+	if err != nil && n.catchBody != nil && !isControlFlowError(err) {
+		if n.errVar != "" {
+			setVar(n.errVar, caughtErrorMessage(err))
+		}
+		_, err = n.catchBody.execute()
+	}
+	if n.finallyBody != nil {
+		_, finallyErr := n.finallyBody.execute()
+		if finallyErr != nil {
+			return nil, finallyErr
+		}
+	}
+	return nil, err
+}
+
 type whileNode struct {
 	condition node
 	body      node
@@ -51,10 +212,13 @@ func (n *whileNode) execute() (interface{}, error) {
 		if !condition {
 			break
 		}
-		_, err = n.body.execute()
+		stop, err := executeLoopBody(n.body)
 		if err != nil {
 			return nil, err
 		}
+		if stop {
+			break
+		}
 	}
 	return nil, nil
 }
@@ -83,10 +247,13 @@ func (n *forNode) execute() (interface{}, error) {
 		if !condition {
 			break
 		}
-		_, err = n.body.execute()
+		stop, err := executeLoopBody(n.body)
 		if err != nil {
 			return nil, err
 		}
+		if stop {
+			break
+		}
 		_, err = n.incrementor.execute()
 		if err != nil {
 			return nil, err
@@ -106,19 +273,42 @@ func (n *forArrayNode) execute() (interface{}, error) {
 	if err != nil {
 		return nil, err
 	}
-	arr, ok := val.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("only an array can be iterated")
+	var arr []interface{}
+	switch v := val.(type) {
+	case []interface{}:
+		arr = v
+	case []float64:
+		for _, x := range v {
+			arr = append(arr, x)
+		}
+	case []string:
+		for _, s := range v {
+			arr = append(arr, s)
+		}
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			arr = append(arr, key)
+		}
+	default:
+		return nil, fmt.Errorf("only an array or a map can be iterated")
 	}
 	for _, v := range arr {
 		_, err := (&assignNode{n.variable, &valueNode{v}}).execute()
 		if err != nil {
 			return nil, err
 		}
-		_, err = n.body.execute()
+		stop, err := executeLoopBody(n.body)
 		if err != nil {
 			return nil, err
 		}
+		if stop {
+			break
+		}
 	}
 	return nil, nil
 }
@@ -155,10 +345,13 @@ func (n *forRangeNode) execute() (interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
-		_, err = n.body.execute()
+		stop, err := executeLoopBody(n.body)
 		if err != nil {
 			return nil, err
 		}
+		if stop {
+			break
+		}
 	}
 	return nil, nil
 }
@@ -5,6 +5,7 @@ import (
 	l "cli/logger"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type floatLeaf struct {
@@ -23,6 +24,16 @@ func (l intLeaf) execute() (interface{}, error) {
 	return l.val, nil
 }
 
+// A number followed by a unit, converted when it
+// is given to an attribute of an object
+type quantityLeaf struct {
+	val cmd.Quantity
+}
+
+func (l quantityLeaf) execute() (interface{}, error) {
+	return l.val, nil
+}
+
 func numToString(num any) string {
 	switch v := num.(type) {
 	case int:
@@ -104,24 +115,82 @@ func (a *arithNode) execute() (interface{}, error) {
 			return nil, fmt.Errorf("invalid operator for float operands")
 		}
 	}
-	if leftString || rightString {
-		if !leftString && (leftFloat || leftInt) {
-			leftStringVal = numToString(lv)
+	if a.op == "*" && (leftString && rightInt || leftInt && rightString) {
+		//String repetition : "-" * 10
+		if leftInt {
+			leftStringVal, rightIntVal = rightStringVal, leftIntVal
 		}
-		if !rightString && (rightFloat || rightInt) {
-			rightStringVal = numToString(rv)
+		if rightIntVal < 0 {
+			return nil, fmt.Errorf("a string cannot be repeated a negative number of times")
 		}
+		return strings.Repeat(leftStringVal, rightIntVal), nil
+	}
+	if leftString || rightString {
 		switch a.op {
 		case "+":
-			return leftStringVal + rightStringVal, nil
+			//The other operand is converted to a string
+			return valueToString(lv) + valueToString(rv), nil
 		default:
 			return nil, fmt.Errorf("invalid operator for string operands")
 		}
 	}
+	if _, ok := lv.(cmd.Quantity); ok {
+		return quantityArith(a.op, lv, rv)
+	}
+	if _, ok := rv.(cmd.Quantity); ok {
+		return quantityArith(a.op, lv, rv)
+	}
 	l.GetWarningLogger().Println("Invalid arithmetic operation attempted")
 	return nil, fmt.Errorf("invalid arithmetic operation attempted")
 }
 
+// Computes with numbers followed by a unit : they are added to each other
+// in the unit of the left operand, and scaled by numbers without unit
+func quantityArith(op string, lv, rv interface{}) (interface{}, error) {
+	left, leftQuantity := lv.(cmd.Quantity)
+	right, rightQuantity := rv.(cmd.Quantity)
+	if leftQuantity && rightQuantity {
+		rightVal, err := cmd.ConvertLength(right, left.Unit)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "+":
+			return cmd.Quantity{Value: left.Value + rightVal, Unit: left.Unit}, nil
+		case "-":
+			return cmd.Quantity{Value: left.Value - rightVal, Unit: left.Unit}, nil
+		case "/":
+			if rightVal == 0. {
+				return nil, fmt.Errorf("cannot divide by 0")
+			}
+			return left.Value / rightVal, nil
+		}
+		return nil, fmt.Errorf("invalid operator for operands with units")
+	}
+	if !leftQuantity {
+		if op != "*" {
+			return nil, fmt.Errorf("invalid operator between a number without unit and %v", right)
+		}
+		left, rv = right, lv
+	}
+	if !checkTypeAreNumeric(rv, rv) {
+		return nil, fmt.Errorf("invalid operand for %v : %v", left, rv)
+	}
+	factor, _ := getFloat(rv)
+	switch op {
+	case "*":
+		return cmd.Quantity{Value: left.Value * factor, Unit: left.Unit}, nil
+	case "/":
+		if factor == 0. {
+			return nil, fmt.Errorf("cannot divide by 0")
+		}
+		return cmd.Quantity{Value: left.Value / factor, Unit: left.Unit}, nil
+	case "+", "-":
+		return nil, fmt.Errorf("the unit of %v is missing", rv)
+	}
+	return nil, fmt.Errorf("invalid operator for operands with units")
+}
+
 type negateNode struct {
 	val node
 }
@@ -139,5 +208,8 @@ func (n *negateNode) execute() (interface{}, error) {
 	if isFloat {
 		return -floatVal, nil
 	}
+	if q, ok := v.(cmd.Quantity); ok {
+		return cmd.Quantity{Value: -q.Value, Unit: q.Unit}, nil
+	}
 	return nil, fmt.Errorf("cannot negate non numeric value")
 }
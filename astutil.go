@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"reflect"
+	"strings"
 )
 
 func checkTypesAreSame(x, y interface{}) bool {
@@ -62,6 +63,62 @@ func getFloat(unk interface{}) (float64, error) {
 	return fv.Float(), nil
 }
 
+// Tells whether v is an array of numbers of one of the given lengths,
+// the numbers may be followed by a unit (600mm, 42U...)
+func isVector(v interface{}, lengths ...int) bool {
+	var length int
+	switch v := v.(type) {
+	case []float64:
+		length = len(v)
+	case []interface{}:
+		for _, elt := range v {
+			switch elt.(type) {
+			case int, float64, cmd.Quantity:
+			default:
+				return false
+			}
+		}
+		length = len(v)
+	default:
+		return false
+	}
+	for _, l := range lengths {
+		if length == l {
+			return true
+		}
+	}
+	return false
+}
+
+// Expands the braces of a path, then its wildcards (*, ?, [abc] and **)
+// against the hierarchy of objects, other paths are returned as is
+func expandPath(p string) ([]string, error) {
+	if !isPathPattern(p) {
+		return []string{p}, nil
+	}
+	paths, err := cmd.ExpandBraces(p)
+	if err != nil {
+		return nil, err
+	}
+	expanded := []string{}
+	for _, path := range paths {
+		if !cmd.HasWildcards(path) {
+			expanded = append(expanded, path)
+			continue
+		}
+		matches, err := cmd.ExpandPath(path, cmd.FetchNodesAtLevel)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+func isPathPattern(p string) bool {
+	return p != "_" && (cmd.HasWildcards(p) || strings.Contains(p, "{"))
+}
+
 // Open a file and return the JSON in the file
 // Used by EasyPost, EasyUpdate and Load Template
 func fileToJSON(path string) map[string]interface{} {
@@ -0,0 +1,500 @@
+package main
+
+//This file implements the static checker of OCLI scripts
+//(ocli --check script.ocli), it parses a script and looks
+//for errors without executing it nor contacting the API
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type checker struct {
+	vars     map[string]bool
+	funcs    map[string]int //function name -> number of parameters, -1 if it varies
+	loaded   map[string]bool
+	issues   []string
+	filename string
+	line     parsedLine
+}
+
+func newChecker() *checker {
+	return &checker{
+		vars:   map[string]bool{"argv": true, "argc": true},
+		funcs:  map[string]int{},
+		loaded: map[string]bool{},
+	}
+}
+
+func (c *checker) report(format string, args ...any) {
+	msg := fmt.Sprintf("%s:%s: %s", c.filename,
+		lineRangeString(c.line.lineNumber, c.line.endLineNumber), fmt.Sprintf(format, args...))
+	c.issues = append(c.issues, msg)
+}
+
+// Returns the nodes directly contained in a node
+func subNodes(n node) []node {
+	switch n := n.(type) {
+	case *ast:
+		return n.statements
+	case *funcDefNode:
+		return []node{n.body}
+	case *funcCallNode:
+		return n.args
+	case *arrNode:
+		return n.nodes
+	case *focusNode:
+		return []node{n.path}
+	case *cdNode:
+		return []node{n.path}
+	case *lsNode:
+		return []node{n.path}
+	case *lsAttrNode:
+		return []node{n.path}
+	case *getUNode:
+		return []node{n.path, n.u}
+	case *getSlotNode:
+		return []node{n.path, n.slot}
+	case *loadNode:
+		return []node{n.path}
+	case *debugScriptNode:
+		return []node{n.path}
+	case *saveSessionNode:
+		return []node{n.path}
+	case *loadSessionNode:
+		return []node{n.path}
+	case *loadTemplateNode:
+		return []node{n.path}
+	case *printNode:
+		return []node{n.expr}
+	case *deleteObjNode:
+		return []node{n.path}
+	case *isEntityDrawableNode:
+		return []node{n.path}
+	case *isAttrDrawableNode:
+		return []node{n.path}
+	case *getObjectNode:
+		return []node{n.path}
+	case *selectObjectNode:
+		return []node{n.path}
+	case *updateObjNode:
+		return append([]node{n.path}, n.values...)
+	case *lsObjNode:
+		return []node{n.path}
+	case *treeNode:
+		return []node{n.path}
+	case *drawNode:
+		return []node{n.path}
+	case *undrawNode:
+		return []node{n.path}
+	case *selectChildrenNode:
+		return n.paths
+	case *unsetAttrNode:
+		return []node{n.path, n.index}
+	case *setEnvNode:
+		return []node{n.expr}
+	case *hierarchyNode:
+		return []node{n.path}
+	case *createManyNode:
+		return []node{n.create}
+	case *createTenantNode:
+		return []node{n.path, n.color}
+	case *createSiteNode:
+		return []node{n.path}
+	case *createBuildingNode:
+		return []node{n.path, n.posXY, n.rotation, n.sizeOrTemplate}
+	case *createRoomNode:
+		return []node{n.path, n.posXY, n.rotation, n.size, n.axisOrientation, n.floorUnit, n.template}
+	case *createRackNode:
+		return []node{n.path, n.pos, n.sizeOrTemplate, n.orientation}
+	case *createDeviceNode:
+		return []node{n.path, n.posUOrSlot, n.sizeUOrTemplate, n.side}
+	case *createGroupNode:
+		return append([]node{n.path}, n.paths...)
+	case *createCorridorNode:
+		return []node{n.path, n.leftRack, n.rightRack, n.temp}
+	case *createOrphanNode:
+		return []node{n.path, n.template}
+	case *uiHighlightNode:
+		return []node{n.path}
+	case *cameraMoveNode:
+		return []node{n.position, n.rotation}
+	case *linkObjectNode:
+		return []node{n.source, n.destination, n.slot}
+	case *unlinkObjectNode:
+		return []node{n.source, n.destination}
+	case *objReferenceNode:
+		return []node{n.index}
+	case *arrayReferenceNode:
+		return []node{n.idx}
+	case *indexNode:
+		return []node{n.container, n.idx}
+	case *fieldNode:
+		return []node{n.container}
+	case *mapNode:
+		return n.values
+	case *assignNode:
+		return []node{n.val}
+	case *returnNode:
+		return []node{n.expr}
+	case *exitNode:
+		return []node{n.code}
+	case *commandSubstNode:
+		return []node{n.command}
+	case *ifNode:
+		return []node{n.condition, n.ifBranch, n.elseBranch}
+	case *tryNode:
+		return []node{n.tryBody, n.catchBody, n.finallyBody}
+	case *whileNode:
+		return []node{n.condition, n.body}
+	case *forNode:
+		return []node{n.init, n.condition, n.incrementor, n.body}
+	case *forArrayNode:
+		return []node{n.arr, n.body}
+	case *forRangeNode:
+		return []node{n.start, n.end, n.body}
+	case *arithNode:
+		return []node{n.left, n.right}
+	case *negateNode:
+		return []node{n.val}
+	case *equalityNode:
+		return []node{n.left, n.right}
+	case *comparatorNode:
+		return []node{n.left, n.right}
+	case *logicalNode:
+		return []node{n.left, n.right}
+	case *matchNode:
+		return []node{n.left, n.right}
+	case *inNode:
+		return []node{n.elt, n.container}
+	case *conditionalNode:
+		return []node{n.condition, n.ifTrue, n.ifFalse}
+	case *negateBoolNode:
+		return []node{n.expr}
+	case *pathNode:
+		return []node{n.path}
+	}
+	return nil
+}
+
+// A constant expression only contains literals,
+// it can be evaluated without side effects
+func isConstant(n node) bool {
+	switch n := n.(type) {
+	case *intLeaf, *floatLeaf, *quantityLeaf, *boolLeaf, *strLeaf:
+		return true
+	case *arrNode, *mapNode, *arithNode, *negateNode, *equalityNode,
+		*comparatorNode, *logicalNode, *negateBoolNode, *matchNode, *inNode, *conditionalNode:
+		for _, sub := range subNodes(n) {
+			if !isConstant(sub) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func constValue(n node) (any, bool) {
+	if n == nil || !isConstant(n) {
+		return nil, false
+	}
+	val, err := n.execute()
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+// Collects the variables and functions defined in a script
+// and in the scripts it loads with a literal path
+func (c *checker) collect(n node) {
+	if n == nil {
+		return
+	}
+	switch n := n.(type) {
+	case *assignNode:
+		c.vars[n.variable] = true
+	case *funcDefNode:
+		if arity, ok := c.funcs[n.name]; ok && arity != len(n.params) {
+			c.funcs[n.name] = -1
+		} else {
+			c.funcs[n.name] = len(n.params)
+		}
+		for _, param := range n.params {
+			c.vars[param] = true
+		}
+	case *forArrayNode:
+		c.vars[n.variable] = true
+	case *forRangeNode:
+		c.vars[n.variable] = true
+	case *tryNode:
+		if n.errVar != "" {
+			c.vars[n.errVar] = true
+		}
+	case *loadNode:
+		if path, ok := constValue(n.path); ok {
+			c.collectFile(path.(string))
+		}
+	}
+	for _, sub := range subNodes(n) {
+		c.collect(sub)
+	}
+}
+
+func (c *checker) collectFile(path string) {
+	if c.loaded[path] {
+		return
+	}
+	c.loaded[path] = true
+	lines, _ := parseFile(path)
+	for _, line := range lines {
+		c.collect(line.root)
+	}
+}
+
+func checkVector(val any, name string, sizes ...int) error {
+	if isVector(val, sizes...) {
+		return nil
+	}
+	return fmt.Errorf("%s should be a vector%d", name, sizes[0])
+}
+
+func checkNumber(val any, name string) error {
+	if _, err := getFloat(val); err != nil {
+		return fmt.Errorf("%s should be a number", name)
+	}
+	return nil
+}
+
+func checkKeyWord(val any, name string, keywords []string) error {
+	if !AssertInStringValues(val, keywords) {
+		return fmt.Errorf("invalid %s %v, expected one of %v", name, val, keywords)
+	}
+	return nil
+}
+
+func checkSizeOrTemplate(val any, name string) error {
+	if IsString(val) {
+		//templates can only be checked online
+		return nil
+	}
+	return checkVector(val, name, 3)
+}
+
+// Applies a check to a node if its value is known statically
+func (c *checker) checkConst(n node, check func(any) error) {
+	val, ok := constValue(n)
+	if !ok {
+		return
+	}
+	if err := check(val); err != nil {
+		c.report("%s", err.Error())
+	}
+}
+
+// Checks the values given to a command, as they would be at runtime
+func (c *checker) checkCommand(n node) {
+	switch n := n.(type) {
+	case *ifNode:
+		c.checkConst(n.condition, func(v any) error {
+			_, err := AssertBool(&n.condition, "condition")
+			return err
+		})
+	case *whileNode:
+		c.checkConst(n.condition, func(v any) error {
+			_, err := AssertBool(&n.condition, "condition")
+			return err
+		})
+	case *forNode:
+		c.checkConst(n.condition, func(v any) error {
+			_, err := AssertBool(&n.condition, "condition")
+			return err
+		})
+	case *forRangeNode:
+		c.checkConst(n.start, func(v any) error {
+			_, err := AssertInt(&n.start, "start index")
+			return err
+		})
+		c.checkConst(n.end, func(v any) error {
+			_, err := AssertInt(&n.end, "end index")
+			return err
+		})
+	case *getUNode:
+		c.checkConst(n.u, func(v any) error {
+			_, err := AssertInt(&n.u, "u")
+			return err
+		})
+	case *createTenantNode:
+		c.checkConst(n.color, func(v any) error {
+			if _, ok := AssertColor(v); !ok {
+				return fmt.Errorf("please provide a valid 6 length hex value for the color")
+			}
+			return nil
+		})
+	case *createBuildingNode:
+		c.checkConst(n.posXY, func(v any) error { return checkVector(v, "posXY", 2) })
+		c.checkConst(n.rotation, func(v any) error { return checkNumber(v, "rotation") })
+		c.checkConst(n.sizeOrTemplate, func(v any) error { return checkSizeOrTemplate(v, "size") })
+	case *createRoomNode:
+		c.checkConst(n.posXY, func(v any) error { return checkVector(v, "posXY", 2) })
+		c.checkConst(n.rotation, func(v any) error { return checkNumber(v, "rotation") })
+		c.checkConst(n.size, func(v any) error { return checkVector(v, "size", 3) })
+		c.checkConst(n.axisOrientation, func(v any) error {
+			return checkKeyWord(v, "axis orientation", []string{"+x+y", "+x-y", "-x-y", "-x+y"})
+		})
+		c.checkConst(n.floorUnit, func(v any) error {
+			return checkKeyWord(v, "floor unit", []string{"t", "m", "f"})
+		})
+	case *createRackNode:
+		c.checkConst(n.pos, func(v any) error { return checkVector(v, "position", 2, 3) })
+		c.checkConst(n.sizeOrTemplate, func(v any) error { return checkSizeOrTemplate(v, "size") })
+		c.checkConst(n.orientation, func(v any) error {
+			return checkKeyWord(v, "rack orientation", []string{"front", "rear", "left", "right"})
+		})
+	case *createDeviceNode:
+		c.checkConst(n.side, func(v any) error {
+			return checkKeyWord(v, "side", []string{"front", "rear", "frontflipped", "rearflipped"})
+		})
+	case *createCorridorNode:
+		c.checkConst(n.temp, func(v any) error {
+			return checkKeyWord(v, "temperature", []string{"warm", "cold"})
+		})
+	case *cameraMoveNode:
+		c.checkConst(n.position, func(v any) error { return checkVector(v, "position", 3) })
+		c.checkConst(n.rotation, func(v any) error { return checkVector(v, "rotation", 2) })
+	case *updateObjNode:
+		boolInteractVals := []string{"content", "alpha", "tilesName", "tilesColor", "U", "slots", "localCS"}
+		if AssertInStringValues(n.attr, boolInteractVals) && len(n.values) > 0 {
+			c.checkConst(n.values[0], func(v any) error {
+				if !IsBool(v) {
+					return fmt.Errorf("boolean value expected for %s", n.attr)
+				}
+				return nil
+			})
+		}
+	}
+}
+
+func isTerminator(n node) bool {
+	switch n.(type) {
+	case *breakNode, *continueNode, *returnNode, *exitNode:
+		return true
+	}
+	return false
+}
+
+func (c *checker) checkVar(name string) {
+	if _, err := strconv.Atoi(name); err == nil {
+		//script argument ($1, $2...)
+		return
+	}
+	//${rack.attributes.height} : only the variable itself can be checked
+	name, _, _ = strings.Cut(name, ".")
+	if !c.vars[name] {
+		c.report("undefined variable %s", name)
+	}
+}
+
+func (c *checker) check(n node) {
+	if n == nil {
+		return
+	}
+	if isConstant(n) {
+		if _, err := n.execute(); err != nil {
+			c.report("%s", err.Error())
+		}
+		return
+	}
+	c.checkCommand(n)
+	switch n := n.(type) {
+	case *ast:
+		for i, statement := range n.statements {
+			if isTerminator(statement) {
+				for _, next := range n.statements[i+1:] {
+					if next != nil {
+						c.report("unreachable code after %s", statementName(statement))
+						break
+					}
+				}
+			}
+		}
+	case *symbolReferenceNode:
+		c.checkVar(n.va)
+	case *objReferenceNode:
+		c.checkVar(n.va)
+	case *arrayReferenceNode:
+		c.checkVar(n.variable)
+	case *lenNode:
+		c.checkVar(n.variable)
+	case *formatStringNode:
+		for _, varDeref := range n.varsDeref {
+			c.checkVar(varDeref.va)
+		}
+	case *funcCallNode:
+		arity, ok := c.funcs[n.name]
+		builtin, isBuiltin := builtinFuncs[n.name]
+		if !ok && isBuiltin {
+			if err := builtin.checkArity(n.name, len(n.args)); err != nil {
+				c.report("%s", err.Error())
+			}
+		} else if !ok {
+			c.report("undefined function %s", n.name)
+		} else if arity != -1 && arity != len(n.args) {
+			c.report("function %s expects %d argument(s), %d given", n.name, arity, len(n.args))
+		}
+	}
+	for _, sub := range subNodes(n) {
+		c.check(sub)
+	}
+}
+
+func statementName(n node) string {
+	switch n.(type) {
+	case *breakNode:
+		return "break"
+	case *continueNode:
+		return "continue"
+	case *returnNode:
+		return "return"
+	}
+	return "exit"
+}
+
+// Statically checks an OCLI script, returns the list of issues found
+func CheckFile(path string) []string {
+	c := newChecker()
+	c.filename = filepath.Base(path)
+	lines, err := parseFile(path)
+	if err != nil {
+		if _, ok := err.(*fileParseError); !ok {
+			return []string{err.Error()}
+		}
+		c.issues = append(c.issues, err.Error())
+	}
+	c.loaded[path] = true
+	for _, line := range lines {
+		c.collect(line.root)
+	}
+	for _, line := range lines {
+		c.line = line
+		c.check(line.root)
+	}
+	return c.issues
+}
+
+// Runs the static checker on a script, prints the issues
+// and returns the exit code of the program
+func RunCheck(path string) int {
+	issues := CheckFile(path)
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+	if len(issues) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func checkScript(script string, t *testing.T) []string {
+	path := filepath.Join(t.TempDir(), "script.ocli")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatalf("cannot write script : %s", err.Error())
+	}
+	return CheckFile(path)
+}
+
+func assertIssues(issues []string, expected []string, t *testing.T) {
+	if len(issues) != len(expected) {
+		t.Errorf("unexpected issues : %v", issues)
+		return
+	}
+	for i := range expected {
+		if !strings.Contains(issues[i], expected[i]) {
+			t.Errorf("issue %q does not contain %q", issues[i], expected[i])
+		}
+	}
+}
+
+func TestCheckValidScript(t *testing.T) {
+	script := ".var:i=0\n" +
+		"alias f(x) {\n  return $x + $i\n}\n" +
+		"while $i < 3 {\n  .var:i=f($i)\n}\n" +
+		"+rk:/P/S/B/R/R1@[1,2]@[60,120,42]@front\n"
+	assertIssues(checkScript(script, t), []string{}, t)
+}
+
+// The numbers of the vectors may have units
+func TestCheckUnitVectors(t *testing.T) {
+	script := "+bd:/P/S/B@[0, 0]@0@[50m, 30m, 5m]\n" +
+		"+rk:/P/S/B/R/R1@[1,2]@[60cm, 120cm, 42U]@front\n" +
+		"for i in 1..3 {\n  +rk:/P/S/B/R/A0${i}@[$i * 2, 2]@[60cm, 120cm, 42U]@front\n}\n"
+	assertIssues(checkScript(script, t), []string{}, t)
+	script = "+rk:/P/S/B/R/R1@[1,2]@[60cm, 120cm]@front\n"
+	assertIssues(checkScript(script, t), []string{"should be a vector3"}, t)
+}
+
+func TestCheckErrors(t *testing.T) {
+	script := "alias f(x) {\n  return $x\n  print \"never\"\n}\n" +
+		"f(1, 2)\n" +
+		"g\n" +
+		"print $nope\n" +
+		"+rk:/P/S/B/R/R1@[1,2]@[60,120,42]@\"fornt\"\n" +
+		"if 3 {pwd}\n"
+	expected := []string{
+		"script.ocli:1-4: unreachable code after return",
+		"script.ocli:5: function f expects 1 argument(s), 2 given",
+		"script.ocli:6: undefined function g",
+		"script.ocli:7: undefined variable nope",
+		"script.ocli:8: invalid rack orientation fornt",
+		"script.ocli:9: condition should be a boolean value",
+	}
+	assertIssues(checkScript(script, t), expected, t)
+}
+
+func TestCheckScriptArgs(t *testing.T) {
+	script := "print $argc\nprint $argv[0]\nprint $1\nexit 2\n"
+	assertIssues(checkScript(script, t), []string{}, t)
+}
+
+func TestCheckBuiltinFuncs(t *testing.T) {
+	script := "print pad(3, 2)\nprint upper(\"a\", \"b\")\n"
+	assertIssues(checkScript(script, t), []string{
+		"script.ocli:2: function upper expects 1 argument(s), 2 given",
+	}, t)
+}
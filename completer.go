@@ -304,6 +304,9 @@ func GetPrefixCompleter() *readline.PrefixCompleter {
 			readline.PcItem("for", false),
 			readline.PcItem("while", false),
 			readline.PcItem(".cmds", false),
+			readline.PcItem("debug", false),
+			readline.PcItem("save-session", false),
+			readline.PcItem("load-session", false),
 			readline.PcItem("lsog", false),
 			readline.PcItem("env", false),
 			readline.PcItem("link", false),
@@ -332,6 +335,7 @@ func GetPrefixCompleter() *readline.PrefixCompleter {
 			readline.PcItem(".var", false),
 			readline.PcItem("undraw", false),
 			readline.PcItem("unset", false),
+			readline.PcItem("cache", false),
 			readline.PcItem("=", false),
 			readline.PcItem("-", false),
 			readline.PcItem("+", false),
@@ -374,6 +378,12 @@ func GetPrefixCompleter() *readline.PrefixCompleter {
 		readline.PcItem("selection", false),
 		readline.PcItem(".cmds:", true,
 			readline.PcItemDynamic(ListLocal(""), false)),
+		readline.PcItem("debug", true,
+			readline.PcItemDynamic(ListLocal(""), false)),
+		readline.PcItem("save-session", true,
+			readline.PcItemDynamic(ListLocal(""), false)),
+		readline.PcItem("load-session", true,
+			readline.PcItemDynamic(ListLocal(""), false)),
 
 		readline.PcItem(".template:", true,
 			readline.PcItemDynamic(ListLocal(""), false)),
@@ -469,6 +479,10 @@ func GetPrefixCompleter() *readline.PrefixCompleter {
 
 		readline.PcItem("ui.clearcache", false),
 
+		readline.PcItem("cache", true,
+			readline.PcItem("clear", false),
+			readline.PcItem("stats", false)),
+
 		readline.PcItem(">", true,
 			readline.PcItemDynamic(ListEntities(""), false)),
 		readline.PcItem("hc", true,
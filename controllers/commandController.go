@@ -1,15 +1,17 @@
 package controllers
 
 import (
+	"cli/api"
 	"cli/logger"
 	l "cli/logger"
 	"cli/models"
 	u "cli/utils"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
@@ -26,61 +28,41 @@ func PWD() string {
 }
 
 func PostObj(ent int, entity string, data map[string]interface{}) (map[string]interface{}, error) {
-	var respMap map[string]interface{}
-	resp, e := models.Send("POST",
-		State.APIURL+"/api/"+entity+"s", GetKey(), data)
-
-	respMap = ParseResponse(resp, e, "POST")
-	if respMap == nil {
-		return nil, fmt.Errorf("Invalid Response received from API")
+	obj, err := API().CreateObject(entity, data)
+	if err != nil {
+		return nil, apiError(err)
+	}
+	//Print success message
+	if State.DebugLvl > NONE {
+		println("Successfully created " + entity)
 	}
 
-	if resp.StatusCode == http.StatusCreated && respMap["status"].(bool) == true {
-		//Print success message
-		if State.DebugLvl > NONE {
-			println(string(respMap["message"].(string)))
-		}
-
-		//If ent is in State.ObjsForUnity then notify Unity
-		if IsInObjForUnity(entity) == true {
-			entInt := EntityStrToInt(entity)
-			InformUnity("PostObj", entInt,
-				map[string]interface{}{"type": "create", "data": respMap["data"]})
-		}
-
-		return respMap["data"].(map[string]interface{}), nil
+	//If ent is in State.ObjsForUnity then notify Unity
+	if IsInObjForUnity(entity) == true {
+		entInt := EntityStrToInt(entity)
+		InformUnity("PostObj", entInt,
+			map[string]interface{}{"type": "create", "data": obj.Fields()})
 	}
-	return nil, fmt.Errorf(APIErrorPrefix + respMap["message"].(string))
+
+	return obj.Fields(), nil
 }
 
 // Calls API's Validation
 func ValidateObj(data map[string]interface{}, ent string, silence bool) bool {
-	var respMap map[string]interface{}
-	resp, e := models.Send("POST",
-		State.APIURL+"/api/validate/"+ent+"s", GetKey(), data)
-
-	respMap = ParseResponse(resp, e, "POST")
-	if respMap == nil {
-		if State.DebugLvl > 1 {
-			println("Received invalid response from API")
-		}
+	message, err := API().ValidateObject(ent, data)
+	if err != nil {
+		println("Error: ", apiError(err).Error())
+		println()
 		return false
 	}
-
-	if resp.StatusCode == http.StatusOK && respMap["status"].(bool) == true {
-		//Print success message
-		if silence == false {
-			println(string(respMap["message"].(string)))
-		}
-
-		return true
+	//Print success message
+	if silence == false {
+		println(message)
 	}
-	println("Error: ", string(APIErrorPrefix+respMap["message"].(string)))
-	println()
-	return false
+	return true
 }
 
-func DeleteObj(Path string) bool {
+func DeleteObj(Path string) error {
 	if Path == "" || Path == "." {
 		Path = State.CurrPath
 
@@ -98,46 +80,35 @@ func DeleteObj(Path string) bool {
 		}
 
 		l.GetWarningLogger().Println("Error while deleting Object!")
-		return false
+		return fmt.Errorf("cannot delete %s : object not found", Path)
 	}
 
 	//Make sure we are deleting an object and not
 	//an aggregate call result
-	if objJSON["id"] == nil {
-		println("Error: Cannot delete object")
-		return false
+	id, ok := objJSON["id"].(string)
+	if !ok {
+		return fmt.Errorf("cannot delete %s : it is not an object", Path)
 	}
 	entities := path.Base(path.Dir(GETURL))
-	URL := State.APIURL + "/api/" + entities + "/" + objJSON["id"].(string)
+	entity := entities[:len(entities)-1]
 
 	//Get curr object path to check if it is equivalent
 	//to user received path
 	_, currPathURL := GetObject(State.CurrPath, true)
 
-	resp, e := models.Send("DELETE", URL, GetKey(), nil)
-	if e != nil {
+	if e := API().DeleteObject(entity, id); e != nil {
 		if State.DebugLvl > 0 {
 			println("Error while deleting Object!")
 		}
 
 		l.GetWarningLogger().Println("Error while deleting Object!", e)
-		return false
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusNoContent {
-		println("Success")
-	} else {
-		if State.DebugLvl > 0 {
-			println("Error while deleting Object!")
-		}
-		l.GetWarningLogger().Println("Error while deleting Object!", e)
-		return false
+		return fmt.Errorf("cannot delete %s : %s", Path, e)
 	}
+	println("Success")
 
-	entity := entities[:len(entities)-1]
 	if IsInObjForUnity(entity) == true {
 		InformUnity("DeleteObj", -1,
-			map[string]interface{}{"type": "delete", "data": objJSON["id"].(string)})
+			map[string]interface{}{"type": "delete", "data": id})
 	}
 
 	//Check if deleted object is current path
@@ -145,7 +116,7 @@ func DeleteObj(Path string) bool {
 		CD("..")
 	}
 
-	return true
+	return nil
 }
 
 func DeleteSelection() bool {
@@ -153,7 +124,7 @@ func DeleteSelection() bool {
 	if State.ClipBoard != nil {
 		for i := range *State.ClipBoard {
 			println("Going to delete object: ", (*(State.ClipBoard))[i])
-			if res = DeleteObj((*(State.ClipBoard))[i]); res != true {
+			if res = DeleteObj((*(State.ClipBoard))[i]) == nil; res != true {
 				l.GetWarningLogger().Println("Couldn't delete obj in selection: ",
 					(*(State.ClipBoard))[i])
 				if State.DebugLvl > 0 {
@@ -171,70 +142,56 @@ func DeleteSelection() bool {
 
 // Search for objects
 func SearchObjects(entity string, data map[string]interface{}) []map[string]interface{} {
-	var jsonResp map[string]interface{}
-	URL := State.APIURL + "/api/" + entity + "s?"
-
+	query := url.Values{}
 	for i, k := range data {
-		if i == "attributes" {
-			for j, _ := range k.(map[string]string) {
-				URL = URL + "&" + j + "=" + data[i].(map[string]string)[j]
+		if attrs, ok := k.(map[string]string); ok && i == "attributes" {
+			for j := range attrs {
+				query.Set(j, attrs[j])
 			}
 		} else {
-			URL = URL + "&" + i + "=" + string(data[i].(string))
+			query.Set(i, fmt.Sprint(k))
 		}
 	}
 
-	//println("Here is URL: ", URL)
-	l.GetInfoLogger().Println("Search query URL:", URL)
+	l.GetInfoLogger().Println("Search query:", entity, query.Encode())
 
-	resp, e := models.Send("GET", URL, GetKey(), nil)
-	jsonResp = ParseResponse(resp, e, "Search")
-	if jsonResp == nil {
+	found, e := API().SearchObjects(entity, query)
+	if e != nil {
 		if State.DebugLvl > 1 {
-			println("Received invalid response from API")
+			println("Error: " + apiError(e).Error())
 		}
 		return nil
 	}
 
-	if resp.StatusCode == http.StatusOK {
-		obj := jsonResp["data"].(map[string]interface{})["objects"].([]interface{})
-		objects := []map[string]interface{}{}
-		for idx := range obj {
-			println()
-			println()
-			println("OBJECT: ", idx)
-			displayObject(obj[idx].(map[string]interface{}))
-			objects = append(objects, obj[idx].(map[string]interface{}))
-			println()
-		}
-
-		if IsInObjForUnity(entity) {
-			resp := map[string]interface{}{"type": "search", "data": objects}
-			InformUnity("Search", -1, resp)
-		}
-
-		return objects
+	objects := []map[string]interface{}{}
+	for idx := range found {
+		println()
+		println()
+		println("OBJECT: ", idx)
+		displayObject(found[idx].Fields())
+		objects = append(objects, found[idx].Fields())
+		println()
+	}
 
+	if IsInObjForUnity(entity) {
+		resp := map[string]interface{}{"type": "search", "data": objects}
+		InformUnity("Search", -1, resp)
 	}
-	return nil
+
+	return objects
 }
 
 // Check if the object exists in API
 func CheckObject(path string, silenced bool) (string, bool) {
 	pathSplit := PreProPath(path)
-	paths := OnlinePathResolve(pathSplit)
-
-	for i := range paths {
-		resp, e := models.Send("OPTIONS", paths[i], GetKey(), nil)
-		if e != nil {
-			if !silenced {
-				println(paths[i])
-				println(e.Error())
-			}
-
-		}
-		if resp.StatusCode == http.StatusOK {
-			return paths[i], true
+	result, failures := resolvePath(pathSplit, existsAt)
+	if result != nil {
+		return result.URL, true
+	}
+	for _, failure := range failures {
+		if !silenced {
+			println(failure.URL)
+			println(failure.err.Error())
 		}
 	}
 	return "", false
@@ -244,33 +201,18 @@ func CheckObject(path string, silenced bool) (string, bool) {
 // Useful for LS since
 // otherwise the terminal would be polluted by debug statements
 func GetObject(path string, silenced bool) (map[string]interface{}, string) {
-	var data map[string]interface{}
-
 	pathSplit := PreProPath(path)
-	paths := OnlinePathResolve(pathSplit)
-
-	for i := range paths {
-		resp, e := models.Send("GET", paths[i], GetKey(), nil)
-		if e != nil {
-			println(paths[i])
-			println(e.Error())
-		}
-		data = ParseResponse(resp, e, "GET")
-
-		if resp == nil {
-			return nil, ""
-		}
-
-		if resp.StatusCode == http.StatusOK {
-			if data["data"] != nil {
-				obj := data["data"].(map[string]interface{})
-
-				if !silenced {
-					displayObject(obj)
-				}
-			}
-			return data["data"].(map[string]interface{}), paths[i]
+	result, failures := resolvePath(pathSplit, objectAt)
+	if result != nil {
+		if !silenced {
+			displayObject(result.obj.Fields())
 		}
+		return result.obj.Fields(), result.URL
+	}
+	if len(failures) > 0 {
+		println(failures[0].URL)
+		println(failures[0].err.Error())
+		return nil, ""
 	}
 
 	//Object wasn't found
@@ -311,8 +253,6 @@ func GenUpdateJSON(m map[string]interface{}, key string, value interface{}, del
 // This function recursively applies an update to an object and
 // the rest of its subentities
 func RecursivePatch(Path, id, ent string, data map[string]interface{}) error {
-	var entities string
-	var URL string
 	println("OK. Attempting to update...")
 
 	if data != nil {
@@ -322,61 +262,40 @@ func RecursivePatch(Path, id, ent string, data map[string]interface{}) error {
 			//we don't want to update the wrong object
 			objJSON, GETURL := GetObject(Path, true)
 			if objJSON == nil {
-				l.GetWarningLogger().Println("Error while deleting Object!")
-				return fmt.Errorf("error while deleting Object")
+				l.GetWarningLogger().Println("Error while getting Object!")
+				return fmt.Errorf("error while getting Object")
 			}
-			entities = path.Base(path.Dir(GETURL))
-			URL = State.APIURL + "/api/" + entities + "/" + objJSON["id"].(string) + "/all"
-		} else {
-			entities = ent + "s"
-			URL = State.APIURL + "/api/" + entities + "/" + id + "/all"
+			entities := path.Base(path.Dir(GETURL))
+			ent = entities[:len(entities)-1]
+			id, _ = objJSON["id"].(string)
 		}
 		//GET Object
-		resp, e := models.Send("GET", URL, GetKey(), nil)
-		r := ParseResponse(resp, e, "recursive update")
-		if r == nil {
-			return fmt.Errorf("Failure while getting root object")
-		}
+		root, e := API().Hierarchy(ent, id, -1)
 		if e != nil {
-			return nil
+			return fmt.Errorf("Failure while getting root object : %s", apiError(e).Error())
 		}
-		recursivePatchAux(r["data"].(map[string]interface{}), data)
+		recursivePatchAux(root, data)
 		println("Success")
 		return nil
 	}
 	return fmt.Errorf("error! Please enter desired parameters of Object to be updated")
 }
 
-func recursivePatchAux(res, data map[string]interface{}) {
-	id := res["id"].(string)
-	ent := res["category"].(string)
-	UpdateObj("", id, ent, data, false)
-
-	if childrenJson, ok := res["children"]; ok {
-		if enfants, ok := childrenJson.([]interface{}); ok {
-			for i := range enfants {
-				if child, ok := enfants[i].(map[string]interface{}); ok {
-					//id := child["id"].(string)
-					//ent := child["entity"].(string)
-					//UpdateObj("", id, ent,data, false)
-					recursivePatchAux(child, data)
-				}
-			}
-		}
+func recursivePatchAux(res *api.Object, data map[string]interface{}) {
+	UpdateObj("", res.ID, res.Category, data, false)
+	for _, child := range res.Children {
+		recursivePatchAux(child, data)
 	}
-
 }
 
 // You can either update obj by path or by ID and entity string type
 // The deleteAndPut bool is for deleting an attribute
 func UpdateObj(Path, id, ent string, data map[string]interface{}, deleteAndPut bool) (map[string]interface{}, error) {
 	println("OK. Attempting to update...")
-	var resp *http.Response
 	var objJSON map[string]interface{}
 	var GETURL string
 
 	if data != nil {
-		var respJson map[string]interface{}
 		var URL string
 		var entities string
 
@@ -557,20 +476,16 @@ func UpdateObj(Path, id, ent string, data map[string]interface{}, deleteAndPut b
 		}
 
 		//Make the proper Update JSON
-		var e error
 		var ogData map[string]interface{}
 		if objJSON == nil {
-			r, e1 := models.Send("GET", URL, GetKey(), nil)
-			objJSON = ParseResponse(r, e1, "GET")
-			if objJSON == nil {
-				return nil, fmt.Errorf("Couldn't get object for update")
+			obj, e := API().ObjectAt(URL)
+			if e != nil {
+				return nil, fmt.Errorf("Couldn't get object for update : %s", apiError(e).Error())
 			}
-			ogData = objJSON["data"].(map[string]interface{})
+			ogData = obj.Fields()
 		} else {
 			ogData = objJSON
 		}
-		//respGet, e := models.Send("GET", URL, GetKey(), nil)
-		//ogData := ParseResponse(respGet, e, "GET")
 
 		attrs := map[string]interface{}{}
 
@@ -592,91 +507,80 @@ func UpdateObj(Path, id, ent string, data map[string]interface{}, deleteAndPut b
 			ogData["attributes"] = attrs
 		}
 
-		if deleteAndPut {
-			resp, e = models.Send("PUT", URL, GetKey(), ogData)
-		} else {
-			resp, e = models.Send("PATCH", URL, GetKey(), ogData)
+		objID, _ := ogData["id"].(string)
+		updated, e := API().UpdateObject(entities[:len(entities)-1], objID, ogData, deleteAndPut)
+		if e != nil {
+			var apiErr *api.Error
+			if errors.As(e, &apiErr) && apiErr.Message == "" {
+				msg := "Cannot update. Please ensure that your attributes " +
+					"are modifiable and try again. For more details see the " +
+					"OGREE wiki: https://github.com/ditrit/OGrEE-3D/wiki"
+				return nil, fmt.Errorf(msg)
+			}
+			return nil, apiError(e)
 		}
+		println("Success")
 
-		respJson = ParseResponse(resp, e, "UPDATE")
-		if respJson != nil {
-			if resp.StatusCode == 200 {
-				println("Success")
-
-				//Determine if Unity requires the message as
-				//Interact or Modify
-				message := map[string]interface{}{}
-				interactData := map[string]interface{}{}
-				var key string
-
-				if entities == "rooms" && (data["tilesName"] != nil || data["tilesColor"] != nil) {
-					println("Room modifier detected")
-					Disp(data)
-					message["type"] = "interact"
-
-					//Get the interactive key
-					key = determineStrKey(data, []string{"tilesName", "tilesColor"})
+		//Determine if Unity requires the message as
+		//Interact or Modify
+		message := map[string]interface{}{}
+		interactData := map[string]interface{}{}
+		var key string
 
-					interactData["id"] = ogData["id"]
-					interactData["param"] = key
-					interactData["value"] = data[key]
-					message["data"] = interactData
+		if entities == "rooms" && (data["tilesName"] != nil || data["tilesColor"] != nil) {
+			println("Room modifier detected")
+			Disp(data)
+			message["type"] = "interact"
 
-				} else if entities == "racks" && data["U"] != nil {
-					message["type"] = "interact"
-					interactData["id"] = ogData["id"]
-					interactData["param"] = "U"
-					interactData["value"] = data["U"]
-					message["data"] = interactData
+			//Get the interactive key
+			key = determineStrKey(data, []string{"tilesName", "tilesColor"})
 
-				} else if (entities == "devices" || entities == "racks") &&
-					(data["alpha"] != nil || data["slots"] != nil ||
-						data["localCS"] != nil) {
-					message["type"] = "interact"
+			interactData["id"] = ogData["id"]
+			interactData["param"] = key
+			interactData["value"] = data[key]
+			message["data"] = interactData
 
-					//Get interactive key
-					key = determineStrKey(data, []string{"alpha", "U", "slots", "localCS"})
+		} else if entities == "racks" && data["U"] != nil {
+			message["type"] = "interact"
+			interactData["id"] = ogData["id"]
+			interactData["param"] = "U"
+			interactData["value"] = data["U"]
+			message["data"] = interactData
 
-					interactData["id"] = ogData["id"]
-					interactData["param"] = key
-					interactData["value"] = data[key]
+		} else if (entities == "devices" || entities == "racks") &&
+			(data["alpha"] != nil || data["slots"] != nil ||
+				data["localCS"] != nil) {
+			message["type"] = "interact"
 
-					message["data"] = interactData
+			//Get interactive key
+			key = determineStrKey(data, []string{"alpha", "U", "slots", "localCS"})
 
-				} else if entities == "groups" && data["content"] != nil {
-					message["type"] = "interact"
-					interactData["id"] = ogData["id"]
-					interactData["param"] = "content"
-					interactData["value"] = data["content"]
+			interactData["id"] = ogData["id"]
+			interactData["param"] = key
+			interactData["value"] = data[key]
 
-					message["data"] = interactData
+			message["data"] = interactData
 
-				} else {
-					message["type"] = "modify"
-					message["data"] = respJson["data"]
-				}
+		} else if entities == "groups" && data["content"] != nil {
+			message["type"] = "interact"
+			interactData["id"] = ogData["id"]
+			interactData["param"] = "content"
+			interactData["value"] = data["content"]
 
-				entStr := entities[:len(entities)-1]
-				if IsInObjForUnity(entStr) == true {
-					entInt := EntityStrToInt(entStr)
-					InformUnity("UpdateObj", entInt, message)
-				}
+			message["data"] = interactData
 
-			} else {
-				if mInf, ok := respJson["message"]; ok {
-					if m, ok := mInf.(string); ok {
-						return nil, fmt.Errorf(APIErrorPrefix + m)
-					}
-				}
-				msg := "Cannot update. Please ensure that your attributes " +
-					"are modifiable and try again. For more details see the " +
-					"OGREE wiki: https://github.com/ditrit/OGrEE-3D/wiki"
-				return nil, fmt.Errorf(msg)
-			}
+		} else {
+			message["type"] = "modify"
+			message["data"] = updated.Fields()
+		}
 
+		entStr := entities[:len(entities)-1]
+		if IsInObjForUnity(entStr) == true {
+			entInt := EntityStrToInt(entStr)
+			InformUnity("UpdateObj", entInt, message)
 		}
 
-		data = respJson
+		data = updated.Fields()
 
 	} else {
 		println("Error! Please enter desired parameters of Object to be updated")
@@ -754,43 +658,29 @@ func UnsetInObj(Path, attr string, idx int) (map[string]interface{}, error) {
 	}
 
 	//Send to API and update Unity
-	entity := objJSON["category"].(string)
-	id := objJSON["id"].(string)
-	URL := State.APIURL + "/api/" + entity + "s/" + id
+	entity, _ := objJSON["category"].(string)
+	id, _ := objJSON["id"].(string)
 
-	resp, e := models.Send("PUT", URL, GetKey(), objJSON)
-	respJson := ParseResponse(resp, e, "UPDATE")
-	if respJson != nil {
-		if resp.StatusCode == 200 {
-			println("Success")
+	updated, e := API().UpdateObject(entity, id, objJSON, true)
+	if e != nil {
+		return nil, apiError(e)
+	}
+	println("Success")
 
-			message := map[string]interface{}{
-				"type": "modify", "data": respJson["data"]}
+	message := map[string]interface{}{
+		"type": "modify", "data": updated.Fields()}
 
-			//Update and inform unity
-			if IsInObjForUnity(entity) == true {
-				entInt := EntityStrToInt(entity)
-				InformUnity("UpdateObj", entInt, message)
-			}
-		}
+	//Update and inform unity
+	if IsInObjForUnity(entity) == true {
+		entInt := EntityStrToInt(entity)
+		InformUnity("UpdateObj", entInt, message)
 	}
 
 	return nil, nil
 }
 
 func LS(x string) []map[string]interface{} {
-	var path string
-	if x == "" || x == "." {
-		path = State.CurrPath
-
-	} else if string(x[0]) == "/" {
-		path = x
-
-	} else {
-		path = State.CurrPath + "/" + x
-	}
-
-	res := FetchJsonNodesAtLevel(path)
+	res := LSObjects(x)
 
 	//Display the objects by otherwise by name
 	//or slug for templates
@@ -802,7 +692,22 @@ func LS(x string) []map[string]interface{} {
 		}
 	}
 	return res
+}
+
+// Returns the objects listed by ls, without displaying them
+func LSObjects(x string) []map[string]interface{} {
+	var path string
+	if x == "" || x == "." {
+		path = State.CurrPath
+
+	} else if string(x[0]) == "/" {
+		path = x
+
+	} else {
+		path = State.CurrPath + "/" + x
+	}
 
+	return FetchJsonNodesAtLevel(path)
 }
 
 func Clear() {
@@ -819,9 +724,7 @@ func Clear() {
 
 func LSOG() {
 
-	//Need to add GET /api/version call data here
-	r, e := models.Send("GET", State.APIURL+"/api/version", GetKey(), nil)
-	parsedResp := ParseResponse(r, e, "get API information request")
+	apiInfo, e := API().Version()
 
 	fmt.Println("********************************************")
 	fmt.Println("OGREE Shell Information")
@@ -839,38 +742,29 @@ func LSOG() {
 	fmt.Println("HISTORY FILE PATH:", State.HistoryFilePath)
 	fmt.Println("DEBUG LEVEL: ", State.DebugLvl)
 
-	if parsedResp != nil {
-		if apiInfo, ok := parsedResp["data"].(map[string]interface{}); ok {
-			fmt.Println("********************************************")
-			fmt.Println("API Information")
-			fmt.Println("********************************************")
-			fmt.Println("BUILD DATE:", apiInfo["BuildDate"].(string))
-			fmt.Println("BUILD TREE:", apiInfo["BuildTree"].(string))
-			fmt.Println("BUILD HASH:", apiInfo["BuildHash"].(string))
-			fmt.Println("COMMIT DATE: ", apiInfo["CommitDate"].(string))
-
-		} else if State.DebugLvl > 1 {
-			msg := "Received invalid response from API on GET /api/version"
-			l.GetWarningLogger().Println(msg)
-			fmt.Println("NOTE: " + msg)
-		}
+	if e == nil {
+		fmt.Println("********************************************")
+		fmt.Println("API Information")
+		fmt.Println("********************************************")
+		fmt.Println("BUILD DATE:", apiInfo.BuildDate)
+		fmt.Println("BUILD TREE:", apiInfo.BuildTree)
+		fmt.Println("BUILD HASH:", apiInfo.BuildHash)
+		fmt.Println("COMMIT DATE: ", apiInfo.CommitDate)
 
-	} else {
-		if State.DebugLvl > 1 {
-			msg := "Received nil response from API on GET /api/version"
-			l.GetWarningLogger().Println(msg)
-			fmt.Println("NOTE: " + msg)
-		}
+	} else if State.DebugLvl > 1 {
+		msg := "Cannot get the API information : " + e.Error()
+		l.GetWarningLogger().Println(msg)
+		fmt.Println("NOTE: " + msg)
 	}
 }
 
 func LSEnterprise() {
-	r, e := models.Send("GET", State.APIURL+"/api/stats",
-		GetKey(), nil)
-	resp := ParseResponse(r, e, "lsenterprise")
-	if resp != nil {
-		displayObject(resp)
+	stats, e := API().Stats()
+	if e != nil {
+		println("Error: " + apiError(e).Error())
+		return
 	}
+	displayObject(stats)
 }
 
 // Displays environment variable values
@@ -930,12 +824,21 @@ func LSOBJECT(x string, entity int) []interface{} {
 
 	//Retrieve the desired objects under the working path
 	entStr := EntityToString(entity) + "s"
-	r, e := models.Send("GET", Path+"/"+entStr, GetKey(), nil)
-	parsed := ParseResponse(r, e, "list objects")
-	if parsed == nil {
+	children, e := API().ChildrenAt(Path, entStr)
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot list the objects :", e)
 		return nil
 	}
-	return GetRawObjects(parsed)
+	return objectsFields(children)
+}
+
+// Returns the fields of the objects, as received from the API
+func objectsFields(objs []*api.Object) []interface{} {
+	fields := []interface{}{}
+	for _, obj := range objs {
+		fields = append(fields, obj.Fields())
+	}
+	return fields
 }
 
 func GetByAttr(x string, u interface{}) {
@@ -968,32 +871,26 @@ func GetByAttr(x string, u interface{}) {
 	}
 
 	//GET the devices and process the response
-	req, code := models.Send("GET", url+"/devices", GetKey(), nil)
-	reqParsed := ParseResponse(req, code, "get devices request")
-	if reqParsed == nil {
+	devices, e := API().ChildrenAt(url, "devices")
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot get the devices :", e)
 		return
 	}
-	devInf := reqParsed["data"].(map[string]interface{})["objects"].([]interface{})
-	devices := infArrToMapStrinfArr(devInf)
 
 	switch u.(type) {
 	case int:
-		for i := range devices {
-			if attr, ok := devices[i]["attributes"].(map[string]interface{}); ok {
-				uStr := strconv.Itoa(u.(int))
-				if attr["height"] == uStr {
-					displayObject(devices[i])
-					return //What if the user placed multiple devices at same height?
-				}
+		uStr := strconv.Itoa(u.(int))
+		for _, device := range devices {
+			if device.Attributes["height"] == uStr {
+				displayObject(device.Fields())
+				return //What if the user placed multiple devices at same height?
 			}
 		}
 	default: //String
-		for i := range devices {
-			if attr, ok := devices[i]["attributes"].(map[string]interface{}); ok {
-				if attr["slot"] == u.(string) {
-					displayObject(devices[i])
-					return //What if the user placed multiple devices at same slot?
-				}
+		for _, device := range devices {
+			if device.Attributes["slot"] == u.(string) {
+				displayObject(device.Fields())
+				return //What if the user placed multiple devices at same slot?
 			}
 		}
 	}
@@ -1031,13 +928,12 @@ func LSATTR(x, attr string) {
 	}
 
 	//GET the devices and process the response
-	req, code := models.Send("GET", url+"/devices", GetKey(), nil)
-	reqParsed := ParseResponse(req, code, "get devices request")
-	if reqParsed == nil {
+	devices, e := API().ChildrenAt(url, "devices")
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot get the devices :", e)
 		return
 	}
-	devInf := reqParsed["data"].(map[string]interface{})["objects"].([]interface{})
-	//devices := infArrToMapStrinfArr(devInf)
+	devInf := objectsFields(devices)
 
 	sortedDevices := SortObjects(&devInf, attr)
 
@@ -1141,7 +1037,8 @@ func Help(entry string) {
 		"update", "delete", "lsog", "grep", "for", "while", "if", "env",
 		"cmds", "var", "unset", "select", "camera", "ui", "hc", "drawable",
 		"link", "unlink", "draw", "getu", "getslot", "undraw",
-		"lsenterprise":
+		"lsenterprise", "alias", "global", "break", "continue", "return", "try",
+		"exit", "debug", "save-session", "load-session", "cache":
 		path = "./other/man/" + entry + ".md"
 
 	case ">":
@@ -1192,10 +1089,10 @@ func displayObject(obj map[string]interface{}) {
 }
 
 // Function is an abstraction of a normal exit
-func Exit() {
+func Exit(code int) {
 	//writeHistoryOnExit(&State.sessionBuffer)
 	//runtime.Goexit()
-	os.Exit(0)
+	os.Exit(code)
 }
 
 func Tree(x string, depth int) {
@@ -1274,8 +1171,6 @@ func tree(path string, depth int) {
 }
 
 func GetHierarchy(x string, depth int, silence bool) []map[string]interface{} {
-	//Variable declarations
-	var URL, ext, depthStr string
 	var ans []map[string]interface{}
 
 	if FindNodeInTree(&State.TreeHierarchy, StrToStack(x), true) != nil {
@@ -1295,48 +1190,30 @@ func GetHierarchy(x string, depth int, silence bool) []map[string]interface{} {
 	}
 
 	//Then obtain hierarchy
-	id := obj["id"].(string)
-	if ent, ok := obj["category"]; ok {
-		if entity, ok := ent.(string); ok {
-			//Make URL
-			depthStr = strconv.Itoa(depth)
-			ext = "/api/" + entity + "s/" + id + "/all?limit=" + depthStr
-			URL = State.APIURL + ext
-
-			r, e := models.Send("GET", URL, GetKey(), nil)
-			if e != nil {
-				if State.DebugLvl > 0 {
-					println("Error: " + e.Error())
-				}
-
-				l.GetErrorLogger().Println("Error: " + e.Error())
-				return nil
-			}
-
-			data := ParseResponse(r, nil, "get hierarchy")
-			if data == nil {
-				l.GetWarningLogger().Println("Hierarchy call response was nil")
-				if State.DebugLvl > 0 {
-					println("No data")
-				}
-
-				return nil
+	id, _ := obj["id"].(string)
+	if entity, ok := obj["category"].(string); ok {
+		root, e := API().Hierarchy(entity, id, depth)
+		if e != nil {
+			if State.DebugLvl > 0 {
+				println("Error: " + apiError(e).Error())
 			}
 
-			objs := LoadArrFromResp(data, "children")
-			if objs == nil {
-				l.GetWarningLogger().Println("No objects found in hierarchy call")
-				if State.DebugLvl > 0 {
-					println("No objects found in hierarchy call")
-				}
+			l.GetErrorLogger().Println("Error: " + e.Error())
+			return nil
+		}
 
-				return nil
+		if len(root.Children) == 0 {
+			l.GetWarningLogger().Println("No objects found in hierarchy call")
+			if State.DebugLvl > 0 {
+				println("No objects found in hierarchy call")
 			}
 
-			ans = infArrToMapStrinfArr(objs)
-
+			return nil
 		}
 
+		for _, child := range root.Children {
+			ans = append(ans, child.Fields())
+		}
 	}
 	if silence == false {
 		DispMapArr(ans)
@@ -1395,6 +1272,12 @@ func GetOCLIAtrributes(Path string, ent int, data map[string]interface{}) error
 
 	case BLDG:
 		attr = data["attributes"].(map[string]interface{})
+		if err := convertAttrUnits(attr, "posXY", "m"); err != nil {
+			return err
+		}
+		if err := convertAttrUnits(attr, "size", "m"); err != nil {
+			return err
+		}
 
 		//Check for template
 		if _, ok := attr["template"]; ok {
@@ -1459,6 +1342,12 @@ func GetOCLIAtrributes(Path string, ent int, data map[string]interface{}) error
 
 	case ROOM:
 		attr = data["attributes"].(map[string]interface{})
+		if err := convertAttrUnits(attr, "posXY", "m"); err != nil {
+			return err
+		}
+		if err := convertAttrUnits(attr, "size", "m"); err != nil {
+			return err
+		}
 
 		baseAttrs := map[string]interface{}{
 			"floorUnit": "t",
@@ -1526,6 +1415,17 @@ func GetOCLIAtrributes(Path string, ent int, data map[string]interface{}) error
 		//GetOCLIAtrributesTemplateHelper()
 		orientation := attr["orientation"]
 
+		floorUnit, ok := parentAttr["floorUnit"].(string)
+		if !ok {
+			floorUnit = "t"
+		}
+		if err := convertAttrUnits(attr, "posXYZ", floorUnit); err != nil {
+			return err
+		}
+		if err := convertAttrUnits(attr, "size", "cm", "cm", "U"); err != nil {
+			return err
+		}
+
 		baseAttrs := map[string]interface{}{
 			"sizeUnit":   "cm",
 			"heightUnit": "U",
@@ -1586,6 +1486,13 @@ func GetOCLIAtrributes(Path string, ent int, data map[string]interface{}) error
 	case DEVICE:
 		attr = data["attributes"].(map[string]interface{})
 
+		if err := convertAttrUnits(attr, "posU/slot", "U"); err != nil {
+			return err
+		}
+		if err := convertAttrUnits(attr, "sizeU", "U"); err != nil {
+			return err
+		}
+
 		//Special routine to perform on device
 		//based on if the parent has a "slot" attribute
 
@@ -1719,27 +1626,13 @@ func GetOCLIAtrributes(Path string, ent int, data map[string]interface{}) error
 					tenantName := arr[i+1]
 
 					//GET Tenant/Domain
-					r, e := models.Send("GET",
-						State.APIURL+"/api/tenants/"+tenantName, GetKey(), nil)
-					parsed := ParseResponse(r, e, "get color")
-					if parsed == nil {
+					tenant, e := API().GetTenant(tenantName)
+					if e != nil {
 						msg := "Unable to retrieve color from server"
 						return fmt.Errorf(msg)
 					}
-
-					if tenantData, ok := parsed["data"]; ok {
-						if tenant, ok := tenantData.(map[string]interface{}); ok {
-							if attrInf, ok := tenant["attributes"]; ok {
-								if a, ok := attrInf.(map[string]interface{}); ok {
-									if colorInf, ok := a["color"]; ok {
-										if color, ok := colorInf.(string); ok {
-
-											attr["color"] = color
-										}
-									}
-								}
-							}
-						}
+					if color, ok := tenant.Attributes["color"].(string); ok {
+						attr["color"] = color
 					}
 
 				}
@@ -2109,8 +2002,8 @@ func FocusUI(path string) {
 					" ensure that the object exists and" +
 					" is not a directory and try again"
 				println(msg)
-				return
 			}
+			return
 		}
 		category := EntityStrToInt(obj["category"].(string))
 		if category == TENANT || category == SITE ||
@@ -2120,8 +2013,8 @@ func FocusUI(path string) {
 					" focus on Tenants, Sites, Buildings and Rooms. " +
 					"For more information please refer to the help doc  (man >)"
 				println(msg)
-				return
 			}
+			return
 		}
 
 		id = obj["id"].(string)
@@ -2505,6 +2398,18 @@ func objectCounter(parent *map[string]interface{}) int {
 	return count
 }
 
+// Asks a yes or no question on the terminal, the answer is
+// no when the shell has none, such as in a script or a pipe
+func Confirm(question string) bool {
+	if State.Terminal == nil {
+		return false
+	}
+	(*State.Terminal).Write([]byte(question + " (y/n)\n"))
+	(*State.Terminal).SetPrompt(">")
+	ans, _ := (*State.Terminal).Readline()
+	return ans == "y" || ans == "Y"
+}
+
 // Unity UI will draw already existing objects
 // by retrieving the hierarchy. 'force' bool is useful
 // for scripting where the user can 'force' input if
@@ -2528,19 +2433,13 @@ func Draw(x string, depth int, force bool) error {
 		if State.UnityClientAvail {
 			okToGo := true
 			if count > State.DrawThreshold && !force {
-				msg := "You are about to send " + strconv.Itoa(count) +
-					" objects to the Unity 3D client. " +
-					"Do you want to continue ? (y/n)\n"
-				(*State.Terminal).Write([]byte(msg))
-				(*State.Terminal).SetPrompt(">")
-				ans, _ := (*State.Terminal).Readline()
-				if ans != "y" && ans != "Y" {
-					okToGo = false
+				if State.Terminal == nil {
+					return fmt.Errorf("%d objects to draw, more than the threshold of %d : "+
+						"use draw -f to draw them", count, State.DrawThreshold)
 				}
-			} else if force {
-				okToGo = true
-			} else if !force && count > State.DrawThreshold {
-				okToGo = false
+				okToGo = Confirm("You are about to send " + strconv.Itoa(count) +
+					" objects to the Unity 3D client. " +
+					"Do you want to continue ?")
 			}
 			if okToGo {
 				data := map[string]interface{}{"type": "create", "data": obj}
@@ -2784,41 +2683,17 @@ func UpdateSelection(data map[string]interface{}) error {
 }
 
 func LoadTemplate(data map[string]interface{}, filePath string) error {
-	var URL string
-	if cat := data["category"]; cat == "room" {
-		//Room template
-		URL = State.APIURL + "/api/room-templates"
-	} else if cat == "bldg" || cat == "building" {
-		//Bldg template
-		URL = State.APIURL + "/api/bldg-templates"
-	} else if cat == "rack" || cat == "device" {
-		// Obj template
-		URL = State.APIURL + "/api/obj-templates"
-	} else {
-		return fmt.Errorf("this template does not have a valid category. Please add a category attribute with a value of building or room or rack or device")
+	e := API().CreateTemplate(data)
+	var apiErr *api.Error
+	if errors.As(e, &apiErr) {
+		l.GetWarningLogger().Println("Couldn't load template, Status Code :", apiErr.StatusCode, " filePath :", filePath)
+		return fmt.Errorf("Error template wasn't loaded\n" + apiError(e).Error())
 	}
-	r, e := models.Send("POST", URL, GetKey(), data)
 	if e != nil {
-		return fmt.Errorf(e.Error())
-	}
-	//Crashes here if API timeout
-	if r == nil {
-		return fmt.Errorf("unable to recieve response from API")
-	}
-	if r.StatusCode == http.StatusCreated {
-		println("Template Loaded")
-		return nil
-	} else {
-		l.GetWarningLogger().Println("Couldn't load template, Status Code :", r.StatusCode, " filePath :", filePath)
-		parsedResp := ParseResponse(r, e, "sending template")
-		errorMsg := "Error template wasn't loaded\n"
-		if mInf, ok := parsedResp["message"]; ok {
-			if msg, ok := mInf.(string); ok {
-				errorMsg += APIErrorPrefix + msg
-			}
-		}
-		return fmt.Errorf(errorMsg)
+		return e
 	}
+	println("Template Loaded")
+	return nil
 }
 
 func SetClipBoard(x []string) ([]string, error) {
@@ -3019,10 +2894,12 @@ func LSOBJECTRecursive(x string, entity int) []interface{} {
 
 	if entity == TENANT { //Edge case
 		if x == "/Physical" {
-			r, e := models.Send("GET",
-				State.APIURL+"/api/tenants", GetKey(), nil)
-			obj = ParseResponse(r, e, "Get Tenants")
-			return LoadArrFromResp(obj, "objects")
+			tenants, e := API().ObjectsAt(State.APIURL + "/api/tenants")
+			if e != nil {
+				l.GetWarningLogger().Println("Cannot get the tenants :", e)
+				return nil
+			}
+			return objectsFields(tenants)
 		} else {
 			//Return nothing
 			return nil
@@ -3066,178 +2943,92 @@ func LSOBJECTRecursive(x string, entity int) []interface{} {
 	//println(entities)
 	var idToSend string
 	if obi == TENANT {
-		idToSend = obj["name"].(string)
+		idToSend, _ = obj["name"].(string)
 	} else {
-		idToSend = obj["id"].(string)
+		idToSend, _ = obj["id"].(string)
 	}
-	//println(entities)
-	//println(obi)
-	//println("WANT:", EntityToString(entity))
-	return lsobjHelperRecursive(State.APIURL, idToSend, obi, entity)
-	//return nil
+	return lsobjHelperRecursive(idToSend, obi, entity)
 }
 
 // NOTE: LSDEV is recursive while LSSENSOR is not
-// Code could be more tidy
-func lsobjHelperRecursive(api, objID string, curr, entity int) []interface{} {
-	var ext, URL string
+func lsobjHelperRecursive(objID string, curr, entity int) []interface{} {
 	if entity == SENSOR && (curr == BLDG || curr == ROOM || curr == RACK || curr == DEVICE) {
-		ext = EntityToString(curr) + "s/" + objID + "/" + EntityToString(entity) + "s"
-		URL = State.APIURL + "/api/" + ext
-		r, e := models.Send("GET", URL, GetKey(), nil)
-		tmp := ParseResponse(r, e, "getting objects")
-		if tmp == nil {
-			return nil
-		}
-
-		tmpObjs := LoadArrFromResp(tmp, "objects")
-		if tmp == nil {
-			return nil
-		}
-		//res := infArrToMapStrinfArr(tmpObjs)
-		return tmpObjs
+		return objectsFields(fetchChildren(curr, objID, entity))
 
 	} else if entity-curr >= 2 {
-
-		//println("DEBUG-should be here")
-		ext = EntityToString(curr) + "s/" + objID + "/" + EntityToString(curr+2) + "s"
-		URL = State.APIURL + "/api/" + ext
-		//println("DEBUG-URL:", URL)
-
 		//EDGE CASE, if user is at a BLDG and requests object of room
 		if (curr == BLDG || curr == ROOM) && (entity >= AC && entity <= CORIDOR) {
-			ext = EntityToString(curr) + "s/" + objID + "/" + EntityToString(entity) + "s"
-			r, e := models.Send("GET", State.APIURL+"/api/"+ext, GetKey(), nil)
-			tmp := ParseResponse(r, e, "getting objects")
-			if tmp == nil {
-				return nil
-			}
-
-			return GetRawObjects(tmp)
-
+			return objectsFields(fetchChildren(curr, objID, entity))
 		}
 		//END OF EDGE CASE BLOCK
 
-		r, e := models.Send("GET", URL, GetKey(), nil)
-		resp := ParseResponse(r, e, "getting objects")
-		if resp == nil {
-			println("return nil1")
-			return nil
-		}
-
-		//objs -> resp["data"]["objects"]
-		objs := LoadArrFromResp(resp, "objects")
-		if objs != nil {
-			x := []interface{}{}
-
-			if entity >= AC && entity <= CORIDOR {
-
-				for q := range objs {
-					id := objs[q].(map[string]interface{})["id"].(string)
-					ext2 := "/api/" + EntityToString(curr+2) + "s/" + id + "/" + EntityToString(entity) + "s"
-
-					tmp, e := models.Send("GET", State.APIURL+ext2, GetKey(), nil)
-					tmp2 := ParseResponse(tmp, e, "get objects")
-					if tmp2 != nil {
-						x = GetRawObjects(tmp2)
-					}
-				}
-			} else {
-				if entity == DEVICE && curr == ROOM {
-					x = append(x, objs...)
-				}
-				for i := range objs {
-					rest := lsobjHelperRecursive(api, objs[i].(map[string]interface{})["id"].(string), curr+2, entity)
-					if rest != nil && len(rest) > 0 {
-						x = append(x, rest...)
-					}
-
-				}
+		objs := fetchChildren(curr, objID, curr+2)
+		x := []interface{}{}
+		if entity >= AC && entity <= CORIDOR {
+			for _, obj := range objs {
+				x = append(x, objectsFields(fetchChildren(curr+2, obj.ID, entity))...)
 			}
-
-			if State.DebugLvl > 3 {
-				println(len(x))
+		} else {
+			if entity == DEVICE && curr == ROOM {
+				x = append(x, objectsFields(objs)...)
 			}
+			for _, obj := range objs {
+				x = append(x, lsobjHelperRecursive(obj.ID, curr+2, entity)...)
+			}
+		}
 
-			return x
+		if State.DebugLvl > 3 {
+			println(len(x))
 		}
+		return x
 
 	} else if entity-curr >= 1 {
-		//println("DEBUG-must be here")
-		ext := EntityToString(curr) + "s/" + objID + "/" + EntityToString(curr+1) + "s"
-		URL := State.APIURL + "/api/" + ext
-		r, e := models.Send("GET", URL, GetKey(), nil)
-		//println("DEBUG-URL SENT:", URL)
-		resp := ParseResponse(r, e, "getting objects")
-		if resp == nil {
-			println("return nil")
-			return nil
-		}
-		//objs := resp["data"]["objects"]
-		objs := LoadArrFromResp(resp, "objects")
-		if objs != nil {
+		objs := fetchChildren(curr, objID, curr+1)
+		//For associated objects of room
+		if entity >= AC && entity <= CORIDOR {
 			ans := []interface{}{}
-			//For associated objects of room
-			if entity >= AC && entity <= CORIDOR {
-				for i := range objs {
-					ext2 := "/api/" + EntityToString(curr) + "s/" +
-						objs[i].(map[string]interface{})["id"].(string) +
-						"/" + EntityToString(entity) + "s"
-
-					tmp, e := models.Send("GET", State.APIURL+ext2, GetKey(), nil)
-					x := ParseResponse(tmp, e, "get objects")
-					if x != nil {
-						ans = append(ans, x)
-					}
-				}
-			} else {
-
-				ans = objs
-				if curr == RACK && entity == DEVICE {
-					for idx := range ans {
-						ext2 := "/api/" + EntityToString(entity) +
-							"s/" +
-							ans[idx].(map[string]interface{})["id"].(string) +
-							"/" + EntityToString(entity) + "s"
-
-						subURL := State.APIURL + ext2
-						r1, e1 := models.Send("GET", subURL, GetKey(), nil)
-						tmp1 := ParseResponse(r1, e1, "getting objects")
-
-						tmp2 := LoadArrFromResp(tmp1, "objects")
-						if tmp2 != nil {
-							//Swap ans and objs to keep order
-							ans = append(ans, tmp2...)
-						}
-
-					}
-
-				}
+			for _, obj := range objs {
+				ans = append(ans, objectsFields(fetchChildren(curr+1, obj.ID, entity))...)
 			}
-
 			return ans
 		}
 
-	} else if entity-curr == 0 { //Base Case
+		ans := objectsFields(objs)
+		if curr == RACK && entity == DEVICE {
+			for _, obj := range objs {
+				ans = append(ans, objectsFields(fetchChildren(DEVICE, obj.ID, DEVICE))...)
+			}
+		}
+		return ans
 
+	} else if entity-curr == 0 { //Base Case
 		//For devices we have to make hierarchal call
 		if entity == DEVICE {
-			URL = State.APIURL + "/api/" + EntityToString(curr) + "s/" + objID + "/devices"
-		} else {
-			URL = State.APIURL + "/api/" + EntityToString(curr) + "s/" + objID
+			return objectsFields(fetchChildren(curr, objID, DEVICE))
 		}
 
-		resp, e := models.Send("GET", URL, GetKey(), nil)
-		x := ParseResponse(resp, e, "get object")
-		if entity == DEVICE {
-			return GetRawObjects(x)
-
+		URL := State.APIURL + "/api/" + EntityToString(curr) + "s/" + objID
+		obj, e := API().ObjectAt(URL)
+		if e != nil {
+			l.GetWarningLogger().Println("Cannot get the object :", e)
+			return nil
 		}
-		return []interface{}{x["data"]}
+		return []interface{}{obj.Fields()}
 	}
 	return nil
 }
 
+// Returns the children of an object, logs why they cannot be fetched
+func fetchChildren(parent int, id string, children int) []*api.Object {
+	URL := State.APIURL + "/api/" + EntityToString(parent) + "s/" + id
+	objs, e := API().ChildrenAt(URL, EntityToString(children)+"s")
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot get the objects :", e)
+		return nil
+	}
+	return objs
+}
+
 // Auxillary function that preprocesses
 // strings to be used for Path Resolver funcs
 func PreProPath(Path string) []string {
@@ -3539,23 +3330,18 @@ func OnlineLevelResolver(path []string) []string {
 // template from server if available, this func mainly helps
 // to keep code organised
 func fetchTemplate(name string, objType int) map[string]interface{} {
-	var URL string
+	var tmpl interface{ Fields() map[string]interface{} }
+	var e error
 	if objType == ROOMTMPL {
-		URL = State.APIURL + "/api/room_templates/" + name
+		tmpl, e = API().GetRoomTemplate(name)
 	} else if objType == BLDGTMPL {
-		URL = State.APIURL + "/api/bldg_templates/" + name
+		tmpl, e = API().GetBldgTemplate(name)
 	} else {
-		URL = State.APIURL + "/api/obj_templates/" + name
+		tmpl, e = API().GetObjTemplate(name)
 	}
-	r, e := models.Send("GET", URL, GetKey(), nil)
-	res := ParseResponse(r, e, "fetch template")
-	if res != nil {
-		if tmplInf, ok := res["data"]; ok {
-			if tmpl, ok := tmplInf.(map[string]interface{}); ok {
-				return tmpl
-			}
-		}
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot fetch template "+name+" :", e)
+		return nil
 	}
-
-	return nil
+	return tmpl.Fields()
 }
@@ -0,0 +1,223 @@
+package controllers
+
+//This file implements the expansion of the braces of a path
+//and of its wildcards against the hierarchy of objects
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Maximum number of paths a brace expansion can give
+const maxBraceExpansion = 10000
+
+// Expands the braces of a path as bash does : a{b,c}d gives abd and acd,
+// R{01..10} gives R01 to R10 and {A..F} the letters from A to F.
+// Braces can be nested and a path can contain several of them
+func ExpandBraces(p string) ([]string, error) {
+	start := strings.IndexByte(p, '{')
+	if start == -1 {
+		return []string{p}, nil
+	}
+	depth := 0
+	end := -1
+	commas := []int{}
+	for i := start; i < len(p) && end == -1; i++ {
+		switch p[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+	if end == -1 {
+		return nil, fmt.Errorf("unclosed brace in path %s", p)
+	}
+	var alternatives []string
+	if len(commas) > 0 {
+		begin := start + 1
+		for _, comma := range append(commas, end) {
+			alternatives = append(alternatives, p[begin:comma])
+			begin = comma + 1
+		}
+	} else {
+		var err error
+		alternatives, err = braceRange(p[start+1 : end])
+		if err != nil {
+			return nil, err
+		}
+	}
+	paths := []string{}
+	for _, alternative := range alternatives {
+		expanded, err := ExpandBraces(p[:start] + alternative + p[end+1:])
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, expanded...)
+		if len(paths) > maxBraceExpansion {
+			return nil, fmt.Errorf("path %s gives more than %d paths", p, maxBraceExpansion)
+		}
+	}
+	return paths, nil
+}
+
+// Expands the content of braces of the form START..END or START..END..STEP,
+// between integers or letters. The integers are padded with zeros when
+// one of the bounds begins with a zero : 01..10
+func braceRange(s string) ([]string, error) {
+	bounds := strings.Split(s, "..")
+	if len(bounds) != 2 && len(bounds) != 3 {
+		return nil, fmt.Errorf("invalid brace expansion {%s}, a list or a range expected", s)
+	}
+	step := 1
+	if len(bounds) == 3 {
+		var err error
+		step, err = strconv.Atoi(bounds[2])
+		if err != nil || step == 0 {
+			return nil, fmt.Errorf("invalid step in brace expansion {%s}", s)
+		}
+		if step < 0 {
+			step = -step
+		}
+	}
+	first, errFirst := strconv.Atoi(bounds[0])
+	last, errLast := strconv.Atoi(bounds[1])
+	isLetters := len(bounds[0]) == 1 && len(bounds[1]) == 1 &&
+		isASCIILetter(bounds[0][0]) && isASCIILetter(bounds[1][0])
+	if isLetters {
+		first, last = int(bounds[0][0]), int(bounds[1][0])
+		//{Z..a} would give the punctuation between the two cases
+		if isLowerCase(bounds[0][0]) != isLowerCase(bounds[1][0]) {
+			return nil, fmt.Errorf("invalid range in brace expansion {%s}, "+
+				"the letters should have the same case", s)
+		}
+	} else if errFirst != nil || errLast != nil {
+		return nil, fmt.Errorf("invalid range in brace expansion {%s}", s)
+	}
+	if (last-first)/step >= maxBraceExpansion || (first-last)/step >= maxBraceExpansion {
+		return nil, fmt.Errorf("brace expansion {%s} gives more than %d paths", s, maxBraceExpansion)
+	}
+	width := 0
+	if isZeroPadded(bounds[0]) || isZeroPadded(bounds[1]) {
+		width = len(bounds[0])
+		if len(bounds[1]) > width {
+			width = len(bounds[1])
+		}
+	}
+	if last < first {
+		step = -step
+	}
+	values := []string{}
+	for i := first; (step > 0 && i <= last) || (step < 0 && i >= last); i += step {
+		if isLetters {
+			values = append(values, string(rune(i)))
+		} else if i < 0 {
+			values = append(values, fmt.Sprintf("-%0*d", width-1, -i))
+		} else {
+			values = append(values, fmt.Sprintf("%0*d", width, i))
+		}
+	}
+	return values, nil
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isLowerCase(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isZeroPadded(bound string) bool {
+	bound = strings.TrimPrefix(bound, "-")
+	return len(bound) > 1 && bound[0] == '0'
+}
+
+func HasWildcards(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// Expands an absolute path containing wildcards into the sorted
+// paths of the matching objects. In each segment, * matches any
+// sequence of characters, ? a single character and [abc] one of
+// the characters, a ** segment matches any number of levels.
+// children gives the names of the objects directly under a path
+func ExpandPath(pattern string, children func(path string) []string) ([]string, error) {
+	paths := []string{""}
+	expanded := false
+	for _, segment := range strings.Split(path.Clean(pattern), "/")[1:] {
+		next := []string{}
+		switch {
+		case segment == "**":
+			for _, p := range paths {
+				next = append(next, descendants(p, children)...)
+			}
+			expanded = true
+		case HasWildcards(segment):
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %s in path %s", segment, pattern)
+			}
+			for _, p := range paths {
+				for _, name := range children(levelPath(p)) {
+					if ok, _ := path.Match(segment, name); ok {
+						next = append(next, p+"/"+name)
+					}
+				}
+			}
+			expanded = true
+		case expanded:
+			//Only keep the expanded paths that have this child
+			for _, p := range paths {
+				for _, name := range children(levelPath(p)) {
+					if name == segment {
+						next = append(next, p+"/"+name)
+						break
+					}
+				}
+			}
+		default:
+			for _, p := range paths {
+				next = append(next, p+"/"+segment)
+			}
+		}
+		paths = next
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no object matches path %s", pattern)
+	}
+	sort.Strings(paths)
+	//** can reach the same object from several paths
+	unique := paths[:1]
+	for _, p := range paths[1:] {
+		if p != unique[len(unique)-1] {
+			unique = append(unique, p)
+		}
+	}
+	return unique, nil
+}
+
+func levelPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return p
+}
+
+// Returns the path itself followed by all the paths below it
+func descendants(p string, children func(path string) []string) []string {
+	result := []string{p}
+	for _, name := range children(levelPath(p)) {
+		result = append(result, descendants(p+"/"+name, children)...)
+	}
+	return result
+}
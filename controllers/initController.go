@@ -4,10 +4,13 @@ package controllers
 
 import (
 	"bufio"
+	"cli/api"
+	"cli/api/mock"
 	l "cli/logger"
 	"cli/models"
 	"cli/readline"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -221,6 +224,44 @@ func InitTimeout(env map[string]string) {
 	return
 }
 
+// Reads the settings of the API requests : apiTimeout, apiRetries,
+// apiRetryDelay, apiRetryMaxDelay and apiCacheTTL, the durations
+// are written like 500ms or 10s
+func InitAPIClient(env map[string]string) {
+	config := models.DefaultRetryConfig
+	cacheTTL := DefaultCacheTTL
+	durations := map[string]*time.Duration{
+		"apiTimeout":       &config.Timeout,
+		"apiRetryDelay":    &config.BaseDelay,
+		"apiRetryMaxDelay": &config.MaxDelay,
+		"apiCacheTTL":      &cacheTTL,
+	}
+	for key, duration := range durations {
+		value, ok := env[key]
+		if !ok || value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			l.GetWarningLogger().Println("Invalid duration for " + key + " : " + value)
+			println("Warning: invalid duration for " + key + " in env file, using the default")
+			continue
+		}
+		*duration = d
+	}
+	if value, ok := env["apiRetries"]; ok && value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			l.GetWarningLogger().Println("Invalid number of retries : " + value)
+			println("Warning: invalid apiRetries in env file, using the default")
+		} else {
+			config.MaxRetries = retries
+		}
+	}
+	models.SetRetryConfig(config)
+	apiCache = api.NewCache(cacheTTL)
+}
+
 func InitKey(apiKey string, env map[string]string) string {
 	if apiKey != "" {
 		State.APIKEY = apiKey
@@ -231,6 +272,11 @@ func InitKey(apiKey string, env map[string]string) string {
 		State.APIKEY = envApiKey
 		return State.APIKEY
 	}
+	if IsOffline() {
+		//The offline API accepts any key
+		State.APIKEY = ""
+		return ""
+	}
 	fmt.Println("Error: No API Key Found")
 	if State.DebugLvl > 0 {
 		l.GetErrorLogger().Println(
@@ -286,7 +332,9 @@ func GetURLs(apiURL string, unityURL string, env map[string]string) {
 	if State.APIURL == "" {
 		if envApiURL, ok := env["apiURL"]; ok {
 			// if present, remove the last / to avoid path issues in ls command
-			envApiURL = strings.TrimRight(envApiURL, "/")
+			if !strings.HasPrefix(envApiURL, MockAPIURL) {
+				envApiURL = strings.TrimRight(envApiURL, "/")
+			}
 
 			// check if URL is valid
 			_, err := url.ParseRequestURI(envApiURL)
@@ -311,8 +359,40 @@ func GetURLs(apiURL string, unityURL string, env map[string]string) {
 			"http://localhost:5500")
 		l.GetInfoLogger().Println("Falling back to default Unity URL:" +
 			"http://localhost:5500")
-		State.APIURL = "http://localhost:5500"
+		State.UnityClientURL = "http://localhost:5500"
+	}
+
+	if IsOffline() {
+		if err := InitMockAPI(strings.TrimPrefix(State.APIURL, MockAPIURL)); err != nil {
+			println("Cannot start the offline API : " + err.Error())
+			os.Exit(1)
+		}
+	}
+}
+
+// URL of the API built into the shell, it may be followed
+// by the JSON file where its objects are saved
+const MockAPIURL = "mock://"
+
+// Tells whether the shell uses the API built into it
+func IsOffline() bool {
+	return strings.HasPrefix(State.APIURL, MockAPIURL)
+}
+
+// Starts the API built into the shell and sends the requests to it,
+// its objects are saved in the given JSON file, kept in memory if empty
+func InitMockAPI(file string) error {
+	server, err := mock.NewServer(file)
+	if err != nil {
+		return err
 	}
+	models.RegisterProtocol(strings.TrimSuffix(MockAPIURL, "://"), server)
+	State.APIURL = MockAPIURL
+	//The objects of a previous server are not in this one
+	apiCache.Clear()
+	forgetPaths()
+	l.GetInfoLogger().Println("Using the offline API, objects saved in :", file)
+	return nil
 }
 
 // Helper for InitState will insert objs
@@ -392,30 +472,23 @@ func SetDrawableTemplate(entity string, env map[string]string) map[string]interf
 }
 
 func CreateCredentials() (string, string) {
-	var tp map[string]interface{}
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		//The input is a script, it cannot answer
+		println("No credentials found in the env file " + State.EnvFilePath)
+		os.Exit(1)
+	}
 
 	user, _ := readline.Line("Please Enter desired user email: ")
 	pass, _ := readline.Password("Please Enter desired password: ")
-	data := map[string]interface{}{"email": user, "password": string(pass)}
-
-	resp, e := models.Send("POST", State.APIURL+"/api", "", data)
-	tp = ParseResponse(resp, e, "Create credentials")
-	if tp == nil {
-		println(e.Error())
-		os.Exit(-1)
-	}
 
-	if !tp["status"].(bool) {
-		errMessage := "Error while creating credentials : " + tp["message"].(string)
-		if State.DebugLvl > 0 {
-			println(errMessage)
-		}
+	token, e := api.NewClient(State.APIURL, "").CreateAccount(user, string(pass))
+	if e != nil {
+		errMessage := "Error while creating credentials : " + e.Error()
+		println(errMessage)
 		l.GetErrorLogger().Println(errMessage)
 		os.Exit(-1)
 	}
 
-	token := (tp["account"].(map[string]interface{}))["token"].(string)
-
 	envMap, err := godotenv.Read(State.EnvFilePath)
 	if err != nil {
 		panic(err)
@@ -429,23 +502,14 @@ func CreateCredentials() (string, string) {
 }
 
 func CheckKeyIsValid(key string) bool {
-	resp, err := models.Send("GET", State.APIURL+"/api/token/valid", key, nil)
-	if err != nil {
-		if State.DebugLvl > 0 {
-			l.GetErrorLogger().Println("Unable to connect to API: ", State.APIURL)
-			l.GetErrorLogger().Println(err.Error())
-			println(err.Error())
-		}
-		return false
-	}
-
-	if resp.StatusCode != 200 {
+	err := api.NewClient(State.APIURL, key).CheckKey()
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) {
 		readline.Line("HTTP Response Status code: " +
-			strconv.Itoa(resp.StatusCode))
+			strconv.Itoa(apiErr.StatusCode))
 		if State.DebugLvl > NONE {
-			x := ParseResponse(resp, err, " Read API Response message")
-			if x != nil {
-				println("[API] " + x["message"].(string))
+			if apiErr.Message != "" {
+				println("[API] " + apiErr.Message)
 			} else {
 				println("Was not able to read API Response message")
 			}
@@ -453,6 +517,14 @@ func CheckKeyIsValid(key string) bool {
 
 		return false
 	}
+	if err != nil {
+		if State.DebugLvl > 0 {
+			l.GetErrorLogger().Println("Unable to connect to API: ", State.APIURL)
+			l.GetErrorLogger().Println(err.Error())
+			println(err.Error())
+		}
+		return false
+	}
 
 	return true
 }
@@ -461,6 +533,14 @@ func Login(env map[string]string) (string, string) {
 	user, userOk := env["user"]
 	key, keyOk := env["apiKey"]
 
+	if IsOffline() {
+		//No credentials are needed
+		if user == "" {
+			user = "offline"
+		}
+		return strings.Split(user, "@")[0], key
+	}
+
 	if !userOk || !keyOk || (userOk && user == "") || (keyOk && key == "") {
 		l.GetInfoLogger().Println("Key not found, going to generate..")
 		user, key = CreateCredentials()
@@ -0,0 +1,134 @@
+package controllers
+
+//This file finds the URL of an object from its path, trying at the same
+//time the collections its name may belong to (racks, groups, corridors...)
+
+import (
+	"cli/api"
+	"cli/models"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Answer of the API at one of the URLs a path may have
+type lookupResult struct {
+	URL   string
+	index int //of the URL among the candidates
+	found bool
+	obj   *api.Object //nil if only the existence is checked
+	err   error
+}
+
+// Checks whether the object is at the URL, and fetches it if needed
+type lookup func(client *api.Client, URL string) (bool, *api.Object, error)
+
+func existsAt(client *api.Client, URL string) (bool, *api.Object, error) {
+	exists, err := client.ObjectExistsAt(URL)
+	return exists, nil, err
+}
+
+func objectAt(client *api.Client, URL string) (bool, *api.Object, error) {
+	obj, err := client.ObjectAt(URL)
+	if api.IsNotFound(err) {
+		//Not at this URL, another one may be the right one
+		return false, nil, nil
+	}
+	return err == nil, obj, err
+}
+
+// URLs of the ambiguous paths already found, by path
+var knownPaths = struct {
+	sync.Mutex
+	urls map[string]string
+}{urls: map[string]string{}}
+
+func knownURL(key string) (string, bool) {
+	knownPaths.Lock()
+	defer knownPaths.Unlock()
+	URL, ok := knownPaths.urls[key]
+	return URL, ok
+}
+
+func rememberURL(key, URL string) {
+	knownPaths.Lock()
+	defer knownPaths.Unlock()
+	if URL == "" {
+		delete(knownPaths.urls, key)
+	} else {
+		knownPaths.urls[key] = URL
+	}
+}
+
+func forgetPaths() {
+	knownPaths.Lock()
+	defer knownPaths.Unlock()
+	knownPaths.urls = map[string]string{}
+}
+
+// Looks for the object of a path (split, without /Physical) at its candidate
+// URLs given by OnlinePathResolve. The requests are sent at the same time,
+// the object found at the first URL in their order is returned, and once an
+// object is found the requests to the URLs after it are canceled.
+// The URL of an ambiguous path is remembered, the next lookup tries it first.
+// Returns the errors of the URLs, in their order, if nothing is found
+func resolvePath(pathSplit []string, find lookup) (*lookupResult, []lookupResult) {
+	key := State.APIURL + "/" + strings.Join(pathSplit, "/")
+	if URL, ok := knownURL(key); ok {
+		found, obj, err := find(API(), URL)
+		if found {
+			return &lookupResult{URL: URL, found: true, obj: obj}, nil
+		}
+		//Deleted, or renamed and another object took its name
+		rememberURL(key, "")
+		if err != nil && !isAPIError(err) {
+			return nil, []lookupResult{{URL: URL, err: err}}
+		}
+	}
+
+	URLs := OnlinePathResolve(pathSplit)
+	cancels := make([]context.CancelFunc, len(URLs))
+	results := make(chan lookupResult, len(URLs))
+	for i := range URLs {
+		ctx, cancel := context.WithCancel(models.Context())
+		defer cancel()
+		cancels[i] = cancel
+		go func(i int, client *api.Client) {
+			found, obj, err := find(client, URLs[i])
+			results <- lookupResult{URLs[i], i, found, obj, err}
+		}(i, API().WithContext(ctx))
+	}
+
+	//The canceled requests are waited for, so that
+	//their answers do not reach the cache afterwards
+	var found *lookupResult
+	failures := []lookupResult{}
+	for range URLs {
+		result := <-results
+		if result.found && (found == nil || result.index < found.index) {
+			found = &result
+			for _, cancel := range cancels[result.index+1:] {
+				cancel()
+			}
+		} else if result.err != nil {
+			failures = append(failures, result)
+		}
+	}
+	if found != nil {
+		if len(URLs) > 1 {
+			rememberURL(key, found.URL)
+		}
+		return found, nil
+	}
+	sort.Slice(failures, func(i, j int) bool { return failures[i].index < failures[j].index })
+	return nil, failures
+}
+
+// Tells whether the error is an answer of the API, such as not found,
+// rather than a failure to reach it
+func isAPIError(err error) bool {
+	var apiErr *api.Error
+	return errors.As(err, &apiErr)
+}
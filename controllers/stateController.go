@@ -1,11 +1,12 @@
 package controllers
 
 import (
+	"cli/api"
 	l "cli/logger"
-	"cli/models"
 	"cli/readline"
 	"container/list"
-	"net/http"
+	"errors"
+	"fmt"
 	"path"
 	"strings"
 	"time"
@@ -75,6 +76,47 @@ func GetKey() string {
 	return State.APIKEY
 }
 
+var apiClient *api.Client
+
+// Time during which the objects fetched from the API are reused
+const DefaultCacheTTL = 10 * time.Second
+
+var apiCache = api.NewCache(DefaultCacheTTL)
+
+// Returns the client of the API, for its current URL and key
+func API() *api.Client {
+	if apiClient == nil || apiClient.URL != State.APIURL || apiClient.Key != GetKey() {
+		apiClient = api.NewClient(State.APIURL, GetKey())
+		apiCache.Clear()
+	}
+	apiClient.Cache = apiCache
+	return apiClient
+}
+
+// Forgets the objects fetched from the API, they are fetched again when needed
+func ClearCache() {
+	apiCache.Clear()
+	forgetPaths()
+	println("Cache cleared")
+}
+
+func DisplayCacheStats() {
+	stats := apiCache.Stats()
+	fmt.Println("Entries:", stats.Entries)
+	fmt.Println("Hits:", stats.Hits)
+	fmt.Println("Misses:", stats.Misses)
+	fmt.Println("TTL:", stats.TTL)
+}
+
+// Prefixes the messages given by the API, the other errors are kept as is
+func apiError(err error) error {
+	var apiErr *api.Error
+	if errors.As(err, &apiErr) && apiErr.Message != "" {
+		return fmt.Errorf(APIErrorPrefix + apiErr.Message)
+	}
+	return err
+}
+
 func SearchAndInsert(root **Node, node *Node, path string) {
 	if root != nil {
 		for i := (*root).Nodes.Front(); i != nil; i = i.Next() {
@@ -149,36 +191,22 @@ func FetchNodesAtLevel(Path string) []string {
 
 	for i := range urls {
 		//println("DEBUG URL to send:", urls[i])
-		r, e := models.Send("GET", urls[i], GetKey(), nil)
+		objs, e := API().ObjectsAt(urls[i])
+		var apiErr *api.Error
+		if errors.As(e, &apiErr) {
+			continue
+		}
 		if e != nil {
 			println(e.Error())
 			return nil
 		}
 
-		if r.StatusCode == http.StatusOK { //Retrieved nodes
-			parsedResp := ParseResponse(r, e, "get request")
-			if parsedResp == nil {
-				return nil
-			}
-
-			if parsedResp["data"] != nil {
-
-				if objs, ok := parsedResp["data"].(map[string]interface{})["objects"]; ok {
-					data := objs.([]interface{})
-
-					for i := range data {
-						//If we have templates, check for slug
-						if _, ok := data[i].(map[string]interface{})["slug"]; ok {
-							names = append(names, data[i].(map[string]interface{})["slug"].(string))
-						} else {
-							names = append(names, data[i].(map[string]interface{})["name"].(string))
-						}
-
-						//println(data[i].(map[string]interface{})["name"].(string))
-					}
-
-				}
-
+		for _, obj := range objs {
+			//If we have templates, check for slug
+			if slug, ok := obj.Fields()["slug"].(string); ok {
+				names = append(names, slug)
+			} else {
+				names = append(names, obj.Name)
 			}
 		}
 	}
@@ -239,7 +267,11 @@ func FetchJsonNodesAtLevel(Path string) []map[string]interface{} {
 
 	for i := range urls {
 		//println("URL to send:", urls[i])
-		r, e := models.Send("GET", urls[i], GetKey(), nil)
+		objs, e := API().ObjectsAt(urls[i])
+		var apiErr *api.Error
+		if errors.As(e, &apiErr) {
+			continue
+		}
 		if e != nil {
 			if State.DebugLvl > NONE {
 				println(e.Error())
@@ -247,27 +279,8 @@ func FetchJsonNodesAtLevel(Path string) []map[string]interface{} {
 			return nil
 		}
 
-		if r.StatusCode == http.StatusOK { //Retrieved nodes
-			parsedResp := ParseResponse(r, e, "get request")
-			if parsedResp == nil {
-				return nil
-			}
-
-			if parsedResp["data"] != nil {
-
-				if objs, ok := parsedResp["data"].(map[string]interface{})["objects"]; ok {
-					data := objs.([]interface{})
-
-					for i := range data {
-						//If we have templates, check for slug
-						if object, ok := data[i].(map[string]interface{}); ok {
-							objects = append(objects, object)
-						}
-					}
-
-				}
-
-			}
+		for _, obj := range objs {
+			objects = append(objects, obj.Fields())
 		}
 	}
 	return objects
@@ -290,16 +303,8 @@ func CheckPathOnline(Path string) (bool, string) {
 		}
 	}
 
-	paths := OnlinePathResolve(pathSplit[2:])
-
-	for i := range paths {
-		r, e := models.Send("GET", paths[i], GetKey(), nil)
-		if e != nil {
-			return false, ""
-		}
-		if r.StatusCode == http.StatusOK {
-			return true, paths[i]
-		}
+	if result, _ := resolvePath(pathSplit[2:], existsAt); result != nil {
+		return true, result.URL
 	}
 	return false, ""
 }
@@ -4,9 +4,9 @@ package controllers
 //since it a has more complex algorithm
 
 import (
-	"cli/models"
+	"cli/api"
+	l "cli/logger"
 	"fmt"
-	"strconv"
 	"strings"
 )
 
@@ -33,12 +33,7 @@ func StrayWalk(root **Node, prefix string, depth int) {
 				StrayAndDomain("stray-devices", prefix, depth)
 			case "Sensor":
 				//Get Stray Sensors and print them
-				r, e := models.Send("GET",
-					State.APIURL+"/api/stray-sensors", GetKey(), nil)
-				resp := ParseResponse(r, e, "fetch objects")
-				if resp != nil {
-					RemoteGetAllWalk(resp["data"].(map[string]interface{}), prefix)
-				}
+				RemoteGetAllWalk("stray-sensors", prefix)
 			default: //Error, execution should not reach here
 
 			}
@@ -70,28 +65,16 @@ func LogicalWalk(root **Node, prefix string, depth int) {
 				switch (*root).Name {
 				case "ObjectTemplates":
 					//Get All Obj Templates and print them
-					r, e := models.Send("GET",
-						State.APIURL+"/api/obj-templates", GetKey(), nil)
-					resp := ParseResponse(r, e, "fetching objects")
-					RemoteGetAllWalk(resp["data"].(map[string]interface{}), prefix)
+					RemoteGetAllWalk("obj-templates", prefix)
 				case "RoomTemplates":
 					//Get All Room Templates and print them
-					r, e := models.Send("GET",
-						State.APIURL+"/api/room-templates", GetKey(), nil)
-					resp := ParseResponse(r, e, "fetching objects")
-					RemoteGetAllWalk(resp["data"].(map[string]interface{}), prefix)
+					RemoteGetAllWalk("room-templates", prefix)
 				case "BldgTemplates":
 					//Get All Bldg Templates and print them
-					r, e := models.Send("GET",
-						State.APIURL+"/api/bldg-templates", GetKey(), nil)
-					resp := ParseResponse(r, e, "fetching objects")
-					RemoteGetAllWalk(resp["data"].(map[string]interface{}), prefix)
+					RemoteGetAllWalk("bldg-templates", prefix)
 				case "Groups":
 					//Get All Groups and print them
-					r, e := models.Send("GET",
-						State.APIURL+"/api/groups", GetKey(), nil)
-					resp := ParseResponse(r, e, "fetching objects")
-					RemoteGetAllWalk(resp["data"].(map[string]interface{}), prefix)
+					RemoteGetAllWalk("groups", prefix)
 				default: //Error case, execution should not reach here
 
 				}
@@ -160,14 +143,7 @@ func PhysicalWalk(root **Node, prefix, path string, depth int) {
 
 				//Get and Print Stray Sensors
 				fmt.Println(prefix + "└──Sensor")
-				r1, e1 := models.Send("GET",
-					State.APIURL+"/api/stray-sensors", GetKey(), nil)
-				resp1 := ParseResponse(r1, e1, "fetch objects")
-
-				if resp1 != nil {
-					RemoteGetAllWalk(resp1["data"].(map[string]interface{}),
-						prefix+"    ")
-				}
+				RemoteGetAllWalk("stray-sensors", prefix+"    ")
 			} else { //Extra else block for correct printing
 				fmt.Println(prefix + "└──Sensor")
 			}
@@ -177,141 +153,9 @@ func PhysicalWalk(root **Node, prefix, path string, depth int) {
 
 		}
 	}
-	if len(arr) == 2 { //Means path== "/Physical"
-
-		var resp map[string]interface{}
-		if arr[1] == "Physical" { //Means path== "/Physical"
-
-			//Need to check num tenants before passing the prefix
-			//Get and Print Tenants Block
-
-			r, e := models.Send("GET",
-				State.APIURL+"/api/tenants", GetKey(), nil)
-			resp = ParseResponse(r, e, "fetch objects")
-			strayNode := FindNodeInTree(&State.TreeHierarchy,
-				StrToStack("/Physical/Stray"), true)
-
-			if length, _ := GetRawObjectsLength(resp); length > 0 {
-				fmt.Println(prefix + "├──" + " Stray")
-				StrayWalk(strayNode, prefix+"│   ", depth)
-			} else {
-				fmt.Println(prefix + "└──" + " Stray")
-				StrayWalk(strayNode, prefix+"   ", depth)
-			}
-
-			if resp != nil {
-				if depth == 0 {
-					if _, ok := resp["data"]; ok {
-						RemoteGetAllWalk(resp["data"].(map[string]interface{}),
-							prefix)
-					}
-					return
-				}
-
-			}
-
-			if depth > 0 {
-				if _, ok := resp["data"]; ok {
-					tenants := GetRawObjects(resp)
-
-					size := len(tenants)
-					for idx, tInf := range tenants {
-						tenant := tInf.(map[string]interface{})
-						ID := tenant["id"].(string)
-						depthStr := strconv.Itoa(depth)
-
-						var subPrefix string
-						var currPrefix string
-						if idx == size-1 {
-							subPrefix = prefix + "    "
-							currPrefix = prefix + "└──"
-						} else {
-							subPrefix = prefix + "│   "
-							currPrefix = prefix + "├──"
-						}
-
-						fmt.Println(currPrefix + tenant["name"].(string))
-
-						//Get Hierarchy for each tenant and walk
-						r, e := models.Send("GET",
-							State.APIURL+"/api/tenants/"+ID+"/all?limit="+depthStr, GetKey(), nil)
-						resp := ParseResponse(r, e, "fetch objects")
-						if resp != nil {
-							RemoteHierarchyWalk(resp["data"].(map[string]interface{}),
-								subPrefix, depth)
-						}
-
-					}
-				}
-
-			}
-		} else { //Means path == "/"
-
-			if depth >= 0 {
-
-				strayNode := FindNodeInTree(&State.TreeHierarchy,
-					StrToStack("/Physical/Stray"), true)
-
-				//Get and Print Tenants Block
-				r, e := models.Send("GET",
-					State.APIURL+"/api/tenants", GetKey(), nil)
-				resp = ParseResponse(r, e, "fetch objects")
-
-				//Need to check num tenants before passing the prefix
-				if length, _ := GetRawObjectsLength(resp); length > 0 {
-					fmt.Println(prefix + "├──" + " Stray")
-					StrayWalk(strayNode, prefix+"│   ", depth)
-				} else {
-					fmt.Println(prefix + "└──" + " Stray")
-					StrayWalk(strayNode, prefix+"   ", depth)
-				}
-				if resp != nil {
-					if depth == 0 {
-						if _, ok := resp["data"]; ok {
-							RemoteGetAllWalk(resp["data"].(map[string]interface{}),
-								prefix)
-						}
-						return
-					}
-
-				}
-
-				//If hierarchy happens to be greater than 1
-				if depth > 0 && resp != nil {
-					if tenants := GetRawObjects(resp); tenants != nil {
-						size := len(tenants)
-						for idx, tInf := range tenants {
-							tenant := tInf.(map[string]interface{})
-							ID := tenant["id"].(string)
-							depthStr := strconv.Itoa(depth)
-
-							//Get Hierarchy for each tenant and walk
-							r, e := models.Send("GET",
-								State.APIURL+"/api/tenants/"+ID+"/all?limit="+depthStr, GetKey(), nil)
-							resp := ParseResponse(r, e, "fetch objects")
-
-							var subPrefix string
-							var currPrefix string
-							if idx == size-1 {
-								subPrefix = prefix + "    "
-								currPrefix = prefix + "└──"
-							} else {
-								subPrefix = prefix + "│   "
-								currPrefix = prefix + "├──"
-							}
-
-							fmt.Println(currPrefix + tenant["name"].(string))
-							if resp != nil {
-								RemoteHierarchyWalk(resp["data"].(map[string]interface{}),
-									subPrefix, depth)
-							}
-						}
-					}
-				}
-
-			}
-		}
-
+	//Means path == "/Physical", or path == "/"
+	if len(arr) == 2 && (arr[1] == "Physical" || depth >= 0) {
+		tenantsWalk(prefix, depth)
 	}
 
 	if len(arr) > 3 { //Could still be Stray not sure yet
@@ -324,175 +168,147 @@ func PhysicalWalk(root **Node, prefix, path string, depth int) {
 	}
 }
 
-// Helper function for TreeWalk commands, this will filter out
-// objects that a have a ParentID
-func Filter(root map[string]interface{}, depth int, ent string) {
-	var arr []interface{}
-	var replacement []interface{}
-	if root == nil {
-		return
+// Prints the stray objects, then the tenants with their
+// hierarchies (meant for walking /Physical)
+func tenantsWalk(prefix string, depth int) {
+	tenants := fetchAll("tenants")
+	strayNode := FindNodeInTree(&State.TreeHierarchy,
+		StrToStack("/Physical/Stray"), true)
+
+	//Need to check num tenants before passing the prefix
+	if len(tenants) > 0 {
+		fmt.Println(prefix + "├──" + " Stray")
+		StrayWalk(strayNode, prefix+"│   ", depth)
+	} else {
+		fmt.Println(prefix + "└──" + " Stray")
+		StrayWalk(strayNode, prefix+"   ", depth)
 	}
 
-	if _, ok := root["objects"]; !ok {
-		return
-	}
+	for idx, tenant := range tenants {
+		var subPrefix string
+		var currPrefix string
+		if idx == len(tenants)-1 {
+			subPrefix = prefix + "    "
+			currPrefix = prefix + "└──"
+		} else {
+			subPrefix = prefix + "│   "
+			currPrefix = prefix + "├──"
+		}
 
-	if _, ok := root["objects"].([]interface{}); !ok {
-		return
-	}
-	arr = root["objects"].([]interface{})
-	//length = len(arr)
-
-	for _, m := range arr {
-		if object, ok := m.(map[string]interface{}); ok {
-			if object["parentId"] == nil {
-				//Change m -> result of hierarchal API call
-				ext := object["id"].(string) + "/all?limit=" + strconv.Itoa(depth)
-				URL := State.APIURL + "/api/" + ent + "/" + ext
-				r, _ := models.Send("GET", URL, GetKey(), nil)
-				parsed := ParseResponse(r, nil, "Fetch "+ent)
-				m = parsed["data"].(map[string]interface{})
-				replacement = append(replacement, m)
-				//Disp(m.(map[string]interface{}))
+		fmt.Println(currPrefix + tenant.Name)
+		if depth > 0 {
+			//Get Hierarchy for each tenant and walk
+			root, e := API().Hierarchy("tenant", tenant.ID, depth)
+			if e != nil {
+				l.GetWarningLogger().Println("Cannot get the hierarchy of", tenant.Name, ":", e)
+				continue
 			}
+			RemoteHierarchyWalk(root, subPrefix, depth)
 		}
 	}
-
-	root["objects"] = replacement
 }
 
 func ObjectAndHierarchWalk(path, prefix string, depth int) {
-	depthStr := strconv.Itoa(depth + 1)
-
-	//Need to convert path to URL then append /all?limit=depthStr
-	_, urls := CheckPathOnline(path)
-	r, e := models.Send("GET", urls, GetKey(), nil)
-	//WE need to get the Object in order for us to create
-	//the correct GET /all?limit=depthStr URL
-	//we get the object category and ID in the JSON response
-
-	parsed := ParseResponse(r, e, "get object")
-	if parsed != nil {
-
-		obj := parsed["data"].(map[string]interface{})
-		cat := obj["category"].(string)
-		ID := obj["id"].(string)
-		URL := State.APIURL + "/api/" +
-			cat + "s/" + ID + "/all?limit=" + depthStr
-		r1, e1 := models.Send("GET", URL, GetKey(), nil)
-		parsedRoot := ParseResponse(r1, e1, "get object hierarchy")
-		if parsedRoot != nil {
-			if _, ok := parsedRoot["data"]; ok {
-				RemoteHierarchyWalk(
-					parsedRoot["data"].(map[string]interface{}),
-					prefix, depth+1)
-			}
+	found, urls := CheckPathOnline(path)
+	if !found {
+		l.GetWarningLogger().Println("Object to walk not found :", path)
+		return
+	}
 
-		}
+	//WE need to get the Object in order for us to get
+	//its hierarchy from its category and ID
+	obj, e := API().ObjectAt(urls)
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot get the object to walk :", e)
+		return
+	}
+	root, e := API().Hierarchy(obj.Category, obj.ID, depth+1)
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot get the object hierarchy :", e)
+		return
 	}
+	RemoteHierarchyWalk(root, prefix, depth+1)
 }
 
 // Gets all objects and filters out the objs with PID and adds
 // the respective hierarchies of each object and walks them
 // (meant for walking stray and domain objs)
 func StrayAndDomain(ent, prefix string, depth int) {
-	//Do the call, filter and perform remote
-	//hierarchy walk
-	//Get All Domains OR Stray Devices and print them
-	r, e := models.Send("GET",
-		State.APIURL+"/api/"+ent, GetKey(), nil)
-	resp := ParseResponse(r, e, "fetching objects")
-	if resp != nil {
-		if _, ok := resp["data"]; ok {
-			data := resp["data"].(map[string]interface{})
-			Filter(data, depth, ent)
-
-			if objects, ok := data["objects"]; ok {
-				length := len(objects.([]interface{}))
-				for i, obj := range objects.([]interface{}) {
-					if m, ok := obj.(map[string]interface{}); ok {
-						subname := m["name"].(string)
-
-						if i == length-1 {
-							fmt.Println(prefix+"└──", subname)
-							RemoteHierarchyWalk(m, prefix+"    ", depth-1)
-						} else {
-							fmt.Println(prefix+("├──"), subname)
-							RemoteHierarchyWalk(m, prefix+"│   ", depth-1)
-						}
-					}
-
-				}
-			}
+	roots := []*api.Object{}
+	for _, obj := range fetchAll(ent) {
+		if obj.ParentID != "" {
+			continue
 		}
-
-	}
-
-}
-
-func RemoteGetAllWalk(root map[string]interface{}, prefix string) {
-	var arr []interface{}
-	var length int
-	if root == nil {
-		return
+		root, e := API().Hierarchy(strings.TrimSuffix(ent, "s"), obj.ID, depth)
+		if e != nil {
+			l.GetWarningLogger().Println("Cannot get the hierarchy of", obj.Name, ":", e)
+			continue
+		}
+		roots = append(roots, root)
 	}
 
-	if _, ok := root["objects"]; !ok {
-		return
+	for i, root := range roots {
+		if i == len(roots)-1 {
+			fmt.Println(prefix+"└──", root.Name)
+			RemoteHierarchyWalk(root, prefix+"    ", depth-1)
+		} else {
+			fmt.Println(prefix+("├──"), root.Name)
+			RemoteHierarchyWalk(root, prefix+"│   ", depth-1)
+		}
 	}
+}
 
-	if _, ok := root["objects"].([]interface{}); !ok {
-		return
+// Returns the objects of a collection of the API, such as
+// tenants or stray-devices, logs why they cannot be fetched
+func fetchAll(collection string) []*api.Object {
+	objs, e := API().ObjectsAt(State.APIURL + "/api/" + collection)
+	if e != nil {
+		l.GetWarningLogger().Println("Cannot get the "+collection+" :", e)
+		return nil
 	}
-	arr = root["objects"].([]interface{})
-	length = len(arr)
+	return objs
+}
 
-	for i, m := range arr {
-		var subname string
-		if n, ok := m.(map[string]interface{})["name"].(string); ok {
-			subname = n
-		} else {
-			subname = m.(map[string]interface{})["slug"].(string)
+// Prints the names of the objects of a collection,
+// or the slugs of its templates
+func RemoteGetAllWalk(collection, prefix string) {
+	var names []string
+	if strings.HasSuffix(collection, "-templates") {
+		templates, e := API().TemplatesAt(State.APIURL + "/api/" + collection)
+		if e != nil {
+			l.GetWarningLogger().Println("Cannot get the "+collection+" :", e)
+			return
 		}
+		for _, tmpl := range templates {
+			names = append(names, tmpl.Slug)
+		}
+	} else {
+		for _, obj := range fetchAll(collection) {
+			names = append(names, obj.Name)
+		}
+	}
 
-		if i == length-1 {
-			fmt.Println(prefix+"└──", subname)
-
+	for i, name := range names {
+		if i == len(names)-1 {
+			fmt.Println(prefix+"└──", name)
 		} else {
-			fmt.Println(prefix+("├──"), subname)
+			fmt.Println(prefix+("├──"), name)
 		}
 	}
 }
 
-func RemoteHierarchyWalk(root map[string]interface{}, prefix string, depth int) {
-
+func RemoteHierarchyWalk(root *api.Object, prefix string, depth int) {
 	if depth == 0 || root == nil {
 		return
 	}
-	if infants, ok := root["children"]; !ok || infants == nil {
-		return
-	}
-
-	//name := root["name"].(string)
-	//println(prefix + name)
-
-	//or cast to []interface{}
-	arr := root["children"].([]interface{})
-
-	//or cast to []interface{}
-	length := len(arr)
-
-	//or cast to []interface{}
-	for i, mInf := range arr {
-		m := mInf.(map[string]interface{})
-		subname := m["name"].(string)
 
-		if i == length-1 {
-			fmt.Println(prefix+"└──", subname)
-			RemoteHierarchyWalk(m, prefix+"    ", depth-1)
+	for i, child := range root.Children {
+		if i == len(root.Children)-1 {
+			fmt.Println(prefix+"└──", child.Name)
+			RemoteHierarchyWalk(child, prefix+"    ", depth-1)
 		} else {
-			fmt.Println(prefix+("├──"), subname)
-			RemoteHierarchyWalk(m, prefix+"│   ", depth-1)
+			fmt.Println(prefix+("├──"), child.Name)
+			RemoteHierarchyWalk(child, prefix+"│   ", depth-1)
 		}
 	}
 }
@@ -0,0 +1,97 @@
+package controllers
+
+//This file converts the numbers given with a unit
+//to the units of the attributes of the objects
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// A number followed by a unit, such as 600mm or 42U
+type Quantity struct {
+	Value float64
+	Unit  string
+}
+
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.Value, 'f', -1, 64) + q.Unit
+}
+
+const TILESIZE = .6 //meter
+
+// Length of the units in meters, f is the floor unit of the feet
+var unitLengths = map[string]float64{
+	"mm": .001,
+	"cm": .01,
+	"m":  1,
+	"ft": .3048,
+	"f":  .3048,
+	"U":  RACKUNIT,
+	"t":  TILESIZE,
+}
+
+// The units that may follow a number in OCLI
+var LiteralUnits = []string{"mm", "cm", "m", "ft", "U", "t"}
+
+// Rack units measure heights and tiles measure the floor,
+// they cannot be converted to each other
+func compatibleUnits(from, to string) bool {
+	return !(from == "U" && to == "t") && !(from == "t" && to == "U")
+}
+
+// Converts a number to the given unit, a number
+// without unit is considered to be in this unit
+func ConvertLength(v interface{}, unit string) (float64, error) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case Quantity:
+		toLength, ok := unitLengths[unit]
+		if !ok {
+			return 0, fmt.Errorf("cannot convert %v : unknown unit %s", v, unit)
+		}
+		if !compatibleUnits(v.Unit, unit) {
+			return 0, fmt.Errorf("cannot convert %v to %s", v, unit)
+		}
+		value := v.Value * unitLengths[v.Unit] / toLength
+		//Drops the rounding errors of the conversion
+		return math.Round(value*1e9) / 1e9, nil
+	}
+	return 0, fmt.Errorf("%v is not a number", v)
+}
+
+// Converts the numbers of attr[key] to the units expected by the
+// attribute, the i-th element of an array is converted to units[i]
+// and the last unit is used for the remaining elements.
+// The other values, such as the names of templates, are left as is
+func convertAttrUnits(attr map[string]interface{}, key string, units ...string) error {
+	unitOf := func(i int) string {
+		if i >= len(units) {
+			return units[len(units)-1]
+		}
+		return units[i]
+	}
+	switch v := attr[key].(type) {
+	case Quantity:
+		value, err := ConvertLength(v, unitOf(0))
+		if err != nil {
+			return fmt.Errorf("%s : %s", key, err.Error())
+		}
+		attr[key] = value
+	case []interface{}:
+		values := []float64{}
+		for i := range v {
+			value, err := ConvertLength(v[i], unitOf(i))
+			if err != nil {
+				return fmt.Errorf("%s : %s", key, err.Error())
+			}
+			values = append(values, value)
+		}
+		attr[key] = values
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package main
+
+//This file implements the step debugger of OCLI scripts,
+//started with --debug or with the debug command of the shell
+
+import (
+	"bufio"
+	"bytes"
+	c "cli/controllers"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Wraps each statement of the debugged script,
+// the debugger may stop before executing it
+type debugStatementNode struct {
+	statement node
+	line      int
+}
+
+func (n *debugStatementNode) execute() (interface{}, error) {
+	if debugger != nil {
+		if err := debugger.pause(n.line); err != nil {
+			return nil, err
+		}
+	}
+	return n.statement.execute()
+}
+
+func (n *debugStatementNode) quietValue() (interface{}, error) {
+	command, ok := n.statement.(quietCommand)
+	if !ok {
+		return n.execute()
+	}
+	if debugger != nil {
+		if err := debugger.pause(n.line); err != nil {
+			return nil, err
+		}
+	}
+	return command.quietValue()
+}
+
+// Given to the parser in its frames, parseCommand wraps each statement it
+// parses in a debugStatementNode, baseLine is the line of the parsed buffer
+type debugParse struct {
+	baseLine int
+	lines    map[int]bool //lines where a statement starts
+}
+
+func (p *debugParse) wrap(statement node, buffer string, offset int) node {
+	line := p.baseLine + strings.Count(buffer[:offset], "\n")
+	p.lines[line] = true
+	return &debugStatementNode{statement, line}
+}
+
+const (
+	debugContinue = iota //stop at the breakpoints only
+	debugStep            //stop at the next statement
+	debugNext            //stop at the next statement outside of the function calls
+	debugOut             //stop at the next statement of the calling function
+)
+
+type debugSession struct {
+	filename    string
+	source      []string
+	statements  map[int]bool
+	breakpoints map[int]bool
+	mode        int
+	depth       int //number of function calls when next or out was asked
+	lastCommand string
+	input       *bufio.Reader //read when the shell has no terminal
+}
+
+// The session of the script being debugged
+var debugger *debugSession
+
+const debugHelp = `Debugger commands :
+  s, step           execute the statement, stop inside the function calls
+  n, next           execute the statement, step over the function calls
+  o, out            run until the current function returns
+  c, continue       run until the next breakpoint
+  b, break [LINE]   set a breakpoint, list the breakpoints without LINE
+  d, delete [LINE]  delete a breakpoint, all the breakpoints without LINE
+  l, list           print the source around the current line
+  p, print EXPR     print a variable or the value of an expression
+  vars              print the variables
+  funcs             print the functions
+  q, quit           stop the script
+An empty command repeats the previous one
+`
+
+// Executes a script under the control of the debugger,
+// which stops before the first statement
+func DebugFile(path string) error {
+	return debugFile(path, os.Stdin)
+}
+
+func debugFile(path string, input io.Reader) error {
+	if debugger != nil {
+		return fmt.Errorf("a script is already being debugged")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	filename := filepath.Base(path)
+	instrument := &debugParse{lines: map[int]bool{}}
+	file, err := parseStream(filename, bytes.NewReader(content), instrument)
+	if err != nil {
+		return err
+	}
+	debugger = &debugSession{
+		filename:    filename,
+		source:      strings.Split(string(content), "\n"),
+		statements:  instrument.lines,
+		breakpoints: map[int]bool{},
+		mode:        debugStep,
+		input:       bufio.NewReader(input),
+	}
+	defer func() { debugger = nil }()
+	fmt.Println("Debugging " + filename + ", h for help")
+	return executeStatements(filename, file, false)
+}
+
+// Executes a script given on the command line under the
+// debugger and returns the exit status of the program
+func RunDebug(path string, args []string) int {
+	setScriptArgs(args)
+	return exitStatus(DebugFile(path))
+}
+
+func (d *debugSession) readCommand() (string, error) {
+	if c.State.Terminal != nil {
+		rl := *c.State.Terminal
+		rl.SetPrompt("(debug) ")
+		return rl.Readline()
+	}
+	fmt.Print("(debug) ")
+	line, err := d.input.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return line, nil
+}
+
+// Stops before the statement at the given line if a breakpoint is
+// set there or if stepping, and reads the commands of the user
+func (d *debugSession) pause(line int) error {
+	depth := len(scopeStack)
+	switch {
+	case d.breakpoints[line], d.mode == debugStep:
+	case d.mode == debugNext && depth <= d.depth:
+	case d.mode == debugOut && depth < d.depth:
+	default:
+		return nil
+	}
+	d.show(line)
+	for {
+		command, err := d.readCommand()
+		if err != nil {
+			return &interruptError{}
+		}
+		command = strings.TrimSpace(command)
+		if command == "" {
+			command = d.lastCommand
+		}
+		d.lastCommand = command
+		name, arg, _ := strings.Cut(command, " ")
+		arg = strings.TrimSpace(arg)
+		switch name {
+		case "s", "step":
+			d.mode = debugStep
+			return nil
+		case "n", "next":
+			d.mode, d.depth = debugNext, depth
+			return nil
+		case "o", "out":
+			d.mode, d.depth = debugOut, depth
+			return nil
+		case "c", "continue":
+			d.mode = debugContinue
+			return nil
+		case "q", "quit":
+			return &interruptError{}
+		case "b", "break":
+			d.setBreakpoint(arg, true)
+		case "d", "delete":
+			d.setBreakpoint(arg, false)
+		case "l", "list":
+			d.list(line)
+		case "p", "print":
+			d.print(arg)
+		case "vars":
+			d.printVars()
+		case "funcs":
+			d.printFuncs()
+		case "h", "help":
+			fmt.Print(debugHelp)
+		case "":
+		default:
+			fmt.Println("Unknown debugger command " + name + ", h for help")
+		}
+	}
+}
+
+func (d *debugSession) sourceLine(line int) string {
+	if line < 1 || line > len(d.source) {
+		return ""
+	}
+	return d.source[line-1]
+}
+
+func (d *debugSession) show(line int) {
+	fmt.Printf("%s:%d\n", d.filename, line)
+	fmt.Printf("%5d  %s\n", line, d.sourceLine(line))
+}
+
+func (d *debugSession) list(current int) {
+	for line := current - 5; line <= current+5; line++ {
+		if line < 1 || line > len(d.source) {
+			continue
+		}
+		marker := "  "
+		if line == current {
+			marker = "=>"
+		}
+		if d.breakpoints[line] {
+			marker = marker[:1] + "*"
+		}
+		fmt.Printf("%s%4d  %s\n", marker, line, d.sourceLine(line))
+	}
+}
+
+func (d *debugSession) setBreakpoint(arg string, set bool) {
+	if arg == "" {
+		lines := []int{}
+		for line := range d.breakpoints {
+			lines = append(lines, line)
+		}
+		sort.Ints(lines)
+		if !set {
+			d.breakpoints = map[int]bool{}
+			return
+		}
+		for _, line := range lines {
+			fmt.Printf("%s:%d  %s\n", d.filename, line, strings.TrimSpace(d.sourceLine(line)))
+		}
+		return
+	}
+	line, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Println("Line number expected")
+		return
+	}
+	if !set {
+		delete(d.breakpoints, line)
+		return
+	}
+	if !d.statements[line] {
+		fmt.Printf("No statement starts at line %d\n", line)
+		return
+	}
+	d.breakpoints[line] = true
+}
+
+func (d *debugSession) print(arg string) {
+	if arg == "" {
+		fmt.Println("Variable or expression expected")
+		return
+	}
+	if val, ok := getVar(arg); ok {
+		fmt.Printf("%s = %v\n", arg, val)
+		return
+	}
+	expr, frame, parseErr := parseExpr(newFrame(arg))
+	if parseErr != nil {
+		fmt.Println(parseErr.Error())
+		return
+	}
+	if frame.start != frame.end {
+		fmt.Println("Unexpected characters in expression")
+		return
+	}
+	val, err := expr.execute()
+	if err != nil {
+		fmt.Println("Error : " + err.Error())
+		return
+	}
+	fmt.Printf("%v\n", val)
+}
+
+func printSortedVars(vars map[string]interface{}) {
+	for _, name := range sortedKeys(vars) {
+		fmt.Printf("  %s = %v\n", name, vars[name])
+	}
+}
+
+func (d *debugSession) printVars() {
+	if s := currentScope(); s != nil {
+		fmt.Println("Local variables :")
+		printSortedVars(s.vars)
+	}
+	fmt.Println("Global variables :")
+	printSortedVars(dynamicSymbolTable)
+}
+
+func (d *debugSession) printFuncs() {
+	for _, name := range sortedKeys(funcTable) {
+		if def, ok := funcTable[name].(*funcDefNode); ok {
+			fmt.Println("  " + funcSignature(def))
+		}
+	}
+}
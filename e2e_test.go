@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bufio"
+	c "cli/controllers"
+	l "cli/logger"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The end-to-end tests run the shell against the offline API,
+// from a temporary directory where the logs and sessions are written
+func startOffline(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	l.InitLogs()
+	if err := c.InitMockAPI(""); err != nil {
+		t.Fatalf("cannot start the offline API : %s", err)
+	}
+	c.InitState(map[string]string{})
+}
+
+func sampleScript(t *testing.T, name string) string {
+	path, err := filepath.Abs(filepath.Join("other", "scripts", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEndToEndDemo(t *testing.T) {
+	demo := sampleScript(t, "demo.ocli")
+	startOffline(t)
+	if code := RunScript(demo, nil); code != 0 {
+		t.Fatalf("the demo script failed with status %d", code)
+	}
+	if c.State.CurrPath != "/Physical/DEMO/ALPHA/B/R1" {
+		t.Errorf("wrong current path : %s", c.State.CurrPath)
+	}
+
+	rack, _ := c.GetObject("/Physical/DEMO/ALPHA/B/R1/A02", true)
+	if rack == nil {
+		t.Fatalf("the rack A02 should exist")
+	}
+	attrs := rack["attributes"].(map[string]interface{})
+	if attrs["color"] != "ff0000" {
+		t.Errorf("the color of the rack should be updated : %v", attrs["color"])
+	}
+	if strings.ReplaceAll(attrs["posXYZ"].(string), " ", "") != "{\"x\":4,\"y\":2,\"z\":0}" {
+		t.Errorf("wrong position of the rack : %v", attrs["posXYZ"])
+	}
+	if names := c.FetchNodesAtLevel("/Physical/DEMO/ALPHA/B/R1/A01"); len(names) != 2 {
+		t.Errorf("the rack A01 should have 2 devices : %v", names)
+	}
+	if found := c.SearchObjects("rack", map[string]interface{}{"name": "A03"}); len(found) != 1 {
+		t.Errorf("the rack A03 should be found : %v", found)
+	}
+
+	for _, line := range []string{
+		"-A01/DeviceB",
+		"+dv:A03/DeviceC@1@2",
+		"A03/DeviceC:vendor=IBM",
+	} {
+		if !InterpretLine(line) {
+			t.Errorf("cannot execute %s", line)
+		}
+	}
+	if _, found := c.CheckObject("/Physical/DEMO/ALPHA/B/R1/A01/DeviceB", true); found {
+		t.Errorf("the device B should be deleted")
+	}
+	device, _ := c.GetObject("/Physical/DEMO/ALPHA/B/R1/A03/DeviceC", true)
+	if device == nil {
+		t.Fatalf("the device C should be created")
+	}
+	if vendor := device["attributes"].(map[string]interface{})["vendor"]; vendor != "IBM" {
+		t.Errorf("the vendor of the device should be updated : %v", vendor)
+	}
+	if InterpretLine("+rk:A01@[1, 2]@[60, 120, 42]@front") {
+		t.Errorf("the duplicates should be refused by the API")
+	}
+}
+
+// The children of a room and of a rack may be in several collections,
+// their paths are resolved by trying all of them
+func TestEndToEndAmbiguousPaths(t *testing.T) {
+	demo := sampleScript(t, "demo.ocli")
+	startOffline(t)
+	if code := RunScript(demo, nil); code != 0 {
+		t.Fatalf("the demo script failed with status %d", code)
+	}
+	for _, path := range []string{"/Physical/DEMO/ALPHA/B/R1/A03", "/Physical/DEMO/ALPHA/B/R1/A01/DeviceA"} {
+		for i := 0; i < 2; i++ {
+			if obj, _ := c.GetObject(path, true); obj == nil {
+				t.Errorf("%s should be found", path)
+			}
+		}
+	}
+	if _, found := c.CheckObject("/Physical/DEMO/ALPHA/B/R1/A04", true); found {
+		t.Errorf("the rack A04 should not exist")
+	}
+
+	//A03 is no longer a rack, the URL found before must not be used
+	if !InterpretLine("-/P/DEMO/ALPHA/B/R1/A03") {
+		t.Fatalf("cannot delete the rack A03")
+	}
+	if _, found := c.CheckObject("/Physical/DEMO/ALPHA/B/R1/A03", true); found {
+		t.Errorf("the rack A03 should be deleted")
+	}
+	if !InterpretLine("+co:/P/DEMO/ALPHA/B/R1/A03@{A01,A02}@cold") {
+		t.Fatalf("cannot create the corridor A03")
+	}
+	obj, _ := c.GetObject("/Physical/DEMO/ALPHA/B/R1/A03", true)
+	if obj == nil || obj["category"] != "corridor" {
+		t.Errorf("the corridor A03 should be found : %v", obj)
+	}
+}
+
+// The listings are substituted by the names of the objects,
+// the objects got by their maps, whatever their number
+func TestEndToEndCommandSubst(t *testing.T) {
+	demo := sampleScript(t, "demo.ocli")
+	startOffline(t)
+	if code := RunScript(demo, nil); code != 0 {
+		t.Fatalf("the demo script failed with status %d", code)
+	}
+	executeCommand(".var:names=$(ls /P/DEMO/ALPHA/B/R1/A01)", t)
+	executeCommand(".var:one=$(get /P/DEMO/ALPHA/B/R1/A01); .var:all=$(get /P/DEMO/ALPHA/B/R1/A0*)", t)
+	if names := dynamicSymbolTable["names"]; !reflect.DeepEqual(names, []interface{}{"DeviceA", "DeviceB"}) {
+		t.Errorf("ls should give the names of the devices : %v", names)
+	}
+	one, ok := dynamicSymbolTable["one"].(map[string]interface{})
+	if !ok || one["name"] != "A01" {
+		t.Errorf("get should give the rack : %v", dynamicSymbolTable["one"])
+	}
+	all, ok := dynamicSymbolTable["all"].([]interface{})
+	if !ok || len(all) != 3 || !reflect.DeepEqual(all[0], one) {
+		t.Errorf("get should give the 3 racks : %v", dynamicSymbolTable["all"])
+	}
+}
+
+// Deleting several objects needs -f without a terminal, the
+// children are deleted first and every failure is reported
+func TestEndToEndDeleteMany(t *testing.T) {
+	demo := sampleScript(t, "demo.ocli")
+	startOffline(t)
+	if code := RunScript(demo, nil); code != 0 {
+		t.Fatalf("the demo script failed with status %d", code)
+	}
+	deletion := func(command string) error {
+		n, err := Parse(command)
+		if err != nil {
+			t.Fatalf("cannot parse %s : %s", command, err)
+		}
+		_, execErr := n.execute()
+		return execErr
+	}
+	if deletion("- /P/DEMO/ALPHA/B/R1/A0*") == nil {
+		t.Errorf("several objects should not be deleted without -f")
+	}
+	if _, found := c.CheckObject("/Physical/DEMO/ALPHA/B/R1/A01", true); !found {
+		t.Errorf("the rack A01 should not be deleted")
+	}
+	if err := deletion("- -f /P/DEMO/ALPHA/B/R1/{A01,A01/DeviceA}"); err != nil {
+		t.Errorf("the device should be deleted before its rack : %s", err)
+	}
+	err := deletion("- -f /P/DEMO/ALPHA/B/R1/{A02,A05,A06}")
+	if err == nil || !strings.Contains(err.Error(), "A05") || !strings.Contains(err.Error(), "A06") {
+		t.Errorf("the failures of every path should be reported : %v", err)
+	}
+	for _, rack := range []string{"A01", "A02"} {
+		if _, found := c.CheckObject("/Physical/DEMO/ALPHA/B/R1/"+rack, true); found {
+			t.Errorf("the rack %s should be deleted", rack)
+		}
+	}
+}
+
+// Without a terminal to confirm it, a drawing over the threshold needs -f
+func TestEndToEndDrawThreshold(t *testing.T) {
+	demo := sampleScript(t, "demo.ocli")
+	startOffline(t)
+	if code := RunScript(demo, nil); code != 0 {
+		t.Fatalf("the demo script failed with status %d", code)
+	}
+	threshold := c.State.DrawThreshold
+	c.State.UnityClientAvail, c.State.DrawThreshold = true, 2
+	defer func() { c.State.UnityClientAvail, c.State.DrawThreshold = false, threshold }()
+	n, _ := Parse("draw /P/DEMO/ALPHA/B/R1 2")
+	if _, err := n.execute(); err == nil || !strings.Contains(err.Error(), "draw -f") {
+		t.Errorf("the drawing should be refused : %v", err)
+	}
+}
+
+// A create command with braces creates one object per path
+func TestEndToEndCreateMany(t *testing.T) {
+	demo := sampleScript(t, "demo.ocli")
+	startOffline(t)
+	if code := RunScript(demo, nil); code != 0 {
+		t.Fatalf("the demo script failed with status %d", code)
+	}
+	executeCommand("+rk:/P/DEMO/ALPHA/B/R1/X{a..c}@[1, 2]@[60, 120, 42]@front", t)
+	for _, rack := range []string{"Xa", "Xb", "Xc"} {
+		if _, found := c.CheckObject("/Physical/DEMO/ALPHA/B/R1/"+rack, true); !found {
+			t.Errorf("the rack %s should be created", rack)
+		}
+	}
+	n, _ := Parse("+rk:/P/DEMO/ALPHA/B/R1/Y{Z..a}@[1, 2]@[60, 120, 42]@front")
+	if _, err := n.execute(); err == nil {
+		t.Errorf("a range of letters of both cases should be refused")
+	}
+	if names := c.FetchNodesAtLevel("/Physical/DEMO/ALPHA/B/R1"); len(names) != 6 {
+		t.Errorf("the room should have 6 racks : %v", names)
+	}
+}
+
+func TestEndToEndSampleScripts(t *testing.T) {
+	scripts, err := filepath.Glob(filepath.Join("other", "scripts", "*.ocli"))
+	if err != nil || len(scripts) == 0 {
+		t.Fatalf("no sample script found")
+	}
+	for _, script := range scripts {
+		path := sampleScript(t, filepath.Base(script))
+		t.Run(filepath.Base(script), func(t *testing.T) {
+			if issues := CheckFile(path); len(issues) > 0 {
+				t.Errorf("the script should pass the checks : %v", issues)
+			}
+			startOffline(t)
+			if code := RunScript(path, nil); code != 0 {
+				t.Errorf("the script failed with status %d", code)
+			}
+		})
+	}
+}
+
+// Returns the lines of the examples of a manual
+// page, they are indented after its EXAMPLE title
+func manExamples(t *testing.T, page string) []string {
+	f, err := os.Open(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := []string{}
+	inExamples := false
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "EXAMPLE") {
+			inExamples = true
+		} else if inExamples && strings.HasPrefix(line, "    ") {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines
+}
+
+// Examples of the manual which fail in the room of the demo,
+// their files, objects and variables are only placeholders
+var manExampleFailures = map[string]bool{
+	"cd $x":                              true,
+	"draw $x":                            true,
+	"draw -f $x 5":                       true,
+	"undraw $x":                          true,
+	"draw 2":                             true,
+	"draw DEMO_RACK/DeviceA 2":           true,
+	"undraw DEMO_RACK/DeviceA":           true,
+	"draw /Physical/TenantA":             true,
+	"undraw /Physical/TenantA":           true,
+	"get /Physical/TenantA":              true,
+	"get ../rack01/device-ibm3":          true,
+	"draw /Physical/SITE/BLDG/ROOM/R* 1": true,
+	"get /Physical/SITE/BLDG/*/R*":       true,
+	"ls /Physical/SITE/BLDG*":            true,
+	"= DEMO":                             true,
+	"= ROOM/R*":                          true,
+	"={path/to/object1, path/to/object2, path/to/object3}": true,
+	"- DEMO/ALPHA":                                       true,
+	"- ROOM/R[0-9]*":                                     true,
+	"- -f ROOM/R[0-9]*":                                  true,
+	"unset path/to/room:attribute1":                      true,
+	"unset path/to/object:description[3]":                true,
+	".cmds: ../../scripts/ocliScript":                    true,
+	".cmds: \"path/to/scriptFile/ocliScript.ocli\"":      true,
+	"debug ../../scripts/bootstrap.ocli":                 true,
+	"debug \"path/to/scriptFile/ocliScript.ocli\"":       true,
+	"load-session mysession.ocli":                        true,
+	"load-session \"path/to/sessions/monday.ocli\"":      true,
+	"save-session \"path/to/sessions/monday.ocli\"":      true,
+	".template: ../../templates/roomTemplateA.json":      true,
+	".template: \"path/to/templates/someTemplate.json\"": true,
+	//exit stops the script with an error giving its status
+	"exit": true,
+	"if $argc < 1 { print \"usage : apply.ocli SITE\"; exit 2 }": true,
+}
+
+// The examples of the manual are executed in the room of the demo, they
+// must parse, must not crash the shell and must succeed unless they refer
+// to placeholders
+func TestEndToEndManExamples(t *testing.T) {
+	demo := sampleScript(t, "demo.ocli")
+	pages, err := filepath.Glob(filepath.Join("other", "man", "*.md"))
+	if err != nil || len(pages) == 0 {
+		t.Fatalf("no manual page found")
+	}
+	for _, page := range pages {
+		examples := manExamples(t, page)
+		if len(examples) == 0 {
+			continue
+		}
+		t.Run(filepath.Base(page), func(t *testing.T) {
+			startOffline(t)
+			if code := RunScript(demo, nil); code != 0 {
+				t.Fatalf("the demo script failed with status %d", code)
+			}
+			for _, statement := range splitStatements(examples) {
+				root, err := Parse(statement.line)
+				if err != nil {
+					t.Errorf("cannot parse %q : %s", statement.line, err.Error())
+					continue
+				}
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("%q crashed the shell : %v", statement.line, r)
+						}
+					}()
+					if root == nil {
+						return
+					}
+					if _, err := root.execute(); err != nil && !manExampleFailures[statement.line] {
+						t.Errorf("%q failed : %s", statement.line, err.Error())
+					}
+				}()
+			}
+		})
+	}
+}
@@ -0,0 +1,803 @@
+package main
+
+//This file implements the pretty-printer of OCLI scripts
+//(ocli --fmt script.ocli), it parses a script and prints it back
+//in canonical form : one statement per line, indented blocks,
+//no spaces around @, : and = and the comments of the source
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const formatIndent = "    "
+
+type sourceComment struct {
+	line     int
+	text     string
+	trailing bool //the comment follows a command on the same line
+}
+
+type formatter struct {
+	output   []string
+	indent   int
+	comments []sourceComment //comments not printed yet, sorted by line
+	blank    map[int]bool    //empty lines of the source
+	lastLine int             //last source line printed
+	buffer   string          //statement being printed
+	baseLine int             //line of the source where buffer starts
+	events   []parseEvent
+	err      error //the tree does not match the events of the parser
+	noSource bool  //formats a tree built at runtime, without offsets
+}
+
+func newFormatter(lines []string) *formatter {
+	f := &formatter{blank: map[int]bool{}}
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			f.blank[i+1] = true
+		}
+		code := stripComment(line)
+		if len(code) < len(line) {
+			text := strings.TrimRight(line[len(code):], " \t")
+			f.comments = append(f.comments, sourceComment{i + 1, text, strings.TrimSpace(code) != ""})
+		}
+	}
+	return f
+}
+
+func (f *formatter) lineOf(offset int) int {
+	return f.baseLine + strings.Count(f.buffer[:offset], "\n")
+}
+
+// Pops the next offset recorded by parseCommand, the formatter
+// walks the tree in the same order as the parser built it
+func (f *formatter) nextOffset(blockEnd bool) int {
+	if f.noSource {
+		return 0
+	}
+	if len(f.events) == 0 || f.events[0].blockEnd != blockEnd {
+		if f.err == nil {
+			f.err = fmt.Errorf("formatter out of sync with the parser")
+		}
+		return 0
+	}
+	offset := f.events[0].offset
+	f.events = f.events[1:]
+	return offset
+}
+
+// Keeps one empty line where the source had at least one
+func (f *formatter) separate(line int) {
+	if line <= f.lastLine || !f.blank[line-1] || len(f.output) == 0 {
+		return
+	}
+	last := f.output[len(f.output)-1]
+	if last != "" && !strings.HasSuffix(last, "{") {
+		f.output = append(f.output, "")
+	}
+}
+
+// Prints the comments of the source found before a line
+func (f *formatter) flushComments(line int) {
+	for len(f.comments) > 0 && f.comments[0].line < line {
+		comment := f.comments[0]
+		f.comments = f.comments[1:]
+		f.separate(comment.line)
+		f.output = append(f.output, strings.Repeat(formatIndent, f.indent)+comment.text)
+		if comment.line > f.lastLine {
+			f.lastLine = comment.line
+		}
+	}
+}
+
+// Prints a line of output for the given source line, with
+// the comment that ended this source line if there was one
+func (f *formatter) emit(line int, text string) {
+	f.flushComments(line)
+	if !strings.HasPrefix(text, "}") {
+		f.separate(line)
+	}
+	text = strings.Repeat(formatIndent, f.indent) + text
+	if len(f.comments) > 0 && f.comments[0].line == line && f.comments[0].trailing {
+		text += " " + f.comments[0].text
+		f.comments = f.comments[1:]
+	}
+	f.output = append(f.output, text)
+	if line > f.lastLine {
+		f.lastLine = line
+	}
+}
+
+func blockStatements(body node) []node {
+	if a, ok := body.(*ast); ok {
+		return a.statements
+	}
+	if body == nil {
+		return []node{}
+	}
+	return []node{body}
+}
+
+// Prints the statements of a block, one per line,
+// and returns the offset of the end of the block
+func (f *formatter) block(body node) int {
+	for _, statement := range blockStatements(body) {
+		offset := f.nextOffset(false)
+		if statement != nil {
+			f.statement(statement, offset)
+		}
+	}
+	end := f.nextOffset(true)
+	f.flushComments(f.lineOf(end))
+	return end
+}
+
+func (f *formatter) indented(body node) int {
+	f.indent++
+	end := f.block(body)
+	f.indent--
+	return end
+}
+
+// Checks if the closing brace at offset is followed by the given keyword
+func (f *formatter) followedBy(offset int, keyword string) bool {
+	if f.noSource {
+		return false
+	}
+	rest := strings.TrimLeft(f.buffer[offset+1:], " \t\n")
+	return strings.HasPrefix(rest, keyword)
+}
+
+func (f *formatter) statement(n node, offset int) {
+	line := f.lineOf(offset)
+	switch n := n.(type) {
+	case *debugStatementNode:
+		f.statement(n.statement, offset)
+	case *whileNode:
+		f.emit(line, "while "+f.expr(n.condition)+" {")
+		f.emit(f.lineOf(f.indented(n.body)), "}")
+	case *forRangeNode:
+		f.emit(line, "for "+n.variable+" in "+f.expr(n.start)+".."+f.expr(n.end)+" {")
+		f.emit(f.lineOf(f.indented(n.body)), "}")
+	case *forArrayNode:
+		f.emit(line, "for "+n.variable+" in "+f.expr(n.arr)+" {")
+		f.emit(f.lineOf(f.indented(n.body)), "}")
+	case *funcDefNode:
+		f.emit(line, "alias "+funcSignature(n)+" {")
+		f.emit(f.lineOf(f.indented(n.body)), "}")
+	case *ifNode:
+		f.ifStatement(n, line, "if")
+	case *tryNode:
+		f.emit(line, "try {")
+		end := f.lineOf(f.indented(n.tryBody))
+		if n.catchBody != nil {
+			f.emit(end, "} "+catchKeyWord(n)+" {")
+			end = f.lineOf(f.indented(n.catchBody))
+		}
+		if n.finallyBody != nil {
+			f.emit(end, "} finally {")
+			end = f.lineOf(f.indented(n.finallyBody))
+		}
+		f.emit(end, "}")
+	default:
+		f.emit(line, f.command(n))
+	}
+}
+
+func (f *formatter) ifStatement(n *ifNode, line int, keyword string) {
+	f.emit(line, keyword+" "+f.expr(n.condition)+" {")
+	endOffset := f.indented(n.ifBranch)
+	end := f.lineOf(endOffset)
+	if n.elseBranch == nil {
+		f.emit(end, "}")
+		return
+	}
+	if elif, ok := n.elseBranch.(*ifNode); ok && f.followedBy(endOffset, "elif") {
+		f.ifStatement(elif, end, "} elif")
+		return
+	}
+	f.emit(end, "} else {")
+	f.emit(f.lineOf(f.indented(n.elseBranch)), "}")
+}
+
+func funcSignature(n *funcDefNode) string {
+	if len(n.params) == 0 {
+		return n.name
+	}
+	return n.name + "(" + strings.Join(n.params, ", ") + ")"
+}
+
+func catchKeyWord(n *tryNode) string {
+	if n.errVar == "" {
+		return "catch"
+	}
+	return "catch $" + n.errVar
+}
+
+// Prints the statements of a block on a single line, used for
+// the commands given to $(...), and returns the offset of its end
+func (f *formatter) inlineBlock(body node) (string, int) {
+	commands := []string{}
+	for _, statement := range blockStatements(body) {
+		f.nextOffset(false)
+		if statement != nil {
+			commands = append(commands, f.command(statement))
+		}
+	}
+	return strings.Join(commands, "; "), f.nextOffset(true)
+}
+
+func (f *formatter) inlineBraces(body node) string {
+	s, _ := f.inlineBlock(body)
+	return "{ " + s + " }"
+}
+
+func (f *formatter) inlineIf(n *ifNode, keyword string) string {
+	body, end := f.inlineBlock(n.ifBranch)
+	s := keyword + " " + f.expr(n.condition) + " { " + body + " }"
+	if n.elseBranch == nil {
+		return s
+	}
+	if elif, ok := n.elseBranch.(*ifNode); ok && f.followedBy(end, "elif") {
+		return s + " " + f.inlineIf(elif, "elif")
+	}
+	return s + " else " + f.inlineBraces(n.elseBranch)
+}
+
+// Prints a command on a single line
+func (f *formatter) command(n node) string {
+	switch n := n.(type) {
+	case *debugStatementNode:
+		return f.command(n.statement)
+	case *whileNode:
+		return "while " + f.expr(n.condition) + " " + f.inlineBraces(n.body)
+	case *forRangeNode:
+		return "for " + n.variable + " in " + f.expr(n.start) + ".." + f.expr(n.end) +
+			" " + f.inlineBraces(n.body)
+	case *forArrayNode:
+		return "for " + n.variable + " in " + f.expr(n.arr) + " " + f.inlineBraces(n.body)
+	case *funcDefNode:
+		return "alias " + funcSignature(n) + " " + f.inlineBraces(n.body)
+	case *ifNode:
+		return f.inlineIf(n, "if")
+	case *tryNode:
+		s := "try " + f.inlineBraces(n.tryBody)
+		if n.catchBody != nil {
+			s += " " + catchKeyWord(n) + " " + f.inlineBraces(n.catchBody)
+		}
+		if n.finallyBody != nil {
+			s += " finally " + f.inlineBraces(n.finallyBody)
+		}
+		return s
+	case *funcCallNode:
+		if len(n.args) == 0 {
+			return n.name
+		}
+		return f.expr(n)
+	case *assignNode:
+		return ".var:" + n.variable + "=" + f.stringExpr(n.val)
+	case *globalNode:
+		return "global " + strings.Join(n.names, ", ")
+	case *returnNode:
+		if n.expr == nil {
+			return "return"
+		}
+		return "return " + f.expr(n.expr)
+	case *breakNode:
+		return "break"
+	case *continueNode:
+		return "continue"
+	case *lenNode:
+		return "len " + n.variable
+	case *helpNode:
+		return withArg("man", n.entry)
+	case *focusNode:
+		return ">" + rawText(n.path)
+	case *cdNode:
+		return withArg("cd", rawText(n.path))
+	case *lsNode:
+		return withArg("ls", rawText(n.path))
+	case *lsAttrNode:
+		return withArg("ls -s "+n.attr, rawText(n.path))
+	case *lsObjNode:
+		return withArg(lsCommands[n.entity]+lsObjArgs(n), rawText(n.path))
+	case *getUNode:
+		return "getu " + rawText(n.path) + " " + f.expr(n.u)
+	case *getSlotNode:
+		return "getslot " + rawText(n.path) + " " + f.stringExpr(n.slot)
+	case *getObjectNode:
+		return withArg("get", rawText(n.path))
+	case *loadNode:
+		return ".cmds:" + f.stringExpr(n.path)
+	case *debugScriptNode:
+		return "debug " + f.stringExpr(n.path)
+	case *saveSessionNode:
+		return "save-session " + f.stringExpr(n.path)
+	case *loadSessionNode:
+		return "load-session " + f.stringExpr(n.path)
+	case *loadTemplateNode:
+		return ".template:" + f.stringExpr(n.path)
+	case *printNode:
+		return "print " + f.stringExpr(n.expr)
+	case *deleteObjNode:
+		if n.force {
+			return "- -f " + rawText(n.path)
+		}
+		return "-" + rawText(n.path)
+	case *deleteSelectionNode:
+		return "-selection"
+	case *isEntityDrawableNode:
+		return "drawable " + rawText(n.path)
+	case *isAttrDrawableNode:
+		return "drawable " + rawText(n.path) + " " + n.attr
+	case *selectObjectNode:
+		return "=" + rawText(n.path)
+	case *selectChildrenNode:
+		return "=" + pathGroup(n.paths)
+	case *updateObjNode:
+		values := []string{}
+		for _, value := range n.values {
+			values = append(values, f.stringExpr(value))
+		}
+		sharpe := ""
+		if n.hasSharpe {
+			sharpe = "#"
+		}
+		return rawText(n.path) + ":" + n.attr + "=" + sharpe + strings.Join(values, "@")
+	case *treeNode:
+		return withDepth(withArg("tree", rawText(n.path)), n.depth, 0)
+	case *drawNode:
+		command := "draw"
+		if n.force {
+			command += " -f"
+		}
+		return withDepth(withArg(command, rawText(n.path)), n.depth, 0)
+	case *undrawNode:
+		if n.path == nil {
+			return "undraw"
+		}
+		return withArg("undraw", rawText(n.path))
+	case *hierarchyNode:
+		return withDepth("hc "+rawText(n.path), n.depth, 1)
+	case *unsetFuncNode:
+		return "unset -f " + n.funcName
+	case *unsetVarNode:
+		return "unset -v " + n.varName
+	case *unsetAttrNode:
+		s := "unset " + rawText(n.path) + ":" + n.attr
+		if n.index != nil {
+			s += "[" + f.expr(n.index) + "]"
+		}
+		return s
+	case *envNode:
+		return "env"
+	case *setEnvNode:
+		return "env " + n.arg + "=" + f.stringExpr(n.expr)
+	case *selectNode:
+		return "selection"
+	case *clrNode:
+		return "clear"
+	case *grepNode:
+		return "grep"
+	case *lsogNode:
+		return "lsog"
+	case *lsenterpriseNode:
+		return "lsenterprise"
+	case *pwdNode:
+		return "pwd"
+	case *exitNode:
+		if n.code == nil {
+			return "exit"
+		}
+		return "exit " + f.expr(n.code)
+	case *createManyNode:
+		return f.command(n.create)
+	case *createTenantNode:
+		return "+tenant:" + rawText(n.path) + "@" + f.color(n.color)
+	case *createSiteNode:
+		return "+site:" + rawText(n.path)
+	case *createBuildingNode:
+		return "+building:" + rawText(n.path) + "@" + f.expr(n.posXY) + "@" + f.expr(n.rotation) +
+			"@" + f.stringExpr(n.sizeOrTemplate)
+	case *createRoomNode:
+		s := "+room:" + rawText(n.path) + "@" + f.expr(n.posXY) + "@" + f.expr(n.rotation)
+		if n.template != nil {
+			return s + "@" + f.stringExpr(n.template)
+		}
+		s += "@" + f.stringExpr(n.size)
+		if n.axisOrientation != nil {
+			s += "@" + f.keyWordOrExpr(n.axisOrientation, "+x+y", "+x-y", "-x-y", "-x+y")
+		}
+		if n.floorUnit != nil {
+			s += "@" + f.keyWordOrExpr(n.floorUnit, "t", "m", "f")
+		}
+		return s
+	case *createRackNode:
+		return "+rack:" + rawText(n.path) + "@" + f.expr(n.pos) + "@" + f.stringExpr(n.sizeOrTemplate) +
+			"@" + f.keyWordOrExpr(n.orientation, "front", "rear", "left", "right")
+	case *createDeviceNode:
+		s := "+device:" + rawText(n.path) + "@" + f.stringExpr(n.posUOrSlot) + "@" + f.stringExpr(n.sizeUOrTemplate)
+		if n.side != nil {
+			s += "@" + f.keyWordOrExpr(n.side, "front", "rear", "frontflipped", "rearflipped")
+		}
+		return s
+	case *createGroupNode:
+		return "+group:" + rawText(n.path) + "@" + pathGroup(n.paths)
+	case *createCorridorNode:
+		return "+corridor:" + rawText(n.path) + "@" + pathGroup([]node{n.leftRack, n.rightRack}) +
+			"@" + f.keyWordOrExpr(n.temp, "cold", "warm")
+	case *createOrphanNode:
+		kind := "device"
+		if n.sensor {
+			kind = "sensor"
+		}
+		return "+orphan " + kind + ":" + rawText(n.path) + "@" + f.stringExpr(n.template)
+	case *uiDelayNode:
+		return "ui.delay=" + formatFloat(n.time)
+	case *uiToggleNode:
+		return "ui." + n.feature + "=" + strconv.FormatBool(n.enable)
+	case *uiHighlightNode:
+		return "ui.highlight=" + rawText(n.path)
+	case *uiClearCacheNode:
+		return "ui.clearcache"
+	case *cacheClearNode:
+		return "cache clear"
+	case *cacheStatsNode:
+		return "cache stats"
+	case *cameraMoveNode:
+		return "camera." + n.command + "=" + f.expr(n.position) + "@" + f.expr(n.rotation)
+	case *cameraWaitNode:
+		return "camera.wait=" + formatFloat(n.time)
+	case *linkObjectNode:
+		s := "link:" + rawText(n.source) + "@" + rawText(n.destination)
+		if n.slot != nil {
+			s += "@" + f.stringExpr(n.slot)
+		}
+		return s
+	case *unlinkObjectNode:
+		if n.destination == nil {
+			return "unlink " + rawText(n.source)
+		}
+		return "unlink " + rawText(n.source) + "@" + rawText(n.destination)
+	}
+	panic(fmt.Sprintf("cannot format node of type %T", n))
+}
+
+func withArg(command string, arg string) string {
+	if arg == "" {
+		return command
+	}
+	return command + " " + arg
+}
+
+func withDepth(command string, depth int, defaultDepth int) string {
+	if depth == defaultDepth {
+		return command
+	}
+	return command + " " + strconv.Itoa(depth)
+}
+
+func lsObjArgs(n *lsObjNode) string {
+	s := ""
+	if n.sort != "" {
+		s += " -s " + n.sort
+	}
+	if n.format != "" {
+		s += " -f (\"" + n.format + "\", " + strings.Join(n.attrList, ", ") + ")"
+	} else if len(n.attrList) > 0 {
+		s += " -f " + strings.Join(n.attrList, ":")
+	}
+	if n.recursive {
+		s += " -r"
+	}
+	return s
+}
+
+func pathGroup(paths []node) string {
+	texts := []string{}
+	for _, path := range paths {
+		texts = append(texts, rawText(path))
+	}
+	return "{" + strings.Join(texts, ", ") + "}"
+}
+
+func formatFloat(x float64) string {
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// Rebuilds the text of a format string, text is applied
+// to the parts of the string between the variables
+func formatStringText(n *formatStringNode, text func(string) string) string {
+	chunks := []string{""}
+	for i := 0; i < len(n.str); i++ {
+		if n.str[i] == '%' && i+1 < len(n.str) {
+			i++
+			if n.str[i] == 'v' {
+				chunks = append(chunks, "")
+				continue
+			}
+		}
+		chunks[len(chunks)-1] += n.str[i : i+1]
+	}
+	s := text(chunks[0])
+	for i, chunk := range chunks[1:] {
+		if i >= len(n.varsDeref) {
+			break
+		}
+		name := n.varsDeref[i].va
+		if chunk != "" && isAlphaNumeric(chunk[0]) || strings.Contains(name, ".") {
+			s += "${" + name + "}"
+		} else {
+			s += "$" + name
+		}
+		s += text(chunk)
+	}
+	return s
+}
+
+// Prints a path or an unquoted string
+func rawText(n node) string {
+	switch n := n.(type) {
+	case *pathNode:
+		return rawText(n.path)
+	case *strLeaf:
+		return n.val
+	case *formatStringNode:
+		return formatStringText(n, func(s string) string { return s })
+	}
+	panic(fmt.Sprintf("cannot format node of type %T as text", n))
+}
+
+var quoteEscaper = strings.NewReplacer(
+	"\\", "\\\\", "\"", "\\\"", "$", "\\$", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+
+func isExpr(n node) bool {
+	switch n.(type) {
+	case *intLeaf, *floatLeaf, *quantityLeaf, *boolLeaf, *strLeaf, *formatStringNode,
+		*symbolReferenceNode, *arrayReferenceNode, *indexNode, *fieldNode, *arrNode, *mapNode, *funcCallNode, *commandSubstNode,
+		*arithNode, *negateNode, *equalityNode, *comparatorNode, *logicalNode, *negateBoolNode,
+		*matchNode, *inNode, *conditionalNode:
+		return true
+	}
+	return false
+}
+
+func binaryOperator(n node) (string, node, node) {
+	switch n := n.(type) {
+	case *arithNode:
+		return n.op, n.left, n.right
+	case *equalityNode:
+		return n.op, n.left, n.right
+	case *comparatorNode:
+		return n.op, n.left, n.right
+	case *logicalNode:
+		return n.op, n.left, n.right
+	case *matchNode:
+		return n.op, n.left, n.right
+	case *inNode:
+		return "in", n.elt, n.container
+	}
+	return "", nil, nil
+}
+
+// Same precedences as the parser, see token.precedence
+func operatorPrecedence(op string) int {
+	switch op {
+	case "||":
+		return 1
+	case "&&":
+		return 2
+	case "==", "!=", "<", "<=", ">", ">=", "=~", "!~", "in":
+		return 3
+	case "+", "-":
+		return 4
+	case "*", "/", "%":
+		return 5
+	}
+	return 6
+}
+
+func (f *formatter) expr(n node) string {
+	s, _ := f.exprPrecedence(n)
+	return s
+}
+
+func (f *formatter) operand(n node, minPrecedence int) string {
+	s, precedence := f.exprPrecedence(n)
+	if precedence < minPrecedence {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// Prints an expression and returns the precedence of its operator,
+// to add the parentheses needed to parse it back to the same tree
+func (f *formatter) exprPrecedence(n node) (string, int) {
+	if op, left, right := binaryOperator(n); op != "" {
+		precedence := operatorPrecedence(op)
+		return f.operand(left, precedence) + " " + op + " " + f.operand(right, precedence+1), precedence
+	}
+	switch n := n.(type) {
+	case *intLeaf:
+		return strconv.Itoa(n.val), 7
+	case *floatLeaf:
+		s := formatFloat(n.val)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, 7
+	case *quantityLeaf:
+		return n.val.String(), 7
+	case *boolLeaf:
+		return strconv.FormatBool(n.val), 7
+	case *strLeaf:
+		return "\"" + quoteEscaper.Replace(n.val) + "\"", 7
+	case *formatStringNode:
+		return "\"" + formatStringText(n, quoteEscaper.Replace) + "\"", 7
+	case *symbolReferenceNode:
+		return variableReference(n.va), 7
+	case *arrayReferenceNode:
+		return variableReference(n.variable) + f.access(n.idx), 7
+	case *indexNode:
+		return f.operand(n.container, 7) + f.access(n.idx), 7
+	case *fieldNode:
+		return f.operand(n.container, 7) + "." + n.field, 7
+	case *arrNode:
+		return "[" + f.exprList(n.nodes) + "]", 7
+	case *mapNode:
+		entries := []string{}
+		for i, key := range n.keys {
+			entries = append(entries, "\""+quoteEscaper.Replace(key)+"\": "+f.expr(n.values[i]))
+		}
+		return "{" + strings.Join(entries, ", ") + "}", 7
+	case *funcCallNode:
+		return n.name + "(" + f.exprList(n.args) + ")", 7
+	case *commandSubstNode:
+		commands, _ := f.inlineBlock(n.command)
+		return "$(" + commands + ")", 7
+	case *negateNode:
+		return "-" + f.operand(n.val, 6), 6
+	case *negateBoolNode:
+		return "!" + f.operand(n.expr, 6), 6
+	case *conditionalNode:
+		return f.operand(n.condition, 1) + " ? " + f.expr(n.ifTrue) + " : " + f.expr(n.ifFalse), 0
+	}
+	panic(fmt.Sprintf("cannot format node of type %T as expression", n))
+}
+
+// Dotted names (${rack.attributes.height}) need the braces
+func variableReference(name string) string {
+	if strings.Contains(name, ".") {
+		return "${" + name + "}"
+	}
+	return "$" + name
+}
+
+var attributeRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Prints the access to an element, keys that are
+// simple words are printed with the dot notation
+func (f *formatter) access(idx node) string {
+	if leaf, ok := idx.(*strLeaf); ok && attributeRegex.MatchString(leaf.val) &&
+		leaf.val != "true" && leaf.val != "false" {
+		return "." + leaf.val
+	}
+	return "[" + f.expr(idx) + "]"
+}
+
+func (f *formatter) exprList(nodes []node) string {
+	texts := []string{}
+	for _, n := range nodes {
+		texts = append(texts, f.expr(n))
+	}
+	return strings.Join(texts, ", ")
+}
+
+var bareWordRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.\-]*$`)
+
+// Prints the value of a parameter accepting unquoted strings,
+// simple words are printed without quotes
+func (f *formatter) stringExpr(n node) string {
+	if leaf, ok := n.(*strLeaf); ok && bareWordRegex.MatchString(leaf.val) &&
+		leaf.val != "true" && leaf.val != "false" {
+		return leaf.val
+	}
+	return f.expr(n)
+}
+
+func (f *formatter) keyWordOrExpr(n node, keywords ...string) string {
+	if leaf, ok := n.(*strLeaf); ok && sliceContains(keywords, leaf.val) {
+		return leaf.val
+	}
+	return f.expr(n)
+}
+
+var colorRegex = regexp.MustCompile(`^[0-9a-fA-F]{6}$`)
+
+func (f *formatter) color(n node) string {
+	if leaf, ok := n.(*strLeaf); ok && colorRegex.MatchString(leaf.val) {
+		return leaf.val
+	}
+	return f.expr(n)
+}
+
+// Formats one statement of the source, given as split by splitStatements
+func (f *formatter) formatStatement(statement parsedLine) error {
+	events := &parseEvents{}
+	root, err := parse(statement.line, events, nil)
+	if err != nil {
+		return err
+	}
+	f.buffer = statement.line
+	f.baseLine = statement.lineNumber
+	f.events = events.list
+	f.err = nil
+	f.block(root)
+	return f.err
+}
+
+// Returns the text of a statement built at runtime, on a single line
+func statementText(n node) string {
+	f := &formatter{blank: map[int]bool{}, noSource: true}
+	return f.command(n)
+}
+
+// Returns the canonical form of an OCLI script
+func FormatSource(filename string, source string) (string, error) {
+	lines := strings.Split(strings.TrimRight(source, "\n"), "\n")
+	f := newFormatter(lines)
+	var fileErr *fileParseError
+	for _, statement := range splitStatements(lines) {
+		if braceDepth(statement.line, 0) > 0 {
+			fileErr = addLineError(fileErr, fmt.Errorf("{ opened but never closed"), filename,
+				statement.lineNumber, statement.endLineNumber, statement.line)
+			continue
+		}
+		err := f.formatStatement(statement)
+		if err != nil {
+			fileErr = addLineError(fileErr, err, filename,
+				statement.lineNumber, statement.endLineNumber, statement.line)
+		}
+	}
+	if fileErr != nil {
+		return "", fileErr
+	}
+	f.flushComments(len(lines) + 1)
+	if len(f.output) == 0 {
+		return "", nil
+	}
+	return strings.Join(f.output, "\n") + "\n", nil
+}
+
+// Formats a script file, prints the result or writes it back
+// to the file, and returns the exit code of the program
+func RunFormat(path string, write bool) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	formatted, err := FormatSource(filepath.Base(path), string(content))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	if !write {
+		fmt.Print(formatted)
+		return 0
+	}
+	if formatted == string(content) {
+		return 0
+	}
+	err = os.WriteFile(path, []byte(formatted), 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func formatScript(script string, t *testing.T) string {
+	formatted, err := FormatSource("script.ocli", script)
+	if err != nil {
+		t.Fatalf("cannot format script : %s", err.Error())
+	}
+	return formatted
+}
+
+// Describes a tree, ignoring the empty statements
+// that the blank lines of a block add to it
+func describeTree(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return "nil"
+		}
+		return describeTree(v.Elem())
+	case reflect.Slice:
+		items := []string{}
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, describeTree(v.Index(i)))
+		}
+		return "[" + strings.Join(items, " ") + "]"
+	case reflect.Struct:
+		if v.Type().Name() == "ast" {
+			statements := []string{}
+			for i := 0; i < v.Field(0).Len(); i++ {
+				if statement := v.Field(0).Index(i); !statement.IsNil() {
+					statements = append(statements, describeTree(statement))
+				}
+			}
+			if len(statements) == 1 {
+				return statements[0]
+			}
+			return "ast{" + strings.Join(statements, " ") + "}"
+		}
+		fields := []string{}
+		for i := 0; i < v.NumField(); i++ {
+			fields = append(fields, describeTree(v.Field(i)))
+		}
+		return v.Type().Name() + "{" + strings.Join(fields, " ") + "}"
+	}
+	return fmt.Sprintf("%q", fmt.Sprint(v))
+}
+
+func assertSameMeaning(formatted string, expected node, t *testing.T) {
+	n, err := Parse(formatted)
+	if err != nil {
+		t.Errorf("cannot parse formatted command : %s", err.Error())
+		return
+	}
+	if describeTree(reflect.ValueOf(n)) != describeTree(reflect.ValueOf(expected)) {
+		t.Errorf("formatting changed the meaning of the command :\n%s", formatted)
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+	for command, tree := range commandsMatching {
+		assertSameMeaning(formatScript(command, t), tree, t)
+	}
+}
+
+func TestFormatScript(t *testing.T) {
+	script := "// header\n" +
+		"\n\n" +
+		".var:a  =   42 // answer\n" +
+		"alias  f ( x,y ) {\n" +
+		"  print \"x is $x\"   // in body\n" +
+		"  // own line\n" +
+		"  return $x+$y*2\n" +
+		"}\n" +
+		"if $a==42 {print ok} elif $a>3 {print \"big\"} else {pwd}\n" +
+		"try { +rk:R1@[1,2]@[60,120,42]@front } catch $err { print \"$err\" }\n" +
+		".var:r=$(ls; pwd)\n" +
+		"print (1+2)*3 - -$a\n"
+	expected := "// header\n" +
+		"\n" +
+		".var:a=42 // answer\n" +
+		"alias f(x, y) {\n" +
+		"    print \"x is $x\" // in body\n" +
+		"    // own line\n" +
+		"    return $x + $y * 2\n" +
+		"}\n" +
+		"if $a == 42 {\n" +
+		"    print ok\n" +
+		"} elif $a > 3 {\n" +
+		"    print big\n" +
+		"} else {\n" +
+		"    pwd\n" +
+		"}\n" +
+		"try {\n" +
+		"    +rack:R1@[1, 2]@[60, 120, 42]@front\n" +
+		"} catch $err {\n" +
+		"    print \"$err\"\n" +
+		"}\n" +
+		".var:r=$(ls; pwd)\n" +
+		"print (1 + 2) * 3 - -$a\n"
+	formatted := formatScript(script, t)
+	if formatted != expected {
+		t.Errorf("unexpected formatting :\n%s\nexpected :\n%s", formatted, expected)
+	}
+	if again := formatScript(formatted, t); again != formatted {
+		t.Errorf("formatting is not idempotent :\n%s", again)
+	}
+}
+
+func TestFormatKeepsMeaning(t *testing.T) {
+	script := "print \"100% \\\"sure\\\" \\$a\\n\"\n" +
+		".var:b=(1 - (2 - 3)) / (4 * (5 % 6))\n" +
+		".var:c=!($a && $b) || $c\n" +
+		"if 5 == 6 {ls} else {if true {pwd}}\n" +
+		"for x in $(lsrack -r R) {print \"$x : \" + $(get R/$x)}\n"
+	formatted := formatScript(script, t)
+	for i, statement := range splitStatements(strings.Split(script, "\n")) {
+		expected, err := Parse(statement.line)
+		if err != nil {
+			t.Fatalf("cannot parse script : %s", err.Error())
+		}
+		statements := splitStatements(strings.Split(formatted, "\n"))
+		assertSameMeaning(statements[i].line, expected, t)
+	}
+}
+
+func TestFormatSyntaxError(t *testing.T) {
+	_, err := FormatSource("script.ocli", "print \"a\"\nwhile true {\n")
+	if err == nil || !strings.Contains(err.Error(), "LINE#: 2\t") {
+		t.Errorf("syntax error expected, got %v", err)
+	}
+}
+
+func TestFormatOutOfSync(t *testing.T) {
+	f := newFormatter([]string{"pwd"})
+	f.buffer = "pwd"
+	f.events = []parseEvent{{blockEnd: true}}
+	f.block(&pwdNode{})
+	if f.err == nil {
+		t.Errorf("a tree that does not match the events should be an error")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	cmd "cli/controllers"
 	"fmt"
 	"strconv"
 	"strings"
@@ -41,6 +42,15 @@ const (
 	tokLss        // '<'
 	tokColor
 	tokText
+	tokCommand    // command substitution '$(...)'
+	tokLeftBrace  // '{'
+	tokRightBrace // '}'
+	tokColon      // ':'
+	tokDot        // '.' before an attribute name
+	tokQuestion   // '?'
+	tokMatch      // '=~'
+	tokNotMatch   // '!~'
+	tokQuantity   // number followed by a unit
 )
 
 func (s tokenType) String() string {
@@ -76,6 +86,15 @@ func (s tokenType) String() string {
 		tokLss:        "lss",
 		tokColor:      "color",
 		tokText:       "text",
+		tokCommand:    "command",
+		tokLeftBrace:  "leftBrace",
+		tokRightBrace: "rightBrace",
+		tokColon:      "colon",
+		tokDot:        "dot",
+		tokQuestion:   "question",
+		tokMatch:      "match",
+		tokNotMatch:   "notMatch",
+		tokQuantity:   "quantity",
 	}[s]
 }
 
@@ -93,8 +112,13 @@ func (t token) precedence() int {
 		return 1
 	case tokAnd:
 		return 2
-	case tokEq, tokNeq, tokLss, tokLeq, tokGtr, tokGeq:
+	case tokEq, tokNeq, tokLss, tokLeq, tokGtr, tokGeq, tokMatch, tokNotMatch:
 		return 3
+	case tokWord:
+		// the in operator
+		if t.str == "in" {
+			return 3
+		}
 	case tokAdd, tokSub:
 		return 4
 	case tokMul, tokDiv, tokMod:
@@ -108,12 +132,15 @@ func (t token) precedence() int {
 const eof = 0
 
 type lexer struct {
-	input string
-	pos   int
-	start int
-	end   int
-	tok   token
-	atEOF bool
+	input      string
+	pos        int
+	start      int
+	end        int
+	tok        token
+	atEOF      bool
+	braces     int // braces opened and not yet closed in a path
+	events     *parseEvents
+	instrument *debugParse
 }
 
 type stateFn func(*lexer) stateFn
@@ -220,6 +247,12 @@ func lexExpr(l *lexer) stateFn {
 		return l.emit(tokRightBrac, nil)
 	case ',':
 		return l.emit(tokComma, nil)
+	case '{':
+		return l.emit(tokLeftBrace, nil)
+	case '}':
+		return l.emit(tokRightBrace, nil)
+	case ':':
+		return l.emit(tokColon, nil)
 	case '(':
 		return l.emit(tokLeftParen, nil)
 	case ')':
@@ -245,16 +278,24 @@ func lexExpr(l *lexer) stateFn {
 		}
 		return l.emit(tokAnd, nil)
 	case '=':
-		if l.next() != '=' {
-			return l.errorf("= expected")
+		switch l.next() {
+		case '=':
+			return l.emit(tokEq, nil)
+		case '~':
+			return l.emit(tokMatch, nil)
 		}
-		return l.emit(tokEq, nil)
+		return l.errorf("= or ~ expected")
 	case '!':
-		if l.next() == '=' {
+		switch l.next() {
+		case '=':
 			return l.emit(tokNeq, nil)
+		case '~':
+			return l.emit(tokNotMatch, nil)
 		}
 		l.backup()
 		return l.emit(tokNot, nil)
+	case '?':
+		return l.emit(tokQuestion, nil)
 	case '<':
 		if l.next() == '=' {
 			return l.emit(tokLeq, nil)
@@ -276,6 +317,11 @@ func lexExpr(l *lexer) stateFn {
 		if l.accept(".") {
 			return l.emit(tokEOF, nil)
 		}
+		c = l.next()
+		l.backup()
+		if isLetter(c) {
+			return l.emit(tokDot, nil)
+		}
 		l.backup()
 		return lexNumber
 	}
@@ -289,6 +335,9 @@ func lexDeref(l *lexer) stateFn {
 	if l.accept("{") {
 		return lexDerefBracket
 	}
+	if l.accept("(") {
+		return lexCommand
+	}
 	if !isAlphaNumeric(l.next()) {
 		l.backup()
 		return l.errorf("identifier expected")
@@ -297,11 +346,34 @@ func lexDeref(l *lexer) stateFn {
 	return l.emit(tokDeref, l.input[l.start+1:l.pos])
 }
 
+// Lexes a command substitution until its matching parenthesis,
+// the command itself is parsed by the parser
+func lexCommand(l *lexer) stateFn {
+	depth := 1
+	inString := false
+	for depth > 0 {
+		c := l.next()
+		switch {
+		case c == eof:
+			return l.errorf("$( opened but never closed")
+		case inString && c == '\\':
+			l.next()
+		case c == '"':
+			inString = !inString
+		case !inString && c == '(':
+			depth++
+		case !inString && c == ')':
+			depth--
+		}
+	}
+	return l.emit(tokCommand, nil)
+}
+
 func lexDerefBracket(l *lexer) stateFn {
 	for isSpace(l.next()) {
 	}
 	l.backup()
-	for isAlphaNumeric(l.next()) {
+	for c := l.next(); isAlphaNumeric(c) || c == '.'; c = l.next() {
 	}
 	l.backup()
 	for isSpace(l.next()) {
@@ -318,6 +390,10 @@ func lexString(l *lexer) stateFn {
 		switch l.next() {
 		case eof:
 			return l.errorf("unterminated string")
+		case '\\':
+			if l.next() == eof {
+				return l.errorf("unterminated string")
+			}
 		case '"':
 			return l.emit(tokString, l.input[l.start+1:l.pos-1])
 		}
@@ -328,11 +404,30 @@ func (l *lexer) endNumber(t tokenType, val any) stateFn {
 	c := l.next()
 	l.backup()
 	if isLetter(c) {
+		if unit, ok := l.acceptUnit(); ok {
+			value, _ := getFloat(val)
+			return l.emit(tokQuantity, cmd.Quantity{Value: value, Unit: unit})
+		}
 		return lexAlphaNumeric
 	}
 	return l.emit(t, val)
 }
 
+// Accepts the unit following a number (600mm, 42U...)
+func (l *lexer) acceptUnit() (string, bool) {
+	numberEnd := l.pos
+	l.acceptRunAlphaNumeric()
+	word := l.input[numberEnd:l.pos]
+	for _, unit := range cmd.LiteralUnits {
+		if word == unit {
+			return unit, true
+		}
+	}
+	l.pos = numberEnd
+	l.atEOF = false
+	return "", false
+}
+
 func lexNumber(l *lexer) stateFn {
 	digits := "0123456789_"
 	l.acceptRun(digits)
@@ -400,15 +495,75 @@ func lexText(l *lexer, endCharacters string, caller stateFn) stateFn {
 }
 
 func lexUnquotedString(l *lexer) stateFn {
-	return lexText(l, " @;,})", lexUnquotedString)
+	return lexText(l, " @;,})\n", lexUnquotedString)
+}
+
+var escapeSequences = map[byte]string{
+	'n':  "\n",
+	't':  "\t",
+	'r':  "\r",
+	'"':  "\"",
+	'\\': "\\",
+	'$':  "$",
 }
 
+// Lexes the content of a quoted string, the text between escape
+// sequences and variable dereferences is emitted as tokText tokens
 func lexQuotedString(l *lexer) stateFn {
-	return lexText(l, "", lexQuotedString)
+	c := l.next()
+	if c == '$' {
+		return lexDeref
+	}
+	if c == '\\' {
+		return lexEscape
+	}
+	for {
+		if c == eof || c == '$' || c == '\\' {
+			l.backup()
+			if l.pos == l.start {
+				return l.emit(tokEOF, nil)
+			}
+			return l.emit(tokText, nil)
+		}
+		c = l.next()
+	}
+}
+
+func lexEscape(l *lexer) stateFn {
+	c := l.next()
+	if c == eof {
+		return l.errorf("unterminated escape sequence")
+	}
+	val, ok := escapeSequences[c]
+	if !ok {
+		return l.errorf("unknown escape sequence \\%c", c)
+	}
+	return l.emit(tokText, val)
 }
 
+// Braces are part of a path, with their commas : R{01..10}, {A,B}{1..4},
+// they are expanded by the commands
 func lexPath(l *lexer) stateFn {
-	return lexText(l, " @;,}):", lexPath)
+	c := l.next()
+	if c == '$' {
+		return lexDeref
+	}
+	for {
+		switch {
+		case c == '{':
+			l.braces++
+		case c == '}' && l.braces > 0:
+			l.braces--
+		case c == ',' && l.braces > 0:
+		case c == eof || strings.Contains(" @;,}):\n$", string(c)):
+			l.backup()
+			if l.pos == l.start {
+				return l.emit(tokEOF, nil)
+			}
+			return l.emit(tokText, nil)
+		}
+		c = l.next()
+	}
 }
 
 func (l *lexer) nextToken(state stateFn) token {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	cmd "cli/controllers"
 	"testing"
 )
 
@@ -38,3 +39,25 @@ func TestLexFormattedString(t *testing.T) {
 	expectedVals := []any{"a", nil, "ab", nil}
 	checkTokSequence(lexUnquotedString, expectedTypes, expectedVals, str, t)
 }
+
+func TestLexEscapedString(t *testing.T) {
+	str := "\"a\\\"b\" + 1"
+	expectedTypes := []tokenType{tokString, tokAdd, tokInt, tokEOF}
+	expectedVals := []any{"a\\\"b", nil, 1, nil}
+	checkTokSequence(lexExpr, expectedTypes, expectedVals, str, t)
+}
+
+func TestLexQuotedStringEscapes(t *testing.T) {
+	str := "a\\tb\\$c$d"
+	expectedTypes := []tokenType{tokText, tokText, tokText, tokText, tokText, tokDeref, tokEOF}
+	expectedVals := []any{nil, "\t", nil, "$", nil, "d", nil}
+	checkTokSequence(lexQuotedString, expectedTypes, expectedVals, str, t)
+}
+
+func TestLexUnits(t *testing.T) {
+	str := "600mm + 2.4m 42U 3th"
+	expectedTypes := []tokenType{tokQuantity, tokAdd, tokQuantity, tokQuantity, tokWord, tokEOF}
+	expectedVals := []any{cmd.Quantity{Value: 600, Unit: "mm"}, nil, cmd.Quantity{Value: 2.4, Unit: "m"},
+		cmd.Quantity{Value: 42, Unit: "U"}, nil, nil}
+	checkTokSequence(lexExpr, expectedTypes, expectedVals, str, t)
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	c "cli/controllers"
 	"flag"
+	"os"
+	"strings"
 )
 
 type Flags struct {
@@ -13,6 +16,12 @@ type Flags struct {
 	envPath    string
 	histPath   string
 	script     string
+	check      string
+	format     string
+	write      bool
+	debug      bool
+	offline    bool
+	args       []string
 }
 
 // Assign value to flag with preference to 'x'
@@ -29,7 +38,8 @@ func NonDefault[T comparable](x, y, defaultValue T) T {
 func main() {
 	var listenPORT, l int
 	var verboseLevel, v, unityURL, u, APIURL, a, APIKEY, k,
-		envPath, e, histPath, h, file, f string
+		envPath, e, histPath, h, file, f, check, format string
+	var write, debug, offline bool
 
 	flag.StringVar(&v, "v", "ERROR",
 		"Indicates level of debugging messages."+
@@ -44,8 +54,13 @@ func main() {
 	flag.StringVar(&unityURL, "unity_url", "", "Unity URL")
 	flag.StringVar(&u, "u", "", "Unity URL")
 
-	flag.StringVar(&APIURL, "api_url", "", "API URL")
-	flag.StringVar(&a, "a", "", "API URL")
+	flag.StringVar(&APIURL, "api_url", "", "API URL, mock:// for the API "+
+		"built into the shell, mock://file.json to save its objects in a file")
+	flag.StringVar(&a, "a", "", "API URL, mock:// for the API "+
+		"built into the shell, mock://file.json to save its objects in a file")
+
+	flag.BoolVar(&offline, "offline", false, "Use the API built into the "+
+		"shell, its objects are kept in memory (same as --api_url mock://)")
 
 	flag.IntVar(&listenPORT, "listen_port", 0,
 		"Indicates which port to communicate to Unity")
@@ -70,6 +85,16 @@ func main() {
 	flag.StringVar(&f, "f", "", "Launch the shell as an interpreter "+
 		" by only executing an OCLI script file")
 
+	flag.StringVar(&check, "check", "", "Statically check an OCLI script file "+
+		"without executing it nor contacting the API")
+
+	flag.StringVar(&format, "fmt", "", "Print an OCLI script file in canonical form")
+	flag.BoolVar(&write, "w", false, "With --fmt, write the result to the "+
+		"script file instead of printing it")
+
+	flag.BoolVar(&debug, "debug", false, "With -f, execute the script "+
+		"step by step under the control of the debugger")
+
 	flag.Parse()
 
 	var flags Flags
@@ -81,6 +106,24 @@ func main() {
 	flags.envPath = NonDefault(e, envPath, "./.env")
 	flags.histPath = NonDefault(h, histPath, "./.history")
 	flags.script = NonDefault(f, file, "")
+	flags.check = check
+	flags.format = format
+	flags.write = write
+	flags.debug = debug
+	flags.offline = offline
+	if flags.offline && !strings.HasPrefix(flags.APIURL, c.MockAPIURL) {
+		flags.APIURL = c.MockAPIURL
+	}
+	flags.args = flag.Args()
+
+	if flags.check != "" {
+		os.Exit(RunCheck(flags.check))
+	}
+
+	if flags.format != "" {
+		os.Exit(RunFormat(flags.format, flags.write))
+	}
+
 	//Pass control to repl.go
 	Start(&flags)
 }
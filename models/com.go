@@ -2,33 +2,146 @@ package models
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"math/rand"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// Context of the API requests, the shell cancels
+// it when the user interrupts the current command
+var requestContext = context.Background()
+
+func SetContext(ctx context.Context) {
+	requestContext = ctx
+}
+
+func Context() context.Context {
+	return requestContext
+}
+
+// Settings of the API requests, the shell reads them from the .env file
+type RetryConfig struct {
+	MaxRetries int           //attempts after the first one
+	BaseDelay  time.Duration //delay before the first retry, doubled at each retry
+	MaxDelay   time.Duration
+	Timeout    time.Duration //of a whole request, response body included
+}
+
+var DefaultRetryConfig = RetryConfig{
+	MaxRetries: 3,
+	BaseDelay:  200 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+	Timeout:    30 * time.Second,
+}
+
+var retryConfig = DefaultRetryConfig
+
+// Sends the requests of the URLs with a registered scheme
+// to their own RoundTripper, the others to the network
+type protocolTransport struct {
+	mu        sync.RWMutex
+	protocols map[string]http.RoundTripper
+}
+
+func (t *protocolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	rt, ok := t.protocols[req.URL.Scheme]
+	t.mu.RUnlock()
+	if ok {
+		return rt.RoundTrip(req)
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+var transport = &protocolTransport{protocols: map[string]http.RoundTripper{}}
+
+// Shared by the requests to reuse their connections
+var client = &http.Client{Timeout: DefaultRetryConfig.Timeout, Transport: transport}
+
+// Sends the requests of the URLs with the given scheme to rt,
+// such as those of the API built into the shell
+func RegisterProtocol(scheme string, rt http.RoundTripper) {
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	transport.protocols[scheme] = rt
+}
+
+func SetRetryConfig(config RetryConfig) {
+	retryConfig = config
+	client.Timeout = config.Timeout
+}
+
+// The requests that can be sent again without changing their result
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// The statuses of a gateway or a server that cannot answer for now
+func isTransientStatus(status int) bool {
+	return status == http.StatusBadGateway ||
+		status == http.StatusServiceUnavailable ||
+		status == http.StatusGatewayTimeout
+}
+
+// Delay before the given retry, with a random part so that
+// the clients do not send their requests again at the same time
+func retryDelay(retry int) time.Duration {
+	delay := retryConfig.BaseDelay << retry
+	if delay > retryConfig.MaxDelay || delay < retryConfig.BaseDelay {
+		//the shift may overflow
+		delay = retryConfig.MaxDelay
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
 // Function helps with API Requests
+// The transport errors are retried for the idempotent requests only,
+// the transient statuses (502, 503, 504) for all the requests
 func Send(method, URL, key string, data map[string]interface{}) (*http.Response,
 	error) {
-	//Loop because sometimes a
-	//Stream Error occurs
-	//thus give max 400 attempts before returning error
-	sender := func(method, URL, key string, data map[string]interface{}) (*http.Response, error) {
-		client := &http.Client{}
-		dataJSON, _ := json.Marshal(data)
-
-		req, _ := http.NewRequest(method, URL, bytes.NewBuffer(dataJSON))
-		req.Header.Set("Authorization", "Bearer "+key)
-		return client.Do(req)
-	}
+	return SendContext(requestContext, method, URL, key, data)
+}
 
-	for i := 0; ; i++ {
-		r, e := sender(method, URL, key, data)
-		if e == nil {
-			return r, e
+// Same as Send, the request is canceled with ctx instead of the command
+func SendContext(ctx context.Context, method, URL, key string,
+	data map[string]interface{}) (*http.Response, error) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	for retry := 0; ; retry++ {
+		req, err := http.NewRequestWithContext(ctx, method, URL, bytes.NewReader(dataJSON))
+		if err != nil {
+			return nil, err
 		}
-
-		if i == 400 {
-			return r, e
+		req.Header.Set("Authorization", "Bearer "+key)
+		r, err := client.Do(req)
+		retryable := false
+		if err != nil {
+			retryable = isIdempotent(method)
+		} else {
+			retryable = isTransientStatus(r.StatusCode)
+		}
+		if !retryable || retry >= retryConfig.MaxRetries || ctx.Err() != nil {
+			return r, err
+		}
+		if r != nil {
+			r.Body.Close()
+		}
+		select {
+		case <-time.After(retryDelay(retry)):
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s %s : %w", method, URL, ctx.Err())
 		}
 	}
 }
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSendRetries(t *testing.T) {
+	SetRetryConfig(RetryConfig{MaxRetries: 2, BaseDelay: time.Millisecond,
+		MaxDelay: time.Millisecond, Timeout: time.Second})
+	defer SetRetryConfig(DefaultRetryConfig)
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	r, err := Send("POST", server.URL, "key", map[string]interface{}{})
+	if err != nil || r.StatusCode != http.StatusOK || attempts != 3 {
+		t.Errorf("the transient statuses should be retried, got %v after %d attempts", err, attempts)
+	}
+
+	//Nothing listens on this address anymore
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	start := time.Now()
+	if _, err := Send("POST", closed.URL, "key", nil); err == nil {
+		t.Errorf("sending to a closed server should fail")
+	}
+	if _, err := Send("GET", closed.URL, "key", nil); err == nil {
+		t.Errorf("sending to a closed server should fail")
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("the retries should be bounded")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	SetContext(ctx)
+	defer SetContext(context.Background())
+	if _, err := Send("GET", server.URL, "key", nil); err == nil {
+		t.Errorf("a cancelled request should fail")
+	}
+}
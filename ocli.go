@@ -4,11 +4,11 @@ package main
 
 import (
 	"bufio"
-	c "cli/controllers"
-	l "cli/logger"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -26,14 +26,25 @@ func (e *fileParseError) Error() string {
 	return msg
 }
 
+func lineRangeString(lineNumber int, endLineNumber int) string {
+	if endLineNumber > lineNumber {
+		return fmt.Sprintf("%d-%d", lineNumber, endLineNumber)
+	}
+	return fmt.Sprintf("%d", lineNumber)
+}
+
 func addLineError(
 	fileErr *fileParseError,
 	lineErr error,
 	filename string,
 	lineNumber int,
+	endLineNumber int,
 	line string,
 ) *fileParseError {
-	msg := fmt.Sprintf("  LINE#: %d\tCOMMAND:%s", lineNumber, line)
+	msg := fmt.Sprintf("  LINE#: %s\tCOMMAND:%s", lineRangeString(lineNumber, endLineNumber), line)
+	if lineErr != nil {
+		msg += "\n" + lineErr.Error()
+	}
 	if fileErr == nil {
 		return &fileParseError{filename, []string{msg}}
 	}
@@ -42,30 +53,139 @@ func addLineError(
 }
 
 type parsedLine struct {
-	line       string
-	lineNumber int
-	root       node
+	line          string
+	lineNumber    int
+	endLineNumber int
+	root          node
+}
+
+// Computes the brace nesting depth at the end of a line,
+// ignoring braces inside quoted strings
+func braceDepth(line string, depth int) int {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case inString && line[i] == '\\':
+			i++
+		case line[i] == '"':
+			inString = !inString
+		case !inString && line[i] == '{':
+			depth++
+		case !inString && line[i] == '}':
+			depth--
+		}
+	}
+	return depth
+}
+
+// Keywords that can start a line to continue the statement
+// whose last block was closed on the previous line
+var continuationKeyWords = []string{"else", "elif", "catch", "finally"}
+
+// Checks if the next non empty line of the script starts with a
+// continuation keyword, such as else continuing the previous if statement
+func nextLineContinues(lines []string, from int) bool {
+	for i := from; i < len(lines); i++ {
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		for _, keyword := range continuationKeyWords {
+			if !strings.HasPrefix(line, keyword) {
+				continue
+			}
+			rest := line[len(keyword):]
+			if rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '{' || rest[0] == '$' {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// Groups the lines of a script into statements. A statement continues
+// on the next line while a { is left open, when the line ends with a \
+// or when the next line starts with a keyword such as else or catch
+func splitStatements(lines []string) []parsedLine {
+	statements := []parsedLine{}
+	for i := 0; i < len(lines); i++ {
+		start := i
+		buffer := ""
+		separator := ""
+		depth := 0
+		for ; i < len(lines); i++ {
+			line := stripComment(lines[i])
+			trimmed := strings.TrimRight(line, " \t")
+			continued := strings.HasSuffix(trimmed, "\\")
+			if continued {
+				line = trimmed[:len(trimmed)-1]
+			}
+			depth = braceDepth(line, depth)
+			buffer += separator + line
+			if continued {
+				separator = " "
+				continue
+			}
+			separator = "\n"
+			if depth > 0 {
+				continue
+			}
+			if strings.HasSuffix(strings.TrimSpace(line), "}") && nextLineContinues(lines, i+1) {
+				continue
+			}
+			break
+		}
+		if i == len(lines) {
+			i--
+		}
+		if strings.TrimSpace(buffer) != "" {
+			statements = append(statements, parsedLine{buffer, start + 1, i + 1, nil})
+		}
+	}
+	return statements
 }
 
 func parseFile(path string) ([]parsedLine, error) {
-	filename := filepath.Base(path)
 	file, openErr := os.Open(path)
 	if openErr != nil {
 		return nil, openErr
 	}
+	defer file.Close()
+	return parseStream(filepath.Base(path), file, nil)
+}
+
+// Parses all the statements read from r, filename is the name given
+// to the input in the errors. The statements are wrapped for the
+// debugger when instrument is not nil
+func parseStream(filename string, r io.Reader, instrument *debugParse) ([]parsedLine, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if scanErr := scanner.Err(); scanErr != nil {
+		return nil, scanErr
+	}
 	result := []parsedLine{}
 	var fileErr *fileParseError
-	scanner := bufio.NewScanner(file)
-	for lineNumber := 1; scanner.Scan(); lineNumber++ {
-		line := scanner.Text()
-		if len(line) > 0 {
-			root, err := Parse(line)
-			if err != nil {
-				fileErr = addLineError(fileErr, err, filename, lineNumber, line)
-			}
-			if root != nil {
-				result = append(result, parsedLine{line, lineNumber, root})
-			}
+	for _, statement := range splitStatements(lines) {
+		if braceDepth(statement.line, 0) > 0 {
+			fileErr = addLineError(fileErr, fmt.Errorf("{ opened but never closed"), filename,
+				statement.lineNumber, statement.endLineNumber, statement.line)
+			continue
+		}
+		if instrument != nil {
+			instrument.baseLine = statement.lineNumber
+		}
+		root, err := parse(statement.line, nil, instrument)
+		if err != nil {
+			fileErr = addLineError(fileErr, err, filename,
+				statement.lineNumber, statement.endLineNumber, statement.line)
+		}
+		if root != nil {
+			statement.root = root
+			result = append(result, statement)
 		}
 	}
 	if fileErr != nil {
@@ -79,49 +199,96 @@ type stackTraceError struct {
 	history string
 }
 
-func newStackTraceError(err error, filename string, line string, lineNumber int) *stackTraceError {
+func newStackTraceError(err error, filename string, line parsedLine) *stackTraceError {
 	stackErr := &stackTraceError{err: err}
-	stackErr.extend(filename, line, lineNumber)
+	stackErr.extend(filename, line)
 	return stackErr
 }
 
-func (s *stackTraceError) extend(filename string, line string, lineNumber int) {
-	trace := fmt.Sprintf("  File \"%s\", line %d\n", filename, lineNumber)
-	trace += "    " + line + "\n"
+func (s *stackTraceError) extend(filename string, line parsedLine) {
+	trace := fmt.Sprintf("  File \"%s\", line %s\n", filename,
+		lineRangeString(line.lineNumber, line.endLineNumber))
+	trace += "    " + strings.ReplaceAll(line.line, "\n", "\n    ") + "\n"
 	s.history = trace + s.history
 }
 
+func (s *stackTraceError) Unwrap() error {
+	return s.err
+}
+
 func (s *stackTraceError) Error() string {
 	msg := "Stack trace (most recent call last):\n"
 	return msg + s.history + "Error : " + s.err.Error()
 }
 
 func LoadFile(path string) error {
-	filename := filepath.Base(path)
 	file, err := parseFile(path)
 	if err != nil {
 		return err
 	}
+	return executeStatements(filepath.Base(path), file, true)
+}
+
+// Executes parsed statements until the first error,
+// echo prints each statement before executing it
+func executeStatements(filename string, file []parsedLine, echo bool) error {
 	for i := range file {
-		fmt.Println(file[i].line)
+		if echo {
+			fmt.Println(file[i].line)
+		}
 		_, err := file[i].root.execute()
+		if _, ok := err.(*exitError); ok {
+			return err
+		}
 		if err != nil {
-			errMsg := err.Error()
-			if strings.Contains(errMsg, "Duplicate") || strings.Contains(errMsg, "duplicate") {
-				l.GetWarningLogger().Println(errMsg)
-				if c.State.DebugLvl > c.NONE {
-					fmt.Println(errMsg)
-				}
-				continue
-			}
 			stackTraceErr, ok := err.(*stackTraceError)
 			if ok {
-				stackTraceErr.extend(filename, file[i].line, file[i].lineNumber)
+				stackTraceErr.extend(filename, file[i])
 			} else {
-				stackTraceErr = newStackTraceError(err, filename, file[i].line, file[i].lineNumber)
+				stackTraceErr = newStackTraceError(err, filename, file[i])
 			}
 			return stackTraceErr
 		}
 	}
 	return nil
 }
+
+// Sets the arguments given to a script on the command line,
+// as $argv and $argc, and as $1, $2... for each argument
+func setScriptArgs(args []string) {
+	setVar("argv", args)
+	setVar("argc", len(args))
+	for i, arg := range args {
+		setVar(strconv.Itoa(i+1), arg)
+	}
+}
+
+// Executes a script given on the command line and returns
+// the exit status of the program, errors go to stderr
+func RunScript(path string, args []string) int {
+	setScriptArgs(args)
+	return exitStatus(LoadFile(path))
+}
+
+// Executes the commands read from a pipe or a redirection,
+// they are neither echoed nor colored
+func RunStream(r io.Reader, args []string) int {
+	setScriptArgs(args)
+	file, err := parseStream("<stdin>", r, nil)
+	if err == nil {
+		err = executeStatements("<stdin>", file, false)
+	}
+	return exitStatus(err)
+}
+
+// Prints the error of a script and returns its exit status
+func exitStatus(err error) int {
+	if exitErr, ok := err.(*exitError); ok {
+		return exitErr.code
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	lines := []string{
+		".var:i=0",
+		"",
+		"while $i<6 {",
+		"  if $i == 2 { // comment with a {",
+		"    print \"}\"",
+		"  }",
+		"  else {",
+		"    pwd",
+		"  }",
+		"}",
+		"print \"a\" + \\",
+		"  \"b\"",
+	}
+	statements := splitStatements(lines)
+	ranges := [][2]int{}
+	for _, statement := range statements {
+		ranges = append(ranges, [2]int{statement.lineNumber, statement.endLineNumber})
+	}
+	expected := [][2]int{{1, 1}, {3, 10}, {11, 12}}
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("wrong statement line ranges : %v", ranges)
+	}
+	if statements[2].line != "print \"a\" +    \"b\"" {
+		t.Errorf("wrong line continuation : %q", statements[2].line)
+	}
+	for _, statement := range statements {
+		if _, err := Parse(statement.line); err != nil {
+			t.Errorf("cannot parse statement : %s", err.Error())
+		}
+	}
+}
+
+func TestSplitStatementsUnclosed(t *testing.T) {
+	statements := splitStatements([]string{"while true {", "  pwd"})
+	if len(statements) != 1 || statements[0].endLineNumber != 2 {
+		t.Errorf("unclosed block should span until the end of the file")
+	}
+	if braceDepth(statements[0].line, 0) != 1 {
+		t.Errorf("unclosed block should be detected")
+	}
+}
+
+func runScript(script string, args []string, t *testing.T) int {
+	path := filepath.Join(t.TempDir(), "script.ocli")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatalf("cannot write script : %s", err.Error())
+	}
+	return RunScript(path, args)
+}
+
+func TestRunScriptArgs(t *testing.T) {
+	script := "if $argc != 2 {\n  exit 2\n}\n" +
+		".var:first=$argv[0]\n" +
+		".var:second=$2\n"
+	if code := runScript(script, []string{"SITE1", "42"}, t); code != 0 {
+		t.Errorf("unexpected exit status %d", code)
+	}
+	if dynamicSymbolTable["first"] != "SITE1" || dynamicSymbolTable["second"] != "42" {
+		t.Errorf("wrong script arguments : %v %v", dynamicSymbolTable["first"], dynamicSymbolTable["second"])
+	}
+	if code := runScript(script, []string{"SITE1"}, t); code != 2 {
+		t.Errorf("exit status 2 expected, got %d", code)
+	}
+}
+
+func TestRunScriptExitStatus(t *testing.T) {
+	if code := runScript("try {\n  exit 3\n} catch {\n  exit 4\n}\n", nil, t); code != 3 {
+		t.Errorf("exit should not be caught, got status %d", code)
+	}
+	if code := runScript("print $undefinedVariable\nexit 5\n", nil, t); code != 1 {
+		t.Errorf("exit status 1 expected for a failing script, got %d", code)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	input := ".var:total=0\nfor i in 1..3 {\n  .var:total=$total+$i\n}\n.var:arg=$1\n"
+	if code := RunStream(strings.NewReader(input), []string{"x"}); code != 0 {
+		t.Errorf("unexpected exit status %d", code)
+	}
+	if dynamicSymbolTable["total"] != 6 || dynamicSymbolTable["arg"] != "x" {
+		t.Errorf("wrong values computed from the stream : %v %v", dynamicSymbolTable["total"], dynamicSymbolTable["arg"])
+	}
+	if code := RunStream(strings.NewReader("exit 7\n"), nil); code != 7 {
+		t.Errorf("exit status 7 expected, got %d", code)
+	}
+	if code := RunStream(strings.NewReader("while true {\n"), nil); code != 1 {
+		t.Errorf("exit status 1 expected for a syntax error, got %d", code)
+	}
+}
+
+func debugScript(commands string, t *testing.T) error {
+	script := ".var:dbgA=1\n" +
+		"alias dbgDouble(x) {\n" +
+		"  .var:y=$x*2\n" +
+		"  return $y\n" +
+		"}\n" +
+		".var:dbgB=dbgDouble($dbgA)\n" +
+		".var:dbgC=3\n"
+	path := filepath.Join(t.TempDir(), "script.ocli")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatalf("cannot write script : %s", err.Error())
+	}
+	delete(dynamicSymbolTable, "dbgB")
+	delete(dynamicSymbolTable, "dbgC")
+	return debugFile(path, strings.NewReader(commands))
+}
+
+func TestDebugger(t *testing.T) {
+	if err := debugScript("n\nn\nn\nq\n", t); err == nil {
+		t.Errorf("quitting the debugger should stop the script")
+	}
+	if dynamicSymbolTable["dbgB"] != 2 || dynamicSymbolTable["dbgC"] != nil {
+		t.Errorf("next should step over the function call : %v %v", dynamicSymbolTable["dbgB"], dynamicSymbolTable["dbgC"])
+	}
+	debugScript("s\ns\ns\nq\n", t)
+	if _, ok := dynamicSymbolTable["dbgB"]; ok {
+		t.Errorf("step should stop inside the function call")
+	}
+	debugScript("b 4\nb 5\nc\nq\n", t)
+	if _, ok := dynamicSymbolTable["dbgB"]; ok {
+		t.Errorf("the script should stop at the breakpoint in the function")
+	}
+	if err := debugScript("\nc\n", t); err != nil {
+		t.Errorf("unexpected error : %s", err.Error())
+	}
+	if dynamicSymbolTable["dbgC"] != 3 {
+		t.Errorf("continue should run the script until its end")
+	}
+}
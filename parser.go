@@ -1,6 +1,7 @@
 package main
 
 import (
+	cmd "cli/controllers"
 	"regexp"
 	"strconv"
 	"strings"
@@ -18,15 +19,15 @@ var noArgsCommands map[string]node
 var manCommands = []string{
 	"get", "getu", "getslot",
 	"+", "-", "=", ">",
-	".cmds", ".template", ".var",
+	".cmds", ".template", ".var", "debug", "save-session", "load-session",
 	"ui", "camera",
 	"link", "unlink",
 	"lsten", "lssite", "lsbldg", "lsroom", "lsrack", "lsdev", "lsac",
 	"lspanel", "lscabinet", "lscorridor", "lssensor", "lsenterprise",
 	"drawable", "draw", "undraw",
 	"tree", "lsog", "env", "cd", "pwd", "clear", "grep", "ls", "exit", "len", "man", "hc",
-	"print", "unset", "selection",
-	"for", "while", "if",
+	"print", "unset", "selection", "cache",
+	"for", "while", "if", "alias", "global", "break", "continue", "return", "try",
 }
 
 func sliceContains(slice []string, s string) bool {
@@ -61,13 +62,20 @@ type ParserError struct {
 	messages []string
 }
 
+// Disabled when the output is not read in a terminal
+var coloredOutput = true
+
 func buildColoredFrame(frame Frame) string {
 	result := ""
 	result += frame.buf[0:frame.start]
-	result += "\033[31m"
+	if coloredOutput {
+		result += "\033[31m"
+	}
 	result += "|"
 	result += frame.buf[frame.start:frame.end]
-	result += "\033[0m"
+	if coloredOutput {
+		result += "\033[0m"
+	}
 	result += frame.buf[frame.end:]
 	return result
 }
@@ -100,20 +108,22 @@ func newParserError(frame Frame, message string) *ParserError {
 }
 
 type Frame struct {
-	buf   string
-	start int
-	end   int
+	buf        string
+	start      int
+	end        int
+	events     *parseEvents //where the statements parsed are recorded, if not nil
+	instrument *debugParse  //wraps the statements parsed for the debugger, if not nil
 }
 
 func newFrame(buffer string) Frame {
-	return Frame{buffer, 0, len(buffer)}
+	return Frame{buffer, 0, len(buffer), nil, nil}
 }
 
 func (frame Frame) new(start int, end int) Frame {
 	if start < frame.start || start > frame.end || end < frame.start || end > frame.end {
 		panic("the subframe is not included in the topframe")
 	}
-	return Frame{frame.buf, start, end}
+	return Frame{frame.buf, start, end, frame.events, frame.instrument}
 }
 
 func (frame Frame) until(end int) Frame {
@@ -139,7 +149,7 @@ func (frame Frame) forward(offset int) Frame {
 	if frame.start+offset > frame.end {
 		panic("cannot go forward")
 	}
-	return Frame{frame.buf, frame.start + offset, frame.end}
+	return Frame{frame.buf, frame.start + offset, frame.end, frame.events, frame.instrument}
 }
 
 func (frame Frame) first() byte {
@@ -154,7 +164,10 @@ func (frame Frame) String() string {
 }
 
 func lexerFromFrame(frame Frame) *lexer {
-	return newLexer(frame.buf, frame.start, frame.end)
+	l := newLexer(frame.buf, frame.start, frame.end)
+	l.events = frame.events
+	l.instrument = frame.instrument
+	return l
 }
 
 func skipWhiteSpaces(frame Frame) Frame {
@@ -188,7 +201,9 @@ func findClosing(frame Frame) int {
 	inString := false
 	for cursor := frame.start; cursor < frame.end; cursor++ {
 		if inString {
-			if frame.char(cursor) == '"' {
+			if frame.char(cursor) == '\\' {
+				cursor++
+			} else if frame.char(cursor) == '"' {
 				inString = false
 			}
 			continue
@@ -213,11 +228,29 @@ func frameEnd(endChars string, frame Frame) bool {
 }
 
 func commandEnd(frame Frame) bool {
-	return frameEnd(";})", frame)
+	return frameEnd(";})\n", frame)
 }
 
 func exprEnd(frame Frame) bool {
-	return frameEnd(";})@", frame)
+	return frameEnd(";})@\n", frame)
+}
+
+// Skips spaces, tabs and newlines, used where a statement
+// is allowed to continue on the next line
+func skipWhiteSpacesAndNewLines(frame Frame) Frame {
+	i := frame.start
+	for i < frame.end && strings.ContainsRune(" \t\n", rune(frame.char(i))) {
+		i += 1
+	}
+	return frame.from(i)
+}
+
+// Parses a command separator, either a semicolon or a newline
+func parseSeparator(frame Frame) (bool, Frame) {
+	if ok, frame := parseExact(";", frame); ok {
+		return true, frame
+	}
+	return parseExact("\n", frame)
 }
 
 func parseExact(word string, frame Frame) (bool, Frame) {
@@ -236,16 +269,23 @@ func isPrefix(prefix string, candidates []string) bool {
 	return false
 }
 
+// Parses the longest candidate found at the start of the frame. A keyword
+// ending with a letter or a digit must not be followed by one, so that
+// debugRacks or tryAll are names rather than debug or try
 func parseKeyWord(candidates []string, frame Frame) (string, Frame) {
 	commandEnd := frame.start
 	for commandEnd < frame.end && isPrefix(frame.until(commandEnd+1).str(), candidates) {
 		commandEnd++
 	}
 	longestPrefix := frame.until(commandEnd).str()
-	if sliceContains(candidates, longestPrefix) {
-		return longestPrefix, frame.from(commandEnd)
+	if !sliceContains(candidates, longestPrefix) {
+		return "", frame
+	}
+	if isAlphaNumeric(longestPrefix[len(longestPrefix)-1]) &&
+		commandEnd < frame.end && isAlphaNumeric(frame.char(commandEnd)) {
+		return "", frame
 	}
-	return "", frame
+	return longestPrefix, frame.from(commandEnd)
 }
 
 func parseWord(frame Frame) (string, Frame, *ParserError) {
@@ -361,18 +401,27 @@ func parseBool(frame Frame) (bool, Frame, *ParserError) {
 func parseRawText(lexFunc stateFn, frame Frame) (node, Frame, *ParserError) {
 	l := lexerFromFrame(frame)
 	s := ""
+	format := ""
 	vars := []symbolReferenceNode{}
 loop:
 	for {
 		tok := l.nextToken(lexFunc)
 		switch tok.t {
 		case tokText:
-			s += tok.str
+			text := tok.str
+			if val, ok := tok.val.(string); ok {
+				//escape sequence
+				text = val
+			}
+			s += text
+			format += strings.ReplaceAll(text, "%", "%%")
 		case tokDeref:
-			s += "%v"
+			format += "%v"
 			vars = append(vars, symbolReferenceNode{tok.val.(string)})
 		case tokEOF:
 			break loop
+		case tokError:
+			return nil, frame, newParserError(frame, tok.str)
 		default:
 			return nil, frame, newParserError(frame, "unexpected token")
 		}
@@ -381,7 +430,7 @@ loop:
 	if len(vars) == 0 {
 		return &strLeaf{s}, frame, nil
 	}
-	return &formatStringNode{s, vars}, frame, nil
+	return &formatStringNode{format, vars}, frame, nil
 }
 
 func parsePath(frame Frame) (node, Frame, *ParserError) {
@@ -435,32 +484,37 @@ func parsePrimaryExpr(l *lexer) (node, *ParserError) {
 	tok := l.tok
 	l.nextToken(lexExpr)
 	switch tok.t {
+	case tokWord:
+		if l.tok.t != tokLeftParen {
+			break
+		}
+		l.nextToken(lexExpr)
+		args, err := parseExprListFromLex(l, tokRightParen)
+		if err != nil {
+			return nil, err
+		}
+		return parseAccessFromLex(l, &funcCallNode{tok.str, args})
 	case tokBool:
 		return &boolLeaf{tok.val.(bool)}, nil
 	case tokInt:
 		return &intLeaf{tok.val.(int)}, nil
 	case tokFloat:
 		return &floatLeaf{tok.val.(float64)}, nil
+	case tokQuantity:
+		return &quantityLeaf{tok.val.(cmd.Quantity)}, nil
 	case tokString:
 		n, _, err := parseRawText(lexQuotedString, newFrame(tok.val.(string)))
 		if err != nil {
-			return nil, exprError(l, "cannot parse string")
+			return nil, exprError(l, "cannot parse string : "+err.messages[0])
 		}
 		return n, nil
 	case tokDeref:
-		if l.tok.t != tokLeftBrac {
-			return &symbolReferenceNode{tok.val.(string)}, nil
-		}
-		l.nextToken(lexExpr)
-		index, err := parseExprFromLex(l)
-		if err != nil {
-			return nil, err
-		}
-		if l.tok.t != tokRightBrac {
-			return nil, exprError(l, "square bracket opened but not closed")
+		variable := &symbolReferenceNode{tok.val.(string)}
+		if strings.HasPrefix(tok.str, "${") && l.tok.t == tokDot {
+			// in ${name}.ext, the dot is not an attribute access
+			return variable, nil
 		}
-		l.nextToken(lexExpr)
-		return &arrayReferenceNode{tok.val.(string), index}, nil
+		return parseAccessFromLex(l, variable)
 	case tokLeftParen:
 		expr, err := parseExprFromLex(l)
 		if err != nil {
@@ -473,29 +527,137 @@ func parsePrimaryExpr(l *lexer) (node, *ParserError) {
 		l.nextToken(lexExpr)
 		return expr, nil
 	case tokLeftBrac:
-		exprList := []node{}
-		if l.tok.t == tokRightBrac {
-			l.nextToken(lexExpr)
-			return &arrNode{exprList}, nil
+		exprList, err := parseExprListFromLex(l, tokRightBrac)
+		if err != nil {
+			return nil, err
 		}
-		for {
-			expr, err := parseExprFromLex(l)
+		return &arrNode{exprList}, nil
+	case tokCommand:
+		frame := Frame{buf: l.input, start: tok.start + 2, end: tok.end - 1,
+			events: l.events, instrument: l.instrument}
+		command, frame, err := parseCommand(frame)
+		if err != nil {
+			return nil, err.extendMessage("parsing command substitution")
+		}
+		if frame.start != frame.end {
+			return nil, newParserError(frame, "unexpected characters in command substitution")
+		}
+		return parseAccessFromLex(l, &commandSubstNode{command})
+	case tokLeftBrace:
+		return parseMapFromLex(l)
+	case tokError:
+		return nil, exprError(l, tok.str)
+	}
+	return nil, exprError(l, "unexpected token : "+tok.str)
+}
+
+// Parses the accesses following a value : $x[0], $x["key"], $x.key
+func parseAccessFromLex(l *lexer, value node) (node, *ParserError) {
+	for {
+		var index node
+		switch l.tok.t {
+		case tokLeftBrac:
+			l.nextToken(lexExpr)
+			var err *ParserError
+			index, err = parseExprFromLex(l)
 			if err != nil {
 				return nil, err
 			}
-			exprList = append(exprList, expr)
-			if l.tok.t == tokRightBrac {
-				l.nextToken(lexExpr)
-				return &arrNode{exprList}, nil
+			if l.tok.t != tokRightBrac {
+				return nil, exprError(l, "square bracket opened but not closed")
 			}
-			if l.tok.t == tokComma {
-				l.nextToken(lexExpr)
-				continue
+		case tokDot:
+			l.nextToken(lexExpr)
+			if l.tok.t != tokWord {
+				return nil, exprError(l, "attribute name expected after .")
 			}
-			return nil, exprError(l, "] or comma expected")
+			value = &fieldNode{value, l.tok.str, false}
+			l.nextToken(lexExpr)
+			continue
+		default:
+			return value, nil
+		}
+		l.nextToken(lexExpr)
+		if variable, ok := value.(*symbolReferenceNode); ok {
+			value = &arrayReferenceNode{variable.va, index}
+		} else {
+			value = &indexNode{value, index}
 		}
 	}
-	return nil, exprError(l, "unexpected token : "+tok.str)
+}
+
+// Parses a map literal : {"key": expr, ...}, the opening brace being consumed
+func parseMapFromLex(l *lexer) (node, *ParserError) {
+	keys := []string{}
+	values := []node{}
+	if l.tok.t == tokRightBrace {
+		l.nextToken(lexExpr)
+		return &mapNode{keys, values}, nil
+	}
+	for {
+		var key string
+		switch l.tok.t {
+		case tokWord:
+			key = l.tok.str
+		case tokString:
+			n, _, err := parseRawText(lexQuotedString, newFrame(l.tok.val.(string)))
+			if err != nil {
+				return nil, exprError(l, "cannot parse string : "+err.messages[0])
+			}
+			leaf, ok := n.(*strLeaf)
+			if !ok {
+				return nil, exprError(l, "map keys cannot contain variables")
+			}
+			key = leaf.val
+		default:
+			return nil, exprError(l, "map key expected")
+		}
+		l.nextToken(lexExpr)
+		if l.tok.t != tokColon {
+			return nil, exprError(l, ": expected after map key")
+		}
+		l.nextToken(lexExpr)
+		value, err := parseExprFromLex(l)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+		if l.tok.t == tokRightBrace {
+			l.nextToken(lexExpr)
+			return &mapNode{keys, values}, nil
+		}
+		if l.tok.t != tokComma {
+			return nil, exprError(l, "} or comma expected")
+		}
+		l.nextToken(lexExpr)
+	}
+}
+
+// Parses a comma separated list of expressions, until the closing token
+func parseExprListFromLex(l *lexer, closing tokenType) ([]node, *ParserError) {
+	exprList := []node{}
+	if l.tok.t == closing {
+		l.nextToken(lexExpr)
+		return exprList, nil
+	}
+	for {
+		expr, err := parseExprFromLex(l)
+		if err != nil {
+			return nil, err
+		}
+		exprList = append(exprList, expr)
+		if l.tok.t == closing {
+			l.nextToken(lexExpr)
+			return exprList, nil
+		}
+		if l.tok.t == tokComma {
+			l.nextToken(lexExpr)
+			continue
+		}
+		closingChar := map[tokenType]string{tokRightBrac: "]", tokRightParen: ")"}[closing]
+		return nil, exprError(l, closingChar+" or comma expected")
+	}
 }
 
 func parseUnaryExpr(l *lexer) (node, *ParserError) {
@@ -549,12 +711,35 @@ func parseBinaryExpr(l *lexer, leftOperand node, precedence int) (node, *ParserE
 			leftOperand = &equalityNode{operator.str, leftOperand, rightOperand}
 		case tokLeq, tokGeq, tokGtr, tokLss:
 			leftOperand = &comparatorNode{operator.str, leftOperand, rightOperand}
+		case tokMatch, tokNotMatch:
+			leftOperand = &matchNode{operator.str, leftOperand, rightOperand}
+		case tokWord:
+			leftOperand = &inNode{leftOperand, rightOperand}
 		}
 	}
 }
 
+// Parses an expression, with an optional conditional
+// expression around it : condition ? expr : expr
 func parseExprFromLex(l *lexer) (node, *ParserError) {
-	return parseBinaryExpr(l, nil, 1)
+	condition, err := parseBinaryExpr(l, nil, 1)
+	if err != nil || l.tok.t != tokQuestion {
+		return condition, err
+	}
+	l.nextToken(lexExpr)
+	ifTrue, err := parseExprFromLex(l)
+	if err != nil {
+		return nil, err
+	}
+	if l.tok.t != tokColon {
+		return nil, exprError(l, ": expected in conditional expression")
+	}
+	l.nextToken(lexExpr)
+	ifFalse, err := parseExprFromLex(l)
+	if err != nil {
+		return nil, err
+	}
+	return &conditionalNode{condition, ifTrue, ifFalse}, nil
 }
 
 func parseExpr(frame Frame) (node, Frame, *ParserError) {
@@ -622,6 +807,9 @@ func parseArgValue(frame Frame) (string, Frame, *ParserError) {
 		return frame.until(endQuote).str(), frame.from(endQuote + 1), nil
 	}
 	endValue := findNext(" ", frame)
+	if newLine := findNext("\n", frame); newLine < endValue {
+		endValue = newLine
+	}
 	return frame.until(endValue).str(), skipWhiteSpaces(frame.from(endValue)), nil
 }
 
@@ -688,7 +876,7 @@ func parseLsObj(lsIdx int, frame Frame) (node, Frame, *ParserError) {
 	var format string
 	if formatArg, ok := args["f"]; ok {
 		if regexMatch(`\(\s*".*"\s*,.+\)`, formatArg) {
-			formatFrame := Frame{formatArg, 1, len(formatArg)}
+			formatFrame := Frame{formatArg, 1, len(formatArg), nil, nil}
 			startFormat := findNextQuote(formatFrame)
 			endFormat := findNextQuote(formatFrame.from(startFormat + 1))
 			format = formatArg[startFormat+1 : endFormat]
@@ -862,6 +1050,11 @@ func parseEnv(frame Frame) (node, Frame, *ParserError) {
 }
 
 func parseDelete(frame Frame) (node, Frame, *ParserError) {
+	args, frame, err := parseArgs([]string{}, []string{"f"}, frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing deletion arguments")
+	}
+	_, force := args["f"]
 	deleteSelection, frame := parseExact("selection", frame)
 	if deleteSelection {
 		return &deleteSelectionNode{}, frame, nil
@@ -870,7 +1063,7 @@ func parseDelete(frame Frame) (node, Frame, *ParserError) {
 	if err != nil {
 		return nil, frame, err.extendMessage("parsing deletion path")
 	}
-	return &deleteObjNode{path}, frame, nil
+	return &deleteObjNode{path, force}, frame, nil
 }
 
 func parseEqual(frame Frame) (node, Frame, *ParserError) {
@@ -893,26 +1086,11 @@ func parseEqual(frame Frame) (node, Frame, *ParserError) {
 }
 
 func parseVar(frame Frame) (node, Frame, *ParserError) {
-	topFrame := frame
 	varName, frame, err := parseAssign(frame)
 	if err != nil {
 		return nil, frame, err.extendMessage("parsing variable assignment")
 	}
-	frame = skipWhiteSpaces(frame)
-	commandExpr, frame := parseExact("$(", frame)
-	if commandExpr {
-		value, frame, err := parseCommand(frame)
-		if err != nil {
-			return nil, frame, err.extendMessage("parsing variable value (command expression)")
-		}
-		closed, frame := parseExact(")", frame)
-		if !closed {
-			return nil, frame, newParserError(frame, "$( opened but never closed").
-				extend(topFrame, "parsing variable assignment")
-		}
-		return &assignNode{varName, value}, frame, nil
-	}
-	value, frame, err := parseStringExpr(frame)
+	value, frame, err := parseStringExpr(skipWhiteSpaces(frame))
 	if err != nil {
 		return nil, frame, err.extendMessage("parsing variable value")
 	}
@@ -927,6 +1105,30 @@ func parseLoad(frame Frame) (node, Frame, *ParserError) {
 	return &loadNode{filePath}, frame, nil
 }
 
+func parseDebug(frame Frame) (node, Frame, *ParserError) {
+	filePath, frame, err := parseStringExpr(skipWhiteSpaces(frame))
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing file path")
+	}
+	return &debugScriptNode{filePath}, frame, nil
+}
+
+func parseSaveSession(frame Frame) (node, Frame, *ParserError) {
+	filePath, frame, err := parseStringExpr(frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing file path")
+	}
+	return &saveSessionNode{filePath}, frame, nil
+}
+
+func parseLoadSession(frame Frame) (node, Frame, *ParserError) {
+	filePath, frame, err := parseStringExpr(frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing file path")
+	}
+	return &loadSessionNode{filePath}, frame, nil
+}
+
 func parseTemplate(frame Frame) (node, Frame, *ParserError) {
 	filePath, frame, err := parseStringExpr(frame)
 	if err != nil {
@@ -1064,6 +1266,17 @@ func parseUi(frame Frame) (node, Frame, *ParserError) {
 	return nil, frame, newParserError(frame, "unknown ui command")
 }
 
+func parseCache(frame Frame) (node, Frame, *ParserError) {
+	action, frame := parseKeyWord([]string{"clear", "stats"}, frame)
+	switch action {
+	case "clear":
+		return &cacheClearNode{}, frame, nil
+	case "stats":
+		return &cacheStatsNode{}, frame, nil
+	}
+	return nil, frame, newParserError(frame, "unknown cache command")
+}
+
 func parseCamera(frame Frame) (node, Frame, *ParserError) {
 	key, frame, err := parseAssign(frame)
 	if err != nil {
@@ -1140,7 +1353,7 @@ func parseFor(frame Frame) (node, Frame, *ParserError) {
 	}
 	ok, frame = parseExact("..", frame)
 	if !ok {
-		return nil, frame, newParserError(frame, ".. expected")
+		return parseForArray(varName, start, frame)
 	}
 	end, frame, err := parseExpr(frame)
 	if err != nil {
@@ -1161,6 +1374,23 @@ func parseFor(frame Frame) (node, Frame, *ParserError) {
 	return &forRangeNode{varName, start, end, body}, frame, nil
 }
 
+// Parses the end of a loop over the elements of an array : for x in [expr] {...}
+func parseForArray(varName string, arr node, frame Frame) (node, Frame, *ParserError) {
+	ok, frame := parseExact("{", frame)
+	if !ok {
+		return nil, frame, newParserError(frame, ".. or { expected")
+	}
+	body, frame, err := parseCommand(frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing for loop body")
+	}
+	ok, frame = parseExact("}", skipWhiteSpaces(frame))
+	if !ok {
+		return nil, frame, newParserError(frame, "} expected")
+	}
+	return &forArrayNode{varName, arr, body}, frame, nil
+}
+
 func parseIf(frame Frame) (node, Frame, *ParserError) {
 	condition, frame, err := parseExpr(frame)
 	if err != nil {
@@ -1178,7 +1408,7 @@ func parseIf(frame Frame) (node, Frame, *ParserError) {
 	if !ok {
 		return nil, frame, newParserError(frame, "} expected")
 	}
-	keyword, frame := parseKeyWord([]string{"else", "elif"}, skipWhiteSpaces(frame))
+	keyword, frame := parseBlockKeyWord([]string{"else", "elif"}, frame)
 	switch keyword {
 	case "":
 		return &ifNode{condition, body, nil}, frame, nil
@@ -1207,11 +1437,130 @@ func parseIf(frame Frame) (node, Frame, *ParserError) {
 	}
 }
 
+// Parses the optional parameter list of a function definition : (a, b, c)
+func parseFuncParams(frame Frame) ([]string, Frame, *ParserError) {
+	frame = skipWhiteSpaces(frame)
+	if ok, _ := parseExact("(", frame); !ok {
+		return []string{}, frame, nil
+	}
+	close := findClosing(frame)
+	frame = frame.forward(1)
+	if close == frame.end {
+		return nil, frame, newParserError(frame, "( opened but never closed")
+	}
+	paramsFrame := skipWhiteSpaces(frame.until(close))
+	if paramsFrame.start == paramsFrame.end {
+		return []string{}, frame.from(close + 1), nil
+	}
+	params, err := parseSeparatedWords(',', paramsFrame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing function parameters")
+	}
+	return params, frame.from(close + 1), nil
+}
+
+// Parses the optional argument list of a function call : (expr1, expr2)
+func parseFuncArgs(frame Frame) ([]node, Frame, *ParserError) {
+	ok, frame := parseExact("(", skipWhiteSpaces(frame))
+	if !ok {
+		return []node{}, frame, nil
+	}
+	args := []node{}
+	frame = skipWhiteSpaces(frame)
+	if ok, nextFrame := parseExact(")", frame); ok {
+		return args, nextFrame, nil
+	}
+	for {
+		arg, nextFrame, err := parseExpr(frame)
+		if err != nil {
+			return nil, frame, err.extendMessage("parsing function argument")
+		}
+		args = append(args, arg)
+		frame = skipWhiteSpaces(nextFrame)
+		if ok, nextFrame := parseExact(")", frame); ok {
+			return args, nextFrame, nil
+		}
+		ok, frame = parseExact(",", frame)
+		if !ok {
+			return nil, frame, newParserError(frame, ", or ) expected")
+		}
+	}
+}
+
+// Looks for a keyword continuing a statement after the closing brace of
+// a block (else, catch...), either on the same line or on the next one
+func parseBlockKeyWord(keywords []string, frame Frame) (string, Frame) {
+	keyword, nextFrame := parseKeyWord(keywords, skipWhiteSpacesAndNewLines(frame))
+	if keyword == "" {
+		return "", frame
+	}
+	next := nextFrame.first()
+	if next != ' ' && next != '\t' && next != '{' && next != '$' {
+		return "", frame
+	}
+	return keyword, nextFrame
+}
+
+// Parses a block of commands surrounded by braces
+func parseBlock(frame Frame) (node, Frame, *ParserError) {
+	ok, frame := parseExact("{", skipWhiteSpaces(frame))
+	if !ok {
+		return nil, frame, newParserError(frame, "{ expected")
+	}
+	body, frame, err := parseCommand(frame)
+	if err != nil {
+		return nil, frame, err
+	}
+	ok, frame = parseExact("}", skipWhiteSpaces(frame))
+	if !ok {
+		return nil, frame, newParserError(frame, "} expected")
+	}
+	return body, frame, nil
+}
+
+func parseTry(frame Frame) (node, Frame, *ParserError) {
+	tryBody, frame, err := parseBlock(frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing try body")
+	}
+	n := &tryNode{tryBody: tryBody}
+	keyword, nextFrame := parseBlockKeyWord([]string{"catch"}, frame)
+	if keyword != "" {
+		frame = skipWhiteSpaces(nextFrame)
+		if ok, _ := parseExact("{", frame); !ok {
+			_, frame = parseExact("$", frame)
+			n.errVar, frame, err = parseWord(frame)
+			if err != nil {
+				return nil, frame, err.extendMessage("parsing catch error variable")
+			}
+		}
+		n.catchBody, frame, err = parseBlock(frame)
+		if err != nil {
+			return nil, frame, err.extendMessage("parsing catch body")
+		}
+	}
+	keyword, nextFrame = parseBlockKeyWord([]string{"finally"}, frame)
+	if keyword != "" {
+		n.finallyBody, frame, err = parseBlock(nextFrame)
+		if err != nil {
+			return nil, frame, err.extendMessage("parsing finally body")
+		}
+	}
+	if n.catchBody == nil && n.finallyBody == nil {
+		return nil, frame, newParserError(frame, "catch or finally expected")
+	}
+	return n, frame, nil
+}
+
 func parseAlias(frame Frame) (node, Frame, *ParserError) {
 	name, frame, err := parseWord(frame)
 	if err != nil {
 		return nil, frame, err
 	}
+	params, frame, err := parseFuncParams(frame)
+	if err != nil {
+		return nil, frame, err
+	}
 	frame = skipWhiteSpaces(frame)
 	ok, frame := parseExact("{", frame)
 	if !ok {
@@ -1227,7 +1576,7 @@ func parseAlias(frame Frame) (node, Frame, *ParserError) {
 	if !ok {
 		return nil, frame, newParserError(frame, "} expected")
 	}
-	return &funcDefNode{name, command}, frame, nil
+	return &funcDefNode{name, params, command}, frame, nil
 }
 
 func parseCallAlias(frame Frame) (node, Frame, *ParserError) {
@@ -1235,7 +1584,45 @@ func parseCallAlias(frame Frame) (node, Frame, *ParserError) {
 	if err != nil {
 		return nil, frame, err.extendMessage("parsing alias call")
 	}
-	return &funcCallNode{name}, frame, nil
+	args, frame, err := parseFuncArgs(frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing alias call")
+	}
+	return &funcCallNode{name, args}, frame, nil
+}
+
+func parseReturn(frame Frame) (node, Frame, *ParserError) {
+	if commandEnd(frame) {
+		return &returnNode{nil}, frame, nil
+	}
+	expr, frame, err := parseExpr(frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing return value")
+	}
+	return &returnNode{expr}, frame, nil
+}
+
+func parseExit(frame Frame) (node, Frame, *ParserError) {
+	if commandEnd(frame) {
+		return &exitNode{nil}, frame, nil
+	}
+	code, frame, err := parseExpr(frame)
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing exit code")
+	}
+	return &exitNode{code}, frame, nil
+}
+
+func parseGlobal(frame Frame) (node, Frame, *ParserError) {
+	end := frame.start
+	for end < frame.end && !commandEnd(frame.from(end)) {
+		end++
+	}
+	names, err := parseSeparatedWords(',', frame.until(end))
+	if err != nil {
+		return nil, frame, err.extendMessage("parsing global variable names")
+	}
+	return &globalNode{names}, frame.from(end), nil
 }
 
 func parseObjType(frame Frame) (string, Frame) {
@@ -1247,6 +1634,17 @@ func parseObjType(frame Frame) (string, Frame) {
 }
 
 func parseCreate(frame Frame) (node, Frame, *ParserError) {
+	create, frame, err := parseCreateObject(frame)
+	if err != nil {
+		return nil, frame, err
+	}
+	if path, _ := createCommandPath(create); hasBraces(path) {
+		return &createManyNode{create}, frame, nil
+	}
+	return create, frame, nil
+}
+
+func parseCreateObject(frame Frame) (node, Frame, *ParserError) {
 	objType, frame := parseObjType(frame)
 	if objType == "" {
 		return nil, frame, newParserError(frame, "parsing object type")
@@ -1263,6 +1661,21 @@ func parseCreate(frame Frame) (node, Frame, *ParserError) {
 	return createObjDispatch[objType](frame)
 }
 
+// Tells whether a path contains braces to expand
+func hasBraces(path node) bool {
+	p, ok := path.(*pathNode)
+	if !ok {
+		return false
+	}
+	switch text := p.path.(type) {
+	case *strLeaf:
+		return strings.Contains(text.val, "{")
+	case *formatStringNode:
+		return strings.Contains(text.str, "{")
+	}
+	return false
+}
+
 func parseColor(frame Frame) (node, Frame, *ParserError) {
 	l := lexerFromFrame(frame)
 	tok := l.nextToken(lexColor)
@@ -1309,10 +1722,14 @@ func parseTemperature(frame Frame) (node, Frame, *ParserError) {
 }
 
 func parseStringExpr(frame Frame) (node, Frame, *ParserError) {
+	eventCount := frame.events.count()
 	expr, nextFrame, err := parseExpr(frame)
 	if err == nil && exprEnd(nextFrame) {
+		fieldsOrText(expr)
 		return expr, nextFrame, nil
 	}
+	//the commands parsed in the expression are dropped
+	frame.events.truncate(eventCount)
 	frame = skipWhiteSpaces(frame)
 	str, frame, err := parseRawText(lexUnquotedString, frame)
 	if err != nil {
@@ -1321,6 +1738,25 @@ func parseStringExpr(frame Frame) (node, Frame, *ParserError) {
 	return str, skipWhiteSpaces(frame), nil
 }
 
+// $name.ext in an unquoted string is the value of name followed
+// by .ext when name is not a map, as in the quoted strings
+func fieldsOrText(expr node) {
+	fields := []*fieldNode{}
+	for {
+		field, ok := expr.(*fieldNode)
+		if !ok {
+			break
+		}
+		fields = append(fields, field)
+		expr = field.container
+	}
+	if _, ok := expr.(*symbolReferenceNode); ok {
+		for _, field := range fields {
+			field.orText = true
+		}
+	}
+}
+
 type objParam struct {
 	name string
 	t    string
@@ -1621,36 +2057,44 @@ func parseSingleCommand(frame Frame) (node, Frame, *ParserError) {
 func parseCommand(frame Frame) (node, Frame, *ParserError) {
 	if commandDispatch == nil {
 		commandDispatch = map[string]parseCommandFunc{
-			"ls":         parseLs,
-			"get":        parseGet,
-			"getu":       parseGetU,
-			"getslot":    parseGetSlot,
-			"undraw":     parseUndraw,
-			"draw":       parseDraw,
-			"drawable":   parseDrawable,
-			"hc":         parseHc,
-			"unset":      parseUnset,
-			"env":        parseEnv,
-			"+":          parseCreate,
-			"-":          parseDelete,
-			"=":          parseEqual,
-			".var:":      parseVar,
-			".cmds:":     parseLoad,
-			".template:": parseTemplate,
-			"len":        parseLen,
-			"link:":      parseLink,
-			"unlink":     parseUnlink,
-			"print":      parsePrint,
-			"man":        parseMan,
-			"cd":         parseCd,
-			"tree":       parseTree,
-			"ui.":        parseUi,
-			"camera.":    parseCamera,
-			">":          parseFocus,
-			"while":      parseWhile,
-			"for":        parseFor,
-			"if":         parseIf,
-			"alias":      parseAlias,
+			"ls":           parseLs,
+			"get":          parseGet,
+			"getu":         parseGetU,
+			"getslot":      parseGetSlot,
+			"undraw":       parseUndraw,
+			"draw":         parseDraw,
+			"drawable":     parseDrawable,
+			"hc":           parseHc,
+			"unset":        parseUnset,
+			"env":          parseEnv,
+			"+":            parseCreate,
+			"-":            parseDelete,
+			"=":            parseEqual,
+			".var:":        parseVar,
+			".cmds:":       parseLoad,
+			"debug":        parseDebug,
+			"save-session": parseSaveSession,
+			"load-session": parseLoadSession,
+			".template:":   parseTemplate,
+			"len":          parseLen,
+			"link:":        parseLink,
+			"unlink":       parseUnlink,
+			"print":        parsePrint,
+			"man":          parseMan,
+			"cd":           parseCd,
+			"tree":         parseTree,
+			"ui.":          parseUi,
+			"camera.":      parseCamera,
+			"cache":        parseCache,
+			">":            parseFocus,
+			"while":        parseWhile,
+			"for":          parseFor,
+			"if":           parseIf,
+			"alias":        parseAlias,
+			"global":       parseGlobal,
+			"return":       parseReturn,
+			"try":          parseTry,
+			"exit":         parseExit,
 		}
 		createObjDispatch = map[string]parseCommandFunc{
 			"tenant":   parseCreateTenant,
@@ -1679,7 +2123,8 @@ func parseCommand(frame Frame) (node, Frame, *ParserError) {
 			"lsog":         &lsogNode{},
 			"lsenterprise": &lsenterpriseNode{},
 			"pwd":          &pwdNode{},
-			"exit":         &exitNode{},
+			"break":        &breakNode{},
+			"continue":     &continueNode{},
 		}
 	}
 	commands := []node{}
@@ -1687,29 +2132,102 @@ func parseCommand(frame Frame) (node, Frame, *ParserError) {
 	var err *ParserError
 	var ok bool
 	for {
+		offset := skipWhiteSpaces(frame).start
+		eventCount := frame.events.count()
+		frame.events.record(false, offset)
 		command, frame, err = parseSingleCommand(frame)
 		if err != nil {
 			return nil, frame, err.extend(frame, "parsing command")
 		}
-		commands = append(commands, command)
+		if command == nil {
+			//empty statements, between separators or lines, are left out
+			frame.events.truncate(eventCount)
+		} else {
+			if frame.instrument != nil {
+				command = frame.instrument.wrap(command, frame.buf, offset)
+			}
+			commands = append(commands, command)
+		}
 		frame = skipWhiteSpaces(frame)
-		ok, frame = parseExact(";", frame)
+		ok, frame = parseSeparator(frame)
 		if !ok {
-			if len(commands) > 1 {
-				return &ast{commands}, frame, nil
+			frame.events.record(true, frame.start)
+			switch len(commands) {
+			case 0:
+				return nil, frame, nil
+			case 1:
+				return commands[0], frame, nil
 			}
-			return command, frame, nil
+			return &ast{commands}, frame, nil
 		}
 		frame = skipWhiteSpaces(frame)
 	}
 }
 
+type parseEvent struct {
+	blockEnd bool
+	offset   int
+}
+
+// parseCommand records in the events of its frame the offset of each
+// statement it parses and of the end of each block, in source order.
+// They are used by the formatter to find back the line of a statement.
+// Nothing is recorded when the events are nil
+type parseEvents struct {
+	list []parseEvent
+}
+
+func (e *parseEvents) record(blockEnd bool, offset int) {
+	if e != nil {
+		e.list = append(e.list, parseEvent{blockEnd, offset})
+	}
+}
+
+func (e *parseEvents) count() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.list)
+}
+
+func (e *parseEvents) truncate(count int) {
+	if e != nil {
+		e.list = e.list[:count]
+	}
+}
+
+// Removes a comment starting with // from a line,
+// unless the slashes are inside a quoted string
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case inString && line[i] == '\\':
+			i++
+		case line[i] == '"':
+			inString = !inString
+		case !inString && strings.HasPrefix(line[i:], "//"):
+			return line[:i]
+		}
+	}
+	return line
+}
+
 func Parse(buffer string) (node, error) {
-	commentIdx := strings.Index(buffer, "//")
-	if commentIdx != -1 {
-		buffer = buffer[:commentIdx]
+	return parse(buffer, nil, nil)
+}
+
+// Parses the buffer, records its statements in events
+// and wraps them with the instrument of the debugger
+func parse(buffer string, events *parseEvents, instrument *debugParse) (node, error) {
+	lines := strings.Split(buffer, "\n")
+	for i := range lines {
+		lines[i] = stripComment(lines[i])
 	}
+	buffer = strings.Join(lines, "\n")
 	frame := newFrame(buffer)
+	frame.events = events
+	frame.instrument = instrument
 	node, frame, err := parseCommand(frame)
 	if err != nil {
 		return nil, err
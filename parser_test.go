@@ -1,6 +1,7 @@
 package main
 
 import (
+	cmd "cli/controllers"
 	"reflect"
 	"testing"
 
@@ -286,10 +287,13 @@ var commandsMatching = map[string]node{
 	"man draw":                       &helpNode{"draw"},
 	"man camera":                     &helpNode{"camera"},
 	"man ui":                         &helpNode{"ui"},
+	"cache clear":                    &cacheClearNode{},
+	"cache stats":                    &cacheStatsNode{},
 	"ls":                             &lsNode{&pathNode{&strLeaf{""}}},
 	"cd":                             &cdNode{&pathNode{&strLeaf{"/"}}},
 	"tree":                           &treeNode{&pathNode{&strLeaf{"."}}, 0},
 	"get ${toto}/tata":               &getObjectNode{testPath},
+	"get /P/SI/*/R?":                 &getObjectNode{&pathNode{&strLeaf{"/P/SI/*/R?"}}},
 	"getu rackA 42":                  &getUNode{&pathNode{&strLeaf{"rackA"}}, &intLeaf{42}},
 	"undraw":                         &undrawNode{nil},
 	"undraw ${toto}/tata":            &undrawNode{testPath},
@@ -300,34 +304,48 @@ var commandsMatching = map[string]node{
 	"draw -f ${toto}/tata":           &drawNode{testPath, 0, true},
 	"draw -f ${toto}/tata 4 ":        &drawNode{testPath, 4, true},
 	".cmds:../toto/tata.ocli":        &loadNode{&strLeaf{"../toto/tata.ocli"}},
+	"debug ../toto/tata.ocli":        &debugScriptNode{&strLeaf{"../toto/tata.ocli"}},
 	".template:../toto/tata.ocli":    &loadTemplateNode{&strLeaf{"../toto/tata.ocli"}},
 	".var:a=42":                      &assignNode{"a", &intLeaf{42}},
+	".var:a=$(pwd)":                  &assignNode{"a", &commandSubstNode{&pwdNode{}}},
 	"=${toto}/tata":                  &selectObjectNode{testPath},
 	"=..":                            &selectObjectNode{&pathNode{&strLeaf{".."}}},
 	"={${toto}/tata}":                &selectChildrenNode{[]node{testPath}},
 	"={${toto}/tata, /toto/../tata}": &selectChildrenNode{[]node{testPath, testPath2}},
-	"-${toto}/tata":                  &deleteObjNode{testPath},
-	">${toto}/tata":                  &focusNode{testPath},
-	"+tenant:${toto}/tata@42ff42":    &createTenantNode{testPath, &strLeaf{"42ff42"}},
-	"+tn:${toto}/tata@42ff42":        &createTenantNode{testPath, &strLeaf{"42ff42"}},
-	"+site:${toto}/tata":             &createSiteNode{testPath},
-	"+si:${toto}/tata":               &createSiteNode{testPath},
-	"+building:${toto}/tata@[1., 2.]@3.@[.1, 2., 3.]":      &createBuildingNode{testPath, vec2(1., 2.), &floatLeaf{3.}, vec3(.1, 2., 3.)},
-	"+room:${toto}/tata@[1., 2.]@3.@[.1, 2., 3.]@+x-y":     &createRoomNode{testPath, vec2(1., 2.), &floatLeaf{3.}, vec3(.1, 2., 3.), &strLeaf{"+x-y"}, nil, nil},
-	"+room:${toto}/tata@[1., 2.]@3.@[.1, 2., 3.]@+x-y@m":   &createRoomNode{testPath, vec2(1., 2.), &floatLeaf{3.}, vec3(.1, 2., 3.), &strLeaf{"+x-y"}, &strLeaf{"m"}, nil},
-	"+room:${toto}/tata@[1., 2.]@3.@template":              &createRoomNode{testPath, vec2(1., 2.), &floatLeaf{3.}, nil, nil, nil, &strLeaf{"template"}},
-	"+rack:${toto}/tata@[1., 2.]@[.1, 2., 3.]@front":       &createRackNode{testPath, vec2(1., 2.), vec3(.1, 2., 3.), &strLeaf{"front"}},
-	"+rack:${toto}/tata@[1., 2.]@template@front":           &createRackNode{testPath, vec2(1., 2.), &strLeaf{"template"}, &strLeaf{"front"}},
-	"+device:${toto}/tata@42@42":                           &createDeviceNode{testPath, &intLeaf{42}, &intLeaf{42}, nil},
-	"+device:${toto}/tata@42@template":                     &createDeviceNode{testPath, &intLeaf{42}, &strLeaf{"template"}, nil},
-	"+device:${toto}/tata@42@template@frontflipped ":       &createDeviceNode{testPath, &intLeaf{42}, &strLeaf{"template"}, &strLeaf{"frontflipped"}},
-	"+device:${toto}/tata@slot42@42":                       &createDeviceNode{testPath, &strLeaf{"slot42"}, &intLeaf{42}, nil},
+	"={R{1..3}, {A,B}${toto}}": &selectChildrenNode{[]node{&pathNode{&strLeaf{"R{1..3}"}},
+		&pathNode{&formatStringNode{"{A,B}%v", []symbolReferenceNode{{"toto"}}}}}},
+	"-${toto}/tata":                                      &deleteObjNode{testPath, false},
+	"- -f ${toto}/tata":                                  &deleteObjNode{testPath, true},
+	">${toto}/tata":                                      &focusNode{testPath},
+	"+tenant:${toto}/tata@42ff42":                        &createTenantNode{testPath, &strLeaf{"42ff42"}},
+	"+tn:${toto}/tata@42ff42":                            &createTenantNode{testPath, &strLeaf{"42ff42"}},
+	"+site:${toto}/tata":                                 &createSiteNode{testPath},
+	"+si:${toto}/tata":                                   &createSiteNode{testPath},
+	"+building:${toto}/tata@[1., 2.]@3.@[.1, 2., 3.]":    &createBuildingNode{testPath, vec2(1., 2.), &floatLeaf{3.}, vec3(.1, 2., 3.)},
+	"+room:${toto}/tata@[1., 2.]@3.@[.1, 2., 3.]@+x-y":   &createRoomNode{testPath, vec2(1., 2.), &floatLeaf{3.}, vec3(.1, 2., 3.), &strLeaf{"+x-y"}, nil, nil},
+	"+room:${toto}/tata@[1., 2.]@3.@[.1, 2., 3.]@+x-y@m": &createRoomNode{testPath, vec2(1., 2.), &floatLeaf{3.}, vec3(.1, 2., 3.), &strLeaf{"+x-y"}, &strLeaf{"m"}, nil},
+	"+room:${toto}/tata@[1., 2.]@3.@template":            &createRoomNode{testPath, vec2(1., 2.), &floatLeaf{3.}, nil, nil, nil, &strLeaf{"template"}},
+	"+rack:${toto}/tata@[1., 2.]@[.1, 2., 3.]@front":     &createRackNode{testPath, vec2(1., 2.), vec3(.1, 2., 3.), &strLeaf{"front"}},
+	"+rack:${toto}/tata@[1., 2.]@template@front":         &createRackNode{testPath, vec2(1., 2.), &strLeaf{"template"}, &strLeaf{"front"}},
+	"+rk:R{01..10}@[1., 2.]@template@front": &createManyNode{&createRackNode{&pathNode{&strLeaf{"R{01..10}"}},
+		vec2(1., 2.), &strLeaf{"template"}, &strLeaf{"front"}}},
+	"+device:${toto}/tata@42@42":                     &createDeviceNode{testPath, &intLeaf{42}, &intLeaf{42}, nil},
+	"+device:${toto}/tata@42@template":               &createDeviceNode{testPath, &intLeaf{42}, &strLeaf{"template"}, nil},
+	"+device:${toto}/tata@42@template@frontflipped ": &createDeviceNode{testPath, &intLeaf{42}, &strLeaf{"template"}, &strLeaf{"frontflipped"}},
+	"+device:${toto}/tata@slot42@42":                 &createDeviceNode{testPath, &strLeaf{"slot42"}, &intLeaf{42}, nil},
+	"+rack:${toto}/tata@[600mm, 2.4m]@[60cm, 1.2m, 42U]@front": &createRackNode{testPath,
+		&arrNode{[]node{&quantityLeaf{cmd.Quantity{Value: 600, Unit: "mm"}}, &quantityLeaf{cmd.Quantity{Value: 2.4, Unit: "m"}}}},
+		&arrNode{[]node{&quantityLeaf{cmd.Quantity{Value: 60, Unit: "cm"}}, &quantityLeaf{cmd.Quantity{Value: 1.2, Unit: "m"}},
+			&quantityLeaf{cmd.Quantity{Value: 42, Unit: "U"}}}}, &strLeaf{"front"}},
+	"+device:${toto}/tata@10U@2U": &createDeviceNode{testPath, &quantityLeaf{cmd.Quantity{Value: 10, Unit: "U"}},
+		&quantityLeaf{cmd.Quantity{Value: 2, Unit: "U"}}, nil},
 	"+device:${toto}/tata@slot42@template":                 &createDeviceNode{testPath, &strLeaf{"slot42"}, &strLeaf{"template"}, nil},
 	"+device:${toto}/tata@slot42@template@frontflipped ":   &createDeviceNode{testPath, &strLeaf{"slot42"}, &strLeaf{"template"}, &strLeaf{"frontflipped"}},
 	"+group:${toto}/tata@{c1, c2}":                         &createGroupNode{testPath, []node{&pathNode{&strLeaf{"c1"}}, &pathNode{&strLeaf{"c2"}}}},
 	"+corridor:${toto}/tata@{r1, r2}@42.7":                 &createCorridorNode{testPath, &pathNode{&strLeaf{"r1"}}, &pathNode{&strLeaf{"r2"}}, &floatLeaf{42.7}},
 	"${toto}/tata:areas=[1., 2., 3., 4.]@[1., 2., 3., 4.]": &updateObjNode{testPath, "areas", []node{vec4(1., 2., 3., 4.), vec4(1., 2., 3., 4.)}, false},
 	"${toto}/tata:separator=[1., 2.]@[1., 2.]@wireframe":   &updateObjNode{testPath, "separator", []node{vec2(1., 2.), vec2(1., 2.), &strLeaf{"wireframe"}}, false},
+	"/P/SI/**/R[12]:color=ff0000":                          &updateObjNode{&pathNode{&strLeaf{"/P/SI/**/R[12]"}}, "color", []node{&strLeaf{"ff0000"}}, false},
 	"${toto}/tata:attr=42":                                 &updateObjNode{testPath, "attr", []node{&intLeaf{42}}, false},
 	"${toto}/tata:label=\"plouf\"":                         &updateObjNode{testPath, "label", []node{&strLeaf{"plouf"}}, false},
 	"${toto}/tata:labelFont=bold":                          &updateObjNode{testPath, "labelFont", []node{&strLeaf{"bold"}}, false},
@@ -348,9 +366,18 @@ var commandsMatching = map[string]node{
 	"camera.wait=15":                                       &cameraWaitNode{15.},
 	"camera.wait = 15":                                     &cameraWaitNode{15.},
 	"clear":                                                &clrNode{},
+	"exit":                                                 &exitNode{nil},
+	"exit 3":                                               &exitNode{&intLeaf{3}},
 	".cmds:${CUST}/DEMO.PERF.ocli":                         &loadNode{&formatStringNode{"%v/DEMO.PERF.ocli", []symbolReferenceNode{{"CUST"}}}},
 	".cmds:${a}/${b}.ocli":                                 &loadNode{&formatStringNode{"%v/%v.ocli", []symbolReferenceNode{{"a"}, {"b"}}}},
-	"while $i<6 {print \"a\"}":                             &whileNode{&comparatorNode{"<", &symbolReferenceNode{"i"}, &intLeaf{6}}, &printNode{&strLeaf{"a"}}},
+	".var:m={\"a b\": $x.y[0], c: {}}": &assignNode{"m", &mapNode{[]string{"a b", "c"},
+		[]node{&indexNode{&fieldNode{&symbolReferenceNode{"x"}, "y", false}, &intLeaf{0}}, &mapNode{[]string{}, []node{}}}}},
+	".var:v=$h in [42, 47] && $name =~ \"^B\" ? \"-\" * 2 : $a ? 1 : 2": &assignNode{"v", &conditionalNode{
+		&logicalNode{"&&", &inNode{&symbolReferenceNode{"h"}, &arrNode{[]node{&intLeaf{42}, &intLeaf{47}}}},
+			&matchNode{"=~", &symbolReferenceNode{"name"}, &strLeaf{"^B"}}},
+		&arithNode{"*", &strLeaf{"-"}, &intLeaf{2}},
+		&conditionalNode{&symbolReferenceNode{"a"}, &intLeaf{1}, &intLeaf{2}}}},
+	"while $i<6 {print \"a\"}": &whileNode{&comparatorNode{"<", &symbolReferenceNode{"i"}, &intLeaf{6}}, &printNode{&strLeaf{"a"}}},
 }
 
 func TestSimpleCommands(t *testing.T) {
@@ -405,10 +432,145 @@ func TestElif(t *testing.T) {
 	command := "if 5 == 6  {ls;} elif 5 == 4 {tree;} else {pwd;}"
 	condition := &equalityNode{"==", &intLeaf{5}, &intLeaf{6}}
 	conditionElif := &equalityNode{"==", &intLeaf{5}, &intLeaf{4}}
-	ifBody := &ast{[]node{&lsNode{&pathNode{&strLeaf{""}}}, nil}}
-	elifBody := &ast{[]node{&treeNode{&pathNode{&strLeaf{"."}}, 0}, nil}}
-	elseBody := &ast{[]node{&pwdNode{}, nil}}
+	ifBody := &lsNode{&pathNode{&strLeaf{""}}}
+	elifBody := &treeNode{&pathNode{&strLeaf{"."}}, 0}
+	elseBody := &pwdNode{}
 	elif := &ifNode{conditionElif, elifBody, elseBody}
 	expected := &ifNode{condition, ifBody, elif}
 	testCommand(command, expected, t)
 }
+
+func TestParseFuncDef(t *testing.T) {
+	command := "alias myfunc(site, count) {print $site}"
+	expected := &funcDefNode{"myfunc", []string{"site", "count"}, &printNode{&symbolReferenceNode{"site"}}}
+	testCommand(command, expected, t)
+	command = "alias myfunc {print \"a\"}"
+	expected = &funcDefNode{"myfunc", []string{}, &printNode{&strLeaf{"a"}}}
+	testCommand(command, expected, t)
+}
+
+func TestParseFuncCall(t *testing.T) {
+	command := "myfunc(\"a\", 1 + 2)"
+	expected := &funcCallNode{"myfunc", []node{&strLeaf{"a"}, &arithNode{"+", &intLeaf{1}, &intLeaf{2}}}}
+	testCommand(command, expected, t)
+	testCommand("myfunc", &funcCallNode{"myfunc", []node{}}, t)
+	testCommand("myfunc()", &funcCallNode{"myfunc", []node{}}, t)
+	//Names starting with a keyword are not this keyword
+	for _, name := range []string{"debugRacks", "tryAll", "returnAll",
+		"lsx", "printAll", "while2", "drawAll"} {
+		testCommand(name, &funcCallNode{name, []node{}}, t)
+		testCommand(name+"(1)", &funcCallNode{name, []node{&intLeaf{1}}}, t)
+	}
+}
+
+func TestParseGlobal(t *testing.T) {
+	testCommand("global a, b", &globalNode{[]string{"a", "b"}}, t)
+}
+
+func TestMultiLineBlock(t *testing.T) {
+	command := "while $i<6 {\n  print \"a\"\n  print \"b\"\n}"
+	condition := &comparatorNode{"<", &symbolReferenceNode{"i"}, &intLeaf{6}}
+	body := &ast{[]node{&printNode{&strLeaf{"a"}}, &printNode{&strLeaf{"b"}}}}
+	testCommand(command, &whileNode{condition, body}, t)
+}
+
+func TestMultiLineElse(t *testing.T) {
+	command := "if true {\n  pwd\n}\nelse {\n  pwd\n}"
+	testCommand(command, &ifNode{&boolLeaf{true}, &pwdNode{}, &pwdNode{}}, t)
+}
+
+func TestStripComment(t *testing.T) {
+	if s := stripComment("print \"a//b\" // comment"); s != "print \"a//b\" " {
+		t.Errorf("wrong comment stripping : %q", s)
+	}
+}
+
+func TestParseExprStringEscapes(t *testing.T) {
+	frame := newFrame("\"rack-${i}\\n\\\"50%\\\" \\$i\"")
+	expr, _, err := parseExpr(frame)
+	if err != nil {
+		t.Errorf("error while parsing : %s", err.Error())
+		return
+	}
+	expected := &formatStringNode{"rack-%v\n\"50%%\" $i", []symbolReferenceNode{{"i"}}}
+	assertParsing(expr, expected, t)
+	frame = newFrame("\"a\\tb\"")
+	expr, _, err = parseExpr(frame)
+	if err != nil {
+		t.Errorf("error while parsing : %s", err.Error())
+		return
+	}
+	assertParsing(expr, &strLeaf{"a\tb"}, t)
+	_, _, err = parseExpr(newFrame("\"a\\qb\""))
+	if err == nil {
+		t.Errorf("unknown escape sequence should be rejected")
+	}
+}
+
+func TestParseControlFlow(t *testing.T) {
+	command := "while true {if $i == 2 {break} else {continue}}"
+	condition := &equalityNode{"==", &symbolReferenceNode{"i"}, &intLeaf{2}}
+	body := &ifNode{condition, &breakNode{}, &continueNode{}}
+	testCommand(command, &whileNode{&boolLeaf{true}, body}, t)
+	testCommand("return", &returnNode{nil}, t)
+	testCommand("return $a + 1", &returnNode{&arithNode{"+", &symbolReferenceNode{"a"}, &intLeaf{1}}}, t)
+}
+
+func TestParseExprFuncCall(t *testing.T) {
+	frame := newFrame("square($a, 2) + 1")
+	expr, _, err := parseExpr(frame)
+	if err != nil {
+		t.Errorf("error while parsing : %s", err.Error())
+		return
+	}
+	call := &funcCallNode{"square", []node{&symbolReferenceNode{"a"}, &intLeaf{2}}}
+	assertParsing(expr, &arithNode{"+", call, &intLeaf{1}}, t)
+}
+
+func TestParseTry(t *testing.T) {
+	command := "try {pwd} catch $err {print $err} finally {clear}"
+	expected := &tryNode{&pwdNode{}, "err", &printNode{&symbolReferenceNode{"err"}}, &clrNode{}}
+	testCommand(command, expected, t)
+	command = "try {\n  pwd\n}\ncatch {\n  clear\n}"
+	testCommand(command, &tryNode{&pwdNode{}, "", &clrNode{}, nil}, t)
+	if _, err := Parse("try {pwd}"); err == nil {
+		t.Errorf("try without catch nor finally should not be parsed")
+	}
+}
+
+func TestParseCommandSubst(t *testing.T) {
+	command := "for x in $(lsrack -r ${room}) {print $x}"
+	lsrack := &lsObjNode{&pathNode{&formatStringNode{"%v", []symbolReferenceNode{{"room"}}}},
+		indexOf(lsCommands, "lsrack"), true, "", nil, ""}
+	expected := &forArrayNode{"x", &commandSubstNode{lsrack}, &printNode{&symbolReferenceNode{"x"}}}
+	testCommand(command, expected, t)
+	command = "print count($(ls; pwd)) + 1"
+	body := &ast{[]node{&lsNode{&pathNode{&strLeaf{""}}}, &pwdNode{}}}
+	expected2 := &printNode{&arithNode{"+", &funcCallNode{"count", []node{&commandSubstNode{body}}}, &intLeaf{1}}}
+	testCommand(command, expected2, t)
+	if _, err := Parse("print $(ls"); err == nil {
+		t.Errorf("unclosed command substitution should not parse")
+	}
+}
+
+func TestParseMapAccess(t *testing.T) {
+	frame := newFrame("{\"height\": 42, color: \"red\", \"pos\": [1, 2]}")
+	expr, _, err := parseExpr(frame)
+	if err != nil {
+		t.Fatalf("error while parsing : %s", err.Error())
+	}
+	expected := &mapNode{[]string{"height", "color", "pos"},
+		[]node{&intLeaf{42}, &strLeaf{"red"}, &arrNode{[]node{&intLeaf{1}, &intLeaf{2}}}}}
+	if !reflect.DeepEqual(expr, expected) {
+		t.Errorf("unexpected parsing : \ntree : %s\nexpected : %s",
+			spew.Sdump(expr), spew.Sdump(expected))
+	}
+	command := "print $rack.attributes[\"posXY\"][0] + ${rack.attributes.height}"
+	access := &indexNode{&fieldNode{&symbolReferenceNode{"rack"}, "attributes", false}, &strLeaf{"posXY"}}
+	expected2 := &printNode{&arithNode{"+", &indexNode{access, &intLeaf{0}},
+		&symbolReferenceNode{"rack.attributes.height"}}}
+	testCommand(command, expected2, t)
+	command = "print $(get R1).name"
+	expected3 := &printNode{&fieldNode{&commandSubstNode{&getObjectNode{&pathNode{&strLeaf{"R1"}}}}, "name", false}}
+	testCommand(command, expected3, t)
+}
@@ -3,6 +3,7 @@ package readline
 import (
 	"errors"
 	"io"
+	"strings"
 	"sync"
 )
 
@@ -28,6 +29,12 @@ type Operation struct {
 	w       io.Writer
 
 	history *opHistory
+
+	// lines of a bracketed paste, they are returned
+	// together once the last one is validated
+	pasting bool
+	pasted  []string
+
 	*opSearch
 	*opCompleter
 	*opPassword
@@ -255,6 +262,16 @@ func (o *Operation) ioloop() {
 			o.buf.BackEscapeWord()
 		case CharCtrlY:
 			o.buf.Yank()
+		case MetaPasteStart:
+			o.pasting = true
+		case MetaPasteEnd:
+			o.pasting = false
+			if o.buf.Len() == 0 && len(o.pasted) > 0 {
+				// the pasted text ends with a new line
+				data := []rune(strings.Join(o.pasted, "\n"))
+				o.pasted = nil
+				o.outchan <- data
+			}
 		case CharEnter, CharCtrlJ:
 			if o.IsSearchMode() {
 				o.ExitSearchMode(false)
@@ -273,7 +290,15 @@ func (o *Operation) ioloop() {
 				o.buf.Clean()
 				data = o.buf.Reset()
 			}
-			o.outchan <- data
+			if o.pasting {
+				o.pasted = append(o.pasted, string(data))
+				o.t.KickRead()
+			} else if len(o.pasted) > 0 {
+				o.outchan <- []rune(strings.Join(append(o.pasted, string(data)), "\n"))
+				o.pasted = nil
+			} else {
+				o.outchan <- data
+			}
 			if !o.GetConfig().DisableAutoSaveHistory {
 				// ignore IO error
 				_ = o.history.New(data)
@@ -330,6 +355,8 @@ func (o *Operation) ioloop() {
 				o.buf.Refresh(nil)
 				break
 			}
+			o.pasting = false
+			o.pasted = nil
 			o.buf.MoveToLineEnd()
 			o.buf.Refresh(nil)
 			hint := o.GetConfig().InterruptPrompt + "\n"
@@ -54,6 +54,8 @@ const (
 	MetaDelete
 	MetaBackspace
 	MetaTranspose
+	MetaPasteStart // bracketed paste mode, Esc[200~
+	MetaPasteEnd   // Esc[201~
 )
 
 // WaitForResume need to call before current process got suspend.
@@ -122,8 +124,13 @@ func escapeExKey(key *escapeKeyPair) rune {
 	case 'F':
 		r = CharLineEnd
 	case '~':
-		if key.attr == "3" {
+		switch key.attr {
+		case "3":
 			r = CharDelete
+		case "200":
+			r = MetaPasteStart
+		case "201":
+			r = MetaPasteEnd
 		}
 	default:
 	}
@@ -12,34 +12,92 @@ package main
 import (
 	c "cli/controllers"
 	l "cli/logger"
+	"cli/models"
 	"cli/readline"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/joho/godotenv"
 )
 
-func InterpretLine(str string) {
+// Parses and executes a command, returns false if it fails
+func InterpretLine(str string) bool {
 	root, parseErr := Parse(str)
 	if parseErr != nil {
 		fmt.Println(parseErr.Error())
-		return
+		return false
 	}
 	if root == nil {
-		return
+		return true
 	}
 	_, err := root.execute()
+	if exitErr, ok := err.(*exitError); ok {
+		c.Exit(exitErr.code)
+	}
 	if err != nil {
 		l.GetErrorLogger().Println(err.Error())
-		if c.State.DebugLvl > c.NONE {
+		var interruptErr *interruptError
+		if errors.As(err, &interruptErr) {
+			//Ctrl-C is always acknowledged, with where it stopped
+			if traceErr, ok := err.(*stackTraceError); ok {
+				fmt.Println(traceErr.Error())
+			} else if interruptErr.statement != "" {
+				fmt.Println("Interrupted : " + interruptErr.statement)
+			} else {
+				fmt.Println("Interrupted : " + str)
+			}
+		} else if c.State.DebugLvl > c.NONE {
 			if traceErr, ok := err.(*stackTraceError); ok {
 				fmt.Println(traceErr.Error())
 			} else {
 				fmt.Println("Error : " + err.Error())
 			}
 		}
+		return false
+	}
+	return true
+}
+
+// Gathers the lines typed or pasted in the REPL until they form
+// complete statements, with the same rules as the lines of a script
+type inputBuffer struct {
+	lines []string
+}
+
+func (b *inputBuffer) add(text string) {
+	b.lines = append(b.lines, strings.Split(text, "\n")...)
+}
+
+func (b *inputBuffer) pending() bool {
+	return len(b.lines) > 0
+}
+
+// Tells whether more lines are needed : a { is left
+// open or the last line ends with a \
+func (b *inputBuffer) incomplete() bool {
+	if len(b.lines) == 0 {
+		return false
 	}
+	last := strings.TrimRight(stripComment(b.lines[len(b.lines)-1]), " \t")
+	if strings.HasSuffix(last, "\\") {
+		return true
+	}
+	depth := 0
+	for _, line := range b.lines {
+		depth = braceDepth(stripComment(line), depth)
+	}
+	return depth > 0
+}
+
+// Returns the statements of the input and empties the buffer
+func (b *inputBuffer) statements() []parsedLine {
+	statements := splitStatements(b.lines)
+	b.lines = nil
+	return statements
 }
 
 // Init the Shell
@@ -55,19 +113,36 @@ func Start(flags *Flags) {
 		fmt.Println("Please ensure that you have a properly formatted environment file saved as '.env' in the same directory here with the shell")
 		fmt.Println("\n\nFor more details please refer to: https://ogree.ditrit.io/htmls/programming.html")
 		fmt.Println("View an environment file example here: https://ogree.ditrit.io/htmls/clienv.html")
-		return
+		os.Exit(1)
 	}
 
 	c.InitTimeout(env)                           //Set the Unity Timeout
+	c.InitAPIClient(env)                         //Set the API timeout and retries
 	c.GetURLs(flags.APIURL, flags.unityURL, env) //Set the URLs
 	c.InitKey(flags.APIKEY, env)                 //Set the API Key
 	user, _ := c.Login(env)
 
 	c.InitState(env)
 
+	//Execute Script if provided as arg and exit
+	if flags.script != "" {
+		if strings.Contains(flags.script, ".ocli") && flags.debug {
+			os.Exit(RunDebug(flags.script, flags.args))
+		}
+		if strings.Contains(flags.script, ".ocli") {
+			os.Exit(RunScript(flags.script, flags.args))
+		}
+	}
+
+	//Commands piped or redirected to the shell
+	//are executed like a script and exit
+	if !readline.IsTerminal(int(os.Stdin.Fd())) {
+		coloredOutput = false
+		os.Exit(RunStream(os.Stdin, flags.args))
+	}
+
 	rl, err := readline.NewEx(&readline.Config{
-		Prompt: "\u001b[1m\u001b[32m" + user + "@" + "OGrEE3D:" +
-			"\u001b[37;1m" + c.State.CurrPath + "\u001b[1m\u001b[32m$>\u001b[0m ",
+		Prompt:          prompt(user),
 		HistoryFile:     c.State.HistoryFilePath,
 		AutoComplete:    GetPrefixCompleter(),
 		InterruptPrompt: "^C",
@@ -84,30 +159,88 @@ func Start(flags *Flags) {
 	//Allow the ShellState to hold a ptr to readline
 	c.SetStateReadline(rl)
 
-	//Execute Script if provided as arg and exit
-	if flags.script != "" {
-		if strings.Contains(flags.script, ".ocli") {
-			script := flags.script
-			LoadFile(script)
-			os.Exit(0)
-		}
-	}
 	c.InitUnityCom(rl, c.State.UnityClientURL)
+	loadRCFile()
 
 	Repl(rl, user)
 }
 
+func prompt(user string) string {
+	return "\u001b[1m\u001b[32m" + user + "@" + "OGrEE3D:" +
+		"\u001b[37;1m" + c.State.CurrPath + "\u001b[1m\u001b[32m$>\u001b[0m "
+}
+
+// Prompt shown while the lines of an incomplete command are typed
+const continuationPrompt = "\u001b[1m\u001b[32m...>\u001b[0m "
+
+// Enables the bracketed paste mode of the terminal, so that
+// a pasted block of lines is received and executed at once
+const (
+	enableBracketedPaste  = "\u001b[?2004h"
+	disableBracketedPaste = "\u001b[?2004l"
+)
+
+// Runs f, Ctrl-C stops it at the next statement or loop
+// iteration and cancels the API request being sent
+func runInterruptible(f func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	models.SetContext(ctx)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-signals:
+			interrupted.Store(true)
+			cancel()
+		case <-done:
+		}
+	}()
+	defer func() {
+		close(done)
+		<-stopped
+		signal.Stop(signals)
+		cancel()
+		models.SetContext(context.Background())
+		interrupted.Store(false)
+	}()
+	f()
+}
+
 // The loop of the program
 func Repl(rl *readline.Instance, user string) {
+	fmt.Print(enableBracketedPaste)
+	defer fmt.Print(disableBracketedPaste)
+	input := &inputBuffer{}
 	for {
 		line, err := rl.Readline()
+		if err == readline.ErrInterrupt && input.pending() {
+			//Ctrl-C discards the incomplete command
+			input.statements()
+			rl.SetPrompt(prompt(user))
+			continue
+		}
 		if err != nil { // io.EOF
 			break
 		}
-		InterpretLine(line)
+		input.add(line)
+		if input.incomplete() {
+			rl.SetPrompt(continuationPrompt)
+			continue
+		}
+		statements := input.statements()
+		runInterruptible(func() {
+			for _, statement := range statements {
+				if !InterpretLine(statement.line) {
+					//The rest of a pasted block is not executed
+					break
+				}
+			}
+		})
 		//c.UpdateSessionState(&line)
 		//Update Prompt
-		rl.SetPrompt("\u001b[1m\u001b[32m" + user + "@" + "OGrEE3D:" +
-			"\u001b[37;1m" + c.State.CurrPath + "\u001b[1m\u001b[32m$>\u001b[0m ")
+		rl.SetPrompt(prompt(user))
 	}
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestInputBuffer(t *testing.T) {
+	input := &inputBuffer{}
+	input.add("for i in 0..3 {")
+	if !input.incomplete() {
+		t.Errorf("an open brace should ask for more lines")
+	}
+	input.add("  print \"}\" // }")
+	if !input.incomplete() {
+		t.Errorf("braces in strings and comments should be ignored")
+	}
+	input.add("}")
+	if input.incomplete() {
+		t.Errorf("the loop should be complete")
+	}
+	if statements := input.statements(); len(statements) != 1 {
+		t.Errorf("wrong number of statements : %d", len(statements))
+	}
+	if input.pending() {
+		t.Errorf("the buffer should be empty after reading the statements")
+	}
+	input.add("print \"a\" + \\")
+	if !input.incomplete() {
+		t.Errorf("a trailing backslash should ask for more lines")
+	}
+	input.add("  \"b\"")
+	if statements := input.statements(); len(statements) != 1 || statements[0].line != "print \"a\" +    \"b\"" {
+		t.Errorf("wrong continued statement : %v", statements)
+	}
+	//A pasted block
+	input.add(".var:i=0\n\nif $i == 0 {\n  pwd\n}\nprint $i")
+	if input.incomplete() {
+		t.Errorf("the pasted block should be complete")
+	}
+	if statements := input.statements(); len(statements) != 3 {
+		t.Errorf("wrong number of pasted statements : %d", len(statements))
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+//This file saves the variables and the functions of the
+//shell as an OCLI script, and loads them back
+
+import (
+	cmd "cli/controllers"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Script loaded at the start of the shell, if it exists
+const rcFileName = ".ogreerc.ocli"
+
+// Returns an expression giving back the value, false
+// if the value cannot be written in the language
+func valueLiteral(v interface{}) (node, bool) {
+	switch v := v.(type) {
+	case int:
+		return &intLeaf{v}, true
+	case float64:
+		return &floatLeaf{v}, true
+	case bool:
+		return &boolLeaf{v}, true
+	case string:
+		return &strLeaf{v}, true
+	case cmd.Quantity:
+		return &quantityLeaf{v}, true
+	case map[string]interface{}:
+		keys := sortedKeys(v)
+		values := []node{}
+		for _, key := range keys {
+			val, ok := valueLiteral(v[key])
+			if !ok {
+				return nil, false
+			}
+			values = append(values, val)
+		}
+		return &mapNode{keys, values}, true
+	}
+	elts, ok := arrayElements(v)
+	if !ok {
+		return nil, false
+	}
+	nodes := []node{}
+	for _, elt := range elts {
+		switch elt.(type) {
+		case int, float64, string, cmd.Quantity:
+		default:
+			//Array literals only hold numbers and strings
+			return nil, false
+		}
+		val, _ := valueLiteral(elt)
+		nodes = append(nodes, val)
+	}
+	return &arrNode{nodes}, true
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns the script defining the global variables and the functions
+// of the shell, the values that cannot be written are reported in skipped
+func sessionSource() (source string, skipped []string) {
+	f := &formatter{blank: map[int]bool{}, noSource: true}
+	f.output = append(f.output, "// Session saved by save-session")
+	for _, name := range sortedKeys(funcTable) {
+		if def, ok := funcTable[name].(*funcDefNode); ok {
+			f.statement(def, 0)
+		}
+	}
+	for _, name := range sortedKeys(dynamicSymbolTable) {
+		if !attributeRegex.MatchString(name) {
+			//$1, $2... are the arguments of a script
+			continue
+		}
+		val, ok := valueLiteral(dynamicSymbolTable[name])
+		if !ok {
+			skipped = append(skipped, name)
+			continue
+		}
+		f.statement(&assignNode{name, val}, 0)
+	}
+	return strings.Join(f.output, "\n") + "\n", skipped
+}
+
+func SaveSession(path string) error {
+	source, skipped := sessionSource()
+	if len(skipped) > 0 {
+		fmt.Println("Variables not saved, their values cannot be written : " +
+			strings.Join(skipped, ", "))
+	}
+	return os.WriteFile(path, []byte(source), 0644)
+}
+
+// Executes a session script without printing its commands
+func LoadSession(path string) error {
+	file, err := parseFile(path)
+	if err != nil {
+		return err
+	}
+	return executeStatements(filepath.Base(path), file, false)
+}
+
+// Loads ~/.ogreerc.ocli, where the users keep their own
+// functions and variables, before the REPL starts
+func loadRCFile() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	path := filepath.Join(home, rcFileName)
+	if _, err := os.Stat(path); err != nil {
+		return
+	}
+	if err := LoadSession(path); err != nil {
+		fmt.Println("Error while loading " + path + " : " + err.Error())
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoadSession(t *testing.T) {
+	executeCommand(".var:sessInt=42; .var:sessStr=\"a \\\"\\$b\\\"\\n\"; .var:sessArr=[1, 2.5]", t)
+	executeCommand(".var:sessMap={\"name\": \"R1\", \"size\": [1, 2], \"sub\": {}}", t)
+	executeCommand(".var:sessWords=split(\"a,b;c\", \",\"); .var:sessMixed=[\"a\", 1, 2mm]", t)
+	//The arrays do not depend on the split function
+	executeCommand("alias split(s, sep) {return 0}", t)
+	defer delete(funcTable, "split")
+	executeCommand("alias sessFunc(x) {if $x > 1 {return $x} elif $x == 1 {print \"one\"} else {return 0}}", t)
+	saved := map[string]interface{}{}
+	for _, name := range []string{"sessInt", "sessStr", "sessArr", "sessMap", "sessWords", "sessMixed"} {
+		saved[name] = dynamicSymbolTable[name]
+	}
+	path := filepath.Join(t.TempDir(), "session.ocli")
+	if err := SaveSession(path); err != nil {
+		t.Fatalf("cannot save session : %s", err.Error())
+	}
+	for name := range saved {
+		delete(dynamicSymbolTable, name)
+	}
+	delete(funcTable, "sessFunc")
+	if err := LoadSession(path); err != nil {
+		content, _ := os.ReadFile(path)
+		t.Fatalf("cannot load session : %s\n%s", err.Error(), content)
+	}
+	for name, val := range saved {
+		if !reflect.DeepEqual(dynamicSymbolTable[name], val) {
+			t.Errorf("%s restored as %v, %v expected", name, dynamicSymbolTable[name], val)
+		}
+	}
+	executeCommand(".var:sessRes=sessFunc(3)", t)
+	if dynamicSymbolTable["sessRes"] != 3 {
+		t.Errorf("function not restored : %v", dynamicSymbolTable["sessRes"])
+	}
+	content, _ := os.ReadFile(path)
+	if !strings.Contains(string(content), "alias sessFunc(x) {\n    if $x > 1 {") {
+		t.Errorf("functions should be saved in canonical form :\n%s", content)
+	}
+}